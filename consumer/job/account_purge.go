@@ -0,0 +1,159 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+)
+
+// Accounts purged per query, the job keeps querying until nothing is due
+const accountPurgeBatchSize = 100
+
+// AccountPurgeJob carries out account deletions whose grace period has ended: the user row is
+// anonymised, related records and the uploaded avatar are removed and user.deleted is published.
+type AccountPurgeJob struct {
+	repo     *repository.Repository
+	upload   *provider.UploadServiceProvider
+	events   *provider.EventProducer
+	interval time.Duration
+}
+
+func NewAccountPurgeJob(repo *repository.Repository, upload *provider.UploadServiceProvider, events *provider.EventProducer, interval time.Duration) *AccountPurgeJob {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &AccountPurgeJob{
+		repo:     repo,
+		upload:   upload,
+		events:   events,
+		interval: interval,
+	}
+}
+
+// Run purges due accounts right away and then every interval until ctx is cancelled
+func (j *AccountPurgeJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.PurgeDueAccounts(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDueAccounts purges every account due now and then finishes the side effects of purged accounts.
+// Failed accounts are retried on the next run.
+func (j *AccountPurgeJob) PurgeDueAccounts(ctx context.Context) {
+	var purged, failed int
+	for ctx.Err() == nil {
+		users, err := j.repo.GetUsersDueForPurge(time.Now(), accountPurgeBatchSize)
+		if err != nil {
+			log.Printf("[Account Purge] Failed to load due accounts: %v", err)
+			break
+		}
+
+		progressed := false
+		for _, user := range users {
+			ok, err := j.repo.PurgeUser(user.UserID)
+			if err != nil {
+				log.Printf("[Account Purge] Failed to purge user %s: %v", user.UserID, err)
+				failed++
+				continue
+			}
+			if !ok {
+				// Cancelled since it was loaded
+				continue
+			}
+			progressed = true
+			purged++
+		}
+
+		// A short page means everything due was seen; a page of failures would loop forever
+		if len(users) < accountPurgeBatchSize || !progressed {
+			break
+		}
+	}
+
+	if purged > 0 || failed > 0 {
+		log.Printf("[Account Purge] Purged %d accounts, %d failed", purged, failed)
+	}
+
+	j.CompletePurges(ctx)
+}
+
+// CompletePurges deletes the avatars and publishes user.deleted for purged accounts. PurgeUser records
+// both in the same transaction as the anonymisation, so they are retried on every run until they succeed.
+func (j *AccountPurgeJob) CompletePurges(ctx context.Context) {
+	var completed, pending int
+	for ctx.Err() == nil {
+		users, err := j.repo.GetUsersWithPendingPurgeEffects(accountPurgeBatchSize)
+		if err != nil {
+			log.Printf("[Account Purge] Failed to load accounts with pending side effects: %v", err)
+			return
+		}
+
+		progressed := false
+		for _, user := range users {
+			if j.completePurge(ctx, &user) {
+				progressed = true
+				completed++
+			} else {
+				pending++
+			}
+		}
+
+		if len(users) < accountPurgeBatchSize || !progressed {
+			break
+		}
+	}
+
+	if completed > 0 || pending > 0 {
+		log.Printf("[Account Purge] Completed %d purged accounts, %d still pending", completed, pending)
+	}
+}
+
+// completePurge carries out what is left for one purged account and reports whether nothing is left
+func (j *AccountPurgeJob) completePurge(ctx context.Context, user *entity.User) bool {
+	done := true
+
+	if user.PurgeAvatarURL != nil {
+		if err := j.upload.DeleteFile(*user.PurgeAvatarURL); err != nil {
+			log.Printf("[Account Purge] Failed to delete avatar of user %s: %v", user.UserID, err)
+			done = false
+		} else if err := j.repo.MarkPurgeAvatarDeleted(user.UserID); err != nil {
+			log.Printf("[Account Purge] Failed to record avatar deletion of user %s: %v", user.UserID, err)
+			done = false
+		}
+	}
+
+	if user.DeletionEventPublishedAt == nil {
+		if err := j.repo.DeleteUserCache(ctx, user.UserID); err != nil {
+			log.Printf("[Account Purge] Failed to clear cache of user %s: %v", user.UserID, err)
+		}
+
+		event := provider.UserDeletedEvent{
+			UserID:      user.UserID.String(),
+			RequestedAt: user.DeletionRequestedAt,
+			DeletedAt:   *user.PurgedAt,
+		}
+		if err := j.events.PublishUserDeleted(ctx, event); err != nil {
+			log.Printf("[Account Purge] Failed to publish user.deleted for user %s: %v", user.UserID, err)
+			return false
+		}
+		// A failure here publishes the event again on the next run, consumers must accept duplicates
+		if err := j.repo.MarkDeletionEventPublished(user.UserID, time.Now()); err != nil {
+			log.Printf("[Account Purge] Failed to record user.deleted for user %s: %v", user.UserID, err)
+			return false
+		}
+	}
+
+	return done
+}
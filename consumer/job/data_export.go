@@ -0,0 +1,468 @@
+package job
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/infra"
+	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+)
+
+const (
+	dataExportQueue = "account.data_export"
+
+	// Login events loaded per query while building an export
+	dataExportLoginPageSize = 500
+
+	// How often archives whose link expired unused are dropped
+	dataExportCleanupInterval = time.Hour
+
+	// Subscribing again after a closed channel, the process exits after this many failures in a row
+	dataExportSubscribeAttempts = 5
+	dataExportSubscribeBackoff  = 5 * time.Second
+)
+
+// DataExportJob builds the personal data archives requested through /profile/data-export and
+// emails a single-use download link to their owner.
+type DataExportJob struct {
+	repo     *repository.Repository
+	emails   *provider.EmailProducer
+	rabbitmq *infra.RabbitMQClient
+	linkTTL  time.Duration
+	domain   string
+}
+
+func NewDataExportJob(repo *repository.Repository, emails *provider.EmailProducer, rabbitmq *infra.RabbitMQClient, linkTTL time.Duration, domain string) *DataExportJob {
+	if linkTTL <= 0 {
+		linkTTL = 48 * time.Hour
+	}
+	return &DataExportJob{
+		repo:     repo,
+		emails:   emails,
+		rabbitmq: rabbitmq,
+		linkTTL:  linkTTL,
+		domain:   domain,
+	}
+}
+
+// Run consumes export requests until ctx is cancelled and drops expired archives every hour. When the
+// delivery channel closes it subscribes again on a new channel; once the connection is gone or
+// subscribing keeps failing the process exits, so it is restarted with a fresh connection.
+func (j *DataExportJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(dataExportCleanupInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		deliveries, channel, err := j.subscribe()
+		if err != nil {
+			failures++
+			if j.rabbitmq.Connection.IsClosed() || failures >= dataExportSubscribeAttempts {
+				log.Fatalf("[Data Export] Failed to subscribe to %s after %d attempts: %v", dataExportQueue, failures, err)
+			}
+			log.Printf("[Data Export] Failed to subscribe to %s, retrying: %v", dataExportQueue, err)
+		} else {
+			failures = 0
+			stopped := j.consume(ctx, deliveries, ticker.C)
+			channel.Close()
+			if stopped {
+				return
+			}
+			log.Printf("[Data Export] Delivery channel closed, subscribing again")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dataExportSubscribeBackoff):
+		}
+	}
+}
+
+// consume handles deliveries until ctx is cancelled, returning true, or the channel closes
+func (j *DataExportJob) consume(ctx context.Context, deliveries <-chan amqp.Delivery, cleanup <-chan time.Time) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-cleanup:
+			j.DropExpiredArchives()
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return false
+			}
+			j.handle(ctx, delivery)
+		}
+	}
+}
+
+// subscribe consumes the export queue on a channel of its own, so a channel error does not take down
+// the one the producers publish on
+func (j *DataExportJob) subscribe() (<-chan amqp.Delivery, *amqp.Channel, error) {
+	channel, err := j.rabbitmq.Connection.Channel()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+
+	deliveries, err := func() (<-chan amqp.Delivery, error) {
+		if err := channel.ExchangeDeclare(provider.AccountEventExchange, "topic", true, false, false, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to declare exchange %s: %w", provider.AccountEventExchange, err)
+		}
+		if _, err := channel.QueueDeclare(dataExportQueue, true, false, false, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to declare queue %s: %w", dataExportQueue, err)
+		}
+		if err := channel.QueueBind(dataExportQueue, provider.DataExportRequestedRoutingKey, provider.AccountEventExchange, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to bind queue %s: %w", dataExportQueue, err)
+		}
+		// Archives are built one at a time
+		if err := channel.Qos(1, 0, false); err != nil {
+			return nil, fmt.Errorf("failed to set qos: %w", err)
+		}
+		return channel.Consume(
+			dataExportQueue, // queue
+			"",              // consumer
+			false,           // auto-ack
+			false,           // exclusive
+			false,           // no-local
+			false,           // no-wait
+			nil,             // args
+		)
+	}()
+	if err != nil {
+		channel.Close()
+		return nil, nil, err
+	}
+	return deliveries, channel, nil
+}
+
+// handle builds one export. The message is always acked: a failed export is recorded on its row
+// and the user can request a new one.
+func (j *DataExportJob) handle(ctx context.Context, delivery amqp.Delivery) {
+	defer func() {
+		if err := delivery.Ack(false); err != nil {
+			log.Printf("[Data Export] Failed to ack message: %v", err)
+		}
+	}()
+
+	var event provider.DataExportRequestedEvent
+	if err := json.Unmarshal(delivery.Body, &event); err != nil {
+		log.Printf("[Data Export] Invalid message: %v", err)
+		return
+	}
+	exportID, err := uuid.Parse(event.ExportID)
+	if err != nil {
+		log.Printf("[Data Export] Invalid export id %q", event.ExportID)
+		return
+	}
+	userID, err := uuid.Parse(event.UserID)
+	if err != nil {
+		log.Printf("[Data Export] Invalid user id %q for export %s", event.UserID, exportID)
+		return
+	}
+
+	claimed, err := j.repo.ClaimDataExport(exportID)
+	if err != nil {
+		log.Printf("[Data Export] Failed to claim export %s: %v", exportID, err)
+		return
+	}
+	if !claimed {
+		// Redelivered after it was handled
+		return
+	}
+
+	if err := j.build(ctx, exportID, userID); err != nil {
+		log.Printf("[Data Export] Failed to build export %s for user %s: %v", exportID, userID, err)
+		if err := j.repo.FailDataExport(exportID, err.Error()); err != nil {
+			log.Printf("[Data Export] Failed to mark export %s as failed: %v", exportID, err)
+		}
+		return
+	}
+	log.Printf("[Data Export] Export %s ready for user %s", exportID, userID)
+}
+
+func (j *DataExportJob) build(ctx context.Context, exportID, userID uuid.UUID) error {
+	user, err := j.repo.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == nil || *user.Email == "" {
+		return fmt.Errorf("user has no email address")
+	}
+
+	document, err := j.collect(user)
+	if err != nil {
+		return err
+	}
+	archive, err := buildDataExportArchive(document)
+	if err != nil {
+		return err
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+	hash := sha256.Sum256([]byte(token))
+
+	expiresAt := time.Now().Add(j.linkTTL)
+	if err := j.repo.CompleteDataExport(exportID, archive, hex.EncodeToString(hash[:]), expiresAt); err != nil {
+		return err
+	}
+
+	recipientName := ""
+	if user.FullName != nil {
+		recipientName = *user.FullName
+	}
+	downloadLink := fmt.Sprintf("https://%s/download-my-data?token=%s", j.domain, token)
+	content := fmt.Sprintf("Xin chào %s,\n\nBản sao dữ liệu cá nhân bạn yêu cầu đã sẵn sàng. Liên kết tải xuống chỉ dùng được một lần và hết hạn vào %s.\n\nNếu bạn không thực hiện yêu cầu này, hãy đổi mật khẩu ngay.",
+		recipientName, expiresAt.Format("15:04 02/01/2006"))
+	if err := j.emails.SendEmailNotification(ctx, *user.Email, recipientName, content, downloadLink); err != nil {
+		// The link only exists in this email, so the export is of no use without it
+		return fmt.Errorf("failed to send download email: %w", err)
+	}
+	return nil
+}
+
+// Contents of data.json in the archive. Secrets (password hash, TOTP secrets, recovery codes,
+// passkey public keys) are left out.
+type dataExportDocument struct {
+	ExportedAt    time.Time                `json:"exported_at"`
+	Profile       dataExportProfile        `json:"profile"`
+	Verifications []dataExportVerification `json:"verifications"`
+	MFAs          []dataExportMFA          `json:"mfa"`
+	RecoveryCodes int64                    `json:"unused_recovery_codes"`
+	Passkeys      []dataExportPasskey      `json:"passkeys"`
+	Roles         []string                 `json:"roles"`
+	Sessions      []dataExportSession      `json:"sessions"`
+	LoginHistory  []dataExportLoginEvent   `json:"login_history"`
+	// The service records no consents yet; the key is kept so the format does not change when it does
+	Consents []any `json:"consents"`
+}
+
+type dataExportProfile struct {
+	UserID              uuid.UUID  `json:"user_id"`
+	Username            *string    `json:"username,omitempty"`
+	Email               *string    `json:"email,omitempty"`
+	Phone               *string    `json:"phone,omitempty"`
+	FullName            *string    `json:"fullname,omitempty"`
+	Gender              *string    `json:"gender,omitempty"`
+	DateOfBirth         *time.Time `json:"date_of_birth,omitempty"`
+	FacebookURL         *string    `json:"facebook_url,omitempty"`
+	GithubURL           *string    `json:"github_url,omitempty"`
+	AvatarURL           *string    `json:"avatar_url,omitempty"`
+	HasPassword         bool       `json:"has_password"`
+	Status              string     `json:"status"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+type dataExportVerification struct {
+	Method     string     `json:"method"`
+	Value      string     `json:"value"`
+	IsVerified bool       `json:"is_verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+type dataExportMFA struct {
+	Type       string     `json:"type"`
+	Enabled    bool       `json:"enabled"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type dataExportPasskey struct {
+	Name       string     `json:"name,omitempty"`
+	AAGUID     string     `json:"aaguid,omitempty"`
+	Transports string     `json:"transports,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type dataExportSession struct {
+	DeviceID    string     `json:"device_id"`
+	UserAgent   string     `json:"user_agent,omitempty"`
+	IPAddress   string     `json:"ip_address,omitempty"`
+	LoginMethod string     `json:"login_method,omitempty"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+type dataExportLoginEvent struct {
+	Method        string    `json:"method"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IPAddress     string    `json:"ip_address,omitempty"`
+	UserAgent     string    `json:"user_agent,omitempty"`
+	DeviceID      string    `json:"device_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (j *DataExportJob) collect(user *entity.User) (*dataExportDocument, error) {
+	document := &dataExportDocument{
+		ExportedAt: time.Now(),
+		Profile: dataExportProfile{
+			UserID:              user.UserID,
+			Username:            user.Username,
+			Email:               user.Email,
+			Phone:               user.Phone,
+			FullName:            user.FullName,
+			Gender:              user.Gender,
+			DateOfBirth:         user.DateOfBirth,
+			FacebookURL:         user.FacebookURL,
+			GithubURL:           user.GithubURL,
+			AvatarURL:           user.AvatarURL,
+			HasPassword:         user.Password != nil && *user.Password != "",
+			Status:              user.EffectiveStatus(time.Now()),
+			DeletionScheduledAt: user.DeletionScheduledAt,
+		},
+		Verifications: []dataExportVerification{},
+		MFAs:          []dataExportMFA{},
+		Passkeys:      []dataExportPasskey{},
+		Roles:         []string{},
+		Sessions:      []dataExportSession{},
+		LoginHistory:  []dataExportLoginEvent{},
+		Consents:      []any{},
+	}
+
+	verifications, err := j.repo.GetUserVerifications(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range verifications {
+		document.Verifications = append(document.Verifications, dataExportVerification{
+			Method:     v.Method,
+			Value:      v.Value,
+			IsVerified: v.IsVerified,
+			VerifiedAt: v.VerifiedAt,
+		})
+	}
+
+	mfas, err := j.repo.GetUserMFAs(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mfas {
+		document.MFAs = append(document.MFAs, dataExportMFA{
+			Type:       m.Type,
+			Enabled:    m.Enabled,
+			VerifiedAt: m.VerifiedAt,
+			CreatedAt:  m.CreatedAt,
+		})
+	}
+
+	if document.RecoveryCodes, err = j.repo.CountUnusedRecoveryCodes(user.UserID); err != nil {
+		return nil, err
+	}
+
+	passkeys, err := j.repo.GetWebAuthnCredentials(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range passkeys {
+		document.Passkeys = append(document.Passkeys, dataExportPasskey{
+			Name:       p.Name,
+			AAGUID:     p.AAGUID,
+			Transports: p.Transports,
+			LastUsedAt: p.LastUsedAt,
+			CreatedAt:  p.CreatedAt,
+		})
+	}
+
+	roles, err := j.repo.GetUserRoles(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		document.Roles = append(document.Roles, role.Name)
+	}
+
+	devices, err := j.repo.GetUserDevices(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range devices {
+		document.Sessions = append(document.Sessions, dataExportSession{
+			DeviceID:    d.DeviceID,
+			UserAgent:   d.UserAgent,
+			IPAddress:   d.IPAddress,
+			LoginMethod: d.LoginMethod,
+			FirstSeenAt: d.FirstSeenAt,
+			LastSeenAt:  d.LastSeenAt,
+			RevokedAt:   d.RevokedAt,
+		})
+	}
+
+	for offset := 0; ; offset += dataExportLoginPageSize {
+		events, _, err := j.repo.GetLoginEvents(user.UserID, offset, dataExportLoginPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			document.LoginHistory = append(document.LoginHistory, dataExportLoginEvent{
+				Method:        e.Method,
+				Success:       e.Success,
+				FailureReason: e.FailureReason,
+				IPAddress:     e.IPAddress,
+				UserAgent:     e.UserAgent,
+				DeviceID:      e.DeviceID,
+				CreatedAt:     e.CreatedAt,
+			})
+		}
+		if len(events) < dataExportLoginPageSize {
+			break
+		}
+	}
+
+	return document, nil
+}
+
+// buildDataExportArchive zips the document as data.json
+func buildDataExportArchive(document *dataExportDocument) ([]byte, error) {
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal export: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "data.json",
+		Method:   zip.Deflate,
+		Modified: document.ExportedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive entry: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write archive entry: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DropExpiredArchives frees the storage of exports whose link expired without being used
+func (j *DataExportJob) DropExpiredArchives() {
+	dropped, err := j.repo.DropExpiredDataExportArchives(time.Now())
+	if err != nil {
+		log.Printf("[Data Export] Failed to drop expired archives: %v", err)
+		return
+	}
+	if dropped > 0 {
+		log.Printf("[Data Export] Dropped %d expired archives", dropped)
+	}
+}
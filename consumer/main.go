@@ -5,9 +5,14 @@ import (
 	"log"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/tnqbao/gau-account-service/consumer/job"
 	"github.com/tnqbao/gau-account-service/shared/config"
+	"github.com/tnqbao/gau-account-service/shared/infra"
+	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/repository"
 )
 
 func main() {
@@ -18,6 +23,18 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	inf := infra.InitInfra(cfg)
+	defer inf.RabbitMQ.Close()
+	repo := repository.InitRepository(inf)
+
+	purgeInterval := time.Duration(cfg.EnvConfig.AccountDeletion.PurgeInterval) * time.Second
+	accountPurge := job.NewAccountPurgeJob(repo, provider.NewUploadServiceProvider(cfg.EnvConfig), provider.NewEventProducer(inf.RabbitMQ), purgeInterval)
+	go accountPurge.Run(ctx)
+
+	linkTTL := time.Duration(cfg.EnvConfig.DataExport.LinkTTL) * time.Second
+	dataExport := job.NewDataExportJob(repo, provider.NewEmailProducer(inf.RabbitMQ), inf.RabbitMQ, linkTTL, cfg.EnvConfig.CORS.DomainName)
+	go dataExport.Run(ctx)
+
 	<-ctx.Done()
 	log.Println("Shutting down consumer service gracefully...")
 }
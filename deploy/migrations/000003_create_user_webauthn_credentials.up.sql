@@ -0,0 +1,20 @@
+-- WebAuthn / passkey credentials registered by users
+CREATE TABLE user_webauthn_credentials (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    credential_id VARCHAR(1024) NOT NULL,
+    public_key BYTEA NOT NULL,
+    algorithm BIGINT NOT NULL,
+    sign_count BIGINT DEFAULT 0,
+    aaguid VARCHAR(36),
+    transports VARCHAR(255),
+    name VARCHAR(100),
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user_webauthn_credentials_user_id FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_webauthn_credentials_user_id ON user_webauthn_credentials(user_id);
+CREATE UNIQUE INDEX idx_user_webauthn_credentials_credential_id ON user_webauthn_credentials(credential_id);
@@ -0,0 +1,18 @@
+-- Devices that signed in to an account, keyed by the X-Device-ID header
+CREATE TABLE user_devices (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    device_id VARCHAR(255) NOT NULL,
+    user_agent VARCHAR(512),
+    ip_address VARCHAR(64),
+    login_method VARCHAR(30),
+    first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user_devices_user_id FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_user_devices_user_device ON user_devices(user_id, device_id);
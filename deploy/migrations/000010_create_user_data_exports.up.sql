@@ -0,0 +1,20 @@
+-- Personal data exports built by the consumer, the archive is dropped once downloaded or expired
+CREATE TABLE user_data_exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    token_hash VARCHAR(64),
+    archive BYTEA,
+    size BIGINT NOT NULL DEFAULT 0,
+    error VARCHAR(500),
+    expires_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    downloaded_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user_data_exports_user_id FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_data_exports_user_id ON user_data_exports(user_id, created_at DESC);
+CREATE UNIQUE INDEX idx_user_data_exports_token_hash ON user_data_exports(token_hash);
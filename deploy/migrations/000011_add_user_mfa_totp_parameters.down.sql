@@ -0,0 +1,3 @@
+ALTER TABLE user_mfas
+    DROP COLUMN IF EXISTS totp_algorithm,
+    DROP COLUMN IF EXISTS totp_digits;
@@ -0,0 +1,10 @@
+-- Marks accounts whose data has been purged, status deleted alone no longer means the purge ran
+ALTER TABLE users ADD COLUMN purged_at TIMESTAMP;
+
+UPDATE users SET purged_at = status_changed_at
+WHERE status = 'deleted' AND deletion_scheduled_at IS NOT NULL;
+
+-- Accounts an admin set to deleted were never purged, schedule them now
+UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, CURRENT_TIMESTAMP),
+                 deletion_scheduled_at = CURRENT_TIMESTAMP
+WHERE status = 'deleted' AND deletion_scheduled_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_purge_pending;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deletion_event_published_at,
+    DROP COLUMN IF EXISTS purge_avatar_url;
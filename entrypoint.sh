@@ -15,7 +15,16 @@ fi
 echo "Migrations completed successfully."
 
 # Start the appropriate service
-if [ "$SERVICE_TYPE" = "consumer" ]; then
+if [ "$SERVICE_TYPE" = "reencrypt-secrets" ]; then
+    echo "Re-encrypting secrets with the active key..."
+    shift
+    if [ -f "./reencrypt-secrets" ]; then
+        ./reencrypt-secrets "$@"
+    else
+        echo "Binary not found. Running with 'go run'..."
+        go run reencrypt/main.go "$@"
+    fi
+elif [ "$SERVICE_TYPE" = "consumer" ]; then
     echo "Starting Consumer service..."
     if [ -f "./consumer-service" ]; then
         ./consumer-service
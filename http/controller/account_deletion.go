@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// AccountDeletionGracePeriod is how long a deletion request can be cancelled before the consumer purges the account
+func (ctrl *Controller) AccountDeletionGracePeriod() time.Duration {
+	return time.Duration(ctrl.Config.EnvConfig.AccountDeletion.GracePeriodDays) * 24 * time.Hour
+}
+
+// CancelScheduledDeletion keeps an account whose owner signed in or followed the cancel link during the
+// grace period. It returns false when no deletion was scheduled.
+func (ctrl *Controller) CancelScheduledDeletion(ctx context.Context, user *entity.User) (bool, error) {
+	if user.DeletionScheduledAt == nil {
+		return false, nil
+	}
+
+	cancelled, err := ctrl.Repository.CancelUserDeletion(user.UserID)
+	if err != nil || !cancelled {
+		return false, err
+	}
+	user.DeletionRequestedAt = nil
+	user.DeletionScheduledAt = nil
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Account Deletion] Deletion cancelled for user: %s", user.UserID)
+
+	if user.Email != nil && *user.Email != "" {
+		content := "Yêu cầu xoá tài khoản Gauas của bạn đã được huỷ. Tài khoản và dữ liệu của bạn được giữ nguyên."
+		if err := ctrl.Provider.EmailProducer.SendEmailNotification(ctx, *user.Email, ctrl.CheckNullString(user.FullName), content, ""); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] Failed to send cancellation email for user: %s", user.UserID)
+		}
+	}
+	return true, nil
+}
+
+// RequestAccountDeletion schedules the deletion of the signed-in account after the grace period.
+// The caller re-authenticates with the password or an OTP and is signed out of every device.
+func (ctrl *Controller) RequestAccountDeletion(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Account Deletion] Deletion request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Account Deletion] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req AccountDeletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	if user.DeletionScheduledAt != nil {
+		utils.JSON409(c, "Account deletion is already scheduled")
+		return
+	}
+
+	if !ctrl.RequireReauthentication(c, user, req.Password, req.OTPCode, "Account Deletion") {
+		return
+	}
+
+	scheduledAt := time.Now().Add(ctrl.AccountDeletionGracePeriod())
+	scheduled, err := ctrl.Repository.ScheduleUserDeletion(userID, scheduledAt)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] Failed to schedule deletion for user: %s", userID.String())
+		utils.JSON500(c, "Failed to schedule account deletion")
+		return
+	}
+	if !scheduled {
+		utils.JSON409(c, "Account deletion is already scheduled")
+		return
+	}
+
+	if user.Email != nil && *user.Email != "" {
+		token, err := ctrl.Repository.CreateDeletionCancelToken(ctx, userID, time.Until(scheduledAt))
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] Failed to create cancel token for user: %s", userID.String())
+		} else {
+			recipientName := ctrl.CheckNullString(user.FullName)
+			cancelLink := fmt.Sprintf("https://%s/cancel-account-deletion?token=%s", ctrl.Config.EnvConfig.CORS.DomainName, token)
+			content := fmt.Sprintf("Xin chào %s,\n\nChúng tôi đã nhận được yêu cầu xoá tài khoản Gauas của bạn. Tài khoản và toàn bộ dữ liệu sẽ bị xoá vĩnh viễn vào ngày %s.\n\nNếu bạn đổi ý, hãy đăng nhập lại hoặc nhấp vào liên kết bên dưới trước thời điểm đó để huỷ yêu cầu. Nếu bạn không thực hiện yêu cầu này, hãy huỷ ngay và đặt lại mật khẩu.",
+				recipientName, scheduledAt.Format("02/01/2006"))
+			if err := ctrl.Provider.EmailProducer.SendEmailWarning(ctx, *user.Email, recipientName, content, cancelLink); err != nil {
+				ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] Failed to send deletion email for user: %s", userID.String())
+			}
+		}
+	}
+
+	// Signing in again cancels the deletion, so no session may stay open
+	if err := ctrl.RevokeUserSessions(ctx, userID, ""); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] Failed to revoke sessions for user: %s", userID.String())
+	}
+	if accessToken := utils.ExtractToken(c); accessToken != "" {
+		ttl := time.Duration(ctrl.Config.EnvConfig.JWT.Expire) * time.Second
+		if err := ctrl.Repository.SetAccessTokenStatus(ctx, accessToken, repository.AccessTokenRevoked, ttl); err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Account Deletion] Failed to cache access token revocation for user %s: %v", userID.String(), err)
+		}
+	}
+	ctrl.SetAccessCookie(c, "", -1)
+	ctrl.SetRefreshCookie(c, "", -1)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Account Deletion] Deletion scheduled for user: %s at %s", userID.String(), scheduledAt.Format(time.RFC3339))
+
+	utils.JSON200(c, gin.H{
+		"message":               "Account deletion scheduled, sign in again before the deletion date to cancel it",
+		"deletion_scheduled_at": scheduledAt,
+	})
+}
+
+// CancelAccountDeletion cancels a scheduled deletion through the link emailed with the request
+func (ctrl *Controller) CancelAccountDeletion(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Account Deletion] Cancel link received")
+
+	var req AccountDeletionCancelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	userID, err := ctrl.Repository.ConsumeDeletionCancelToken(ctx, req.Token)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Account Deletion] Invalid or expired cancel token")
+		utils.JSON400(c, "Invalid or expired link")
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] User not found: %s", userID.String())
+		utils.JSON400(c, "Invalid or expired link")
+		return
+	}
+
+	cancelled, err := ctrl.CancelScheduledDeletion(ctx, user)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Deletion] Failed to cancel deletion for user: %s", userID.String())
+		utils.JSON500(c, "Failed to cancel account deletion")
+		return
+	}
+
+	message := "Account deletion has been cancelled"
+	if !cancelled {
+		message = "No account deletion is scheduled"
+	}
+	utils.JSON200(c, gin.H{
+		"message":   message,
+		"cancelled": cancelled,
+	})
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+var (
+	ErrInvalidStatusTransition = errors.New("account status transition is not allowed")
+	ErrStatusChangeConflict    = errors.New("account status was changed by another request")
+)
+
+// AccountStatusError is returned when the credentials are right but the account may not sign in
+type AccountStatusError struct {
+	User   *entity.User
+	Status string
+}
+
+func (e *AccountStatusError) Error() string {
+	return fmt.Sprintf("account %s is %s", e.User.UserID, e.Status)
+}
+
+// Error codes of the statuses that block sign-in, also stored as the login failure reason
+var accountStatusCodes = map[string]string{
+	entity.UserStatusPending:   loginFailureAccountPending,
+	entity.UserStatusSuspended: loginFailureAccountSuspended,
+	entity.UserStatusBanned:    loginFailureAccountBanned,
+	entity.UserStatusDeleted:   loginFailureAccountDeleted,
+}
+
+func accountStatusCode(status string) string {
+	if code, ok := accountStatusCodes[status]; ok {
+		return code
+	}
+	return "account_inactive"
+}
+
+// CheckAccountStatus returns an *AccountStatusError unless the account is active
+func CheckAccountStatus(user *entity.User) error {
+	status := user.EffectiveStatus(time.Now())
+	if status == entity.UserStatusActive {
+		return nil
+	}
+	return &AccountStatusError{User: user, Status: status}
+}
+
+// writeAccountStatusError answers 403 with an error_code telling the client why the account cannot be used
+func writeAccountStatusError(c *gin.Context, err *AccountStatusError) {
+	message := "Account is not active"
+	switch err.Status {
+	case entity.UserStatusPending:
+		message = "Account has not been activated yet"
+	case entity.UserStatusSuspended:
+		message = "Account is suspended"
+		if err.User.SuspendedUntil != nil {
+			message = fmt.Sprintf("Account is suspended until %s", err.User.SuspendedUntil.UTC().Format(time.RFC3339))
+		}
+	case entity.UserStatusBanned:
+		message = "Account has been banned"
+	case entity.UserStatusDeleted:
+		message = "Account has been deleted"
+	}
+
+	utils.JSON403Code(c, message, accountStatusCode(err.Status))
+}
+
+// RefuseAccountStatus records a sign-in refused because of the account status and answers 403
+func (ctrl *Controller) RefuseAccountStatus(c *gin.Context, err *AccountStatusError, identifier, deviceID, method string) {
+	ctrl.Provider.LoggerProvider.WarningWithContextf(c.Request.Context(), "[Account Status] %s sign-in refused for %s user: %s, device: %s", method, err.Status, err.User.UserID, deviceID)
+	ctrl.RecordFailedLogin(c, err.User, identifier, deviceID, method, accountStatusCode(err.Status))
+	writeAccountStatusError(c, err)
+}
+
+// RejectInactiveAccount refuses the sign-in when the account is not active.
+// It returns true when the response has been written and the handler must stop.
+func (ctrl *Controller) RejectInactiveAccount(c *gin.Context, user *entity.User, identifier, deviceID, method string) bool {
+	var statusErr *AccountStatusError
+	if err := CheckAccountStatus(user); errors.As(err, &statusErr) {
+		ctrl.RefuseAccountStatus(c, statusErr, identifier, deviceID, method)
+		return true
+	}
+	return false
+}
+
+// ChangeUserStatus moves an account to a new status, signs it out of every device when it can no longer
+// be used and publishes a user.status_changed event. changedBy is uuid.Nil for changes made by the system.
+func (ctrl *Controller) ChangeUserStatus(ctx context.Context, user *entity.User, status string, reason *string, suspendedUntil *time.Time, changedBy uuid.UUID) error {
+	from := user.EffectiveStatus(time.Now())
+	if !entity.CanTransitionUserStatus(from, status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, status)
+	}
+	if status != entity.UserStatusSuspended {
+		suspendedUntil = nil
+	}
+
+	updated, err := ctrl.Repository.UpdateUserStatus(user.UserID, user.Status, status, reason, suspendedUntil)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrStatusChangeConflict
+	}
+
+	now := time.Now()
+	user.Status = status
+	user.StatusReason = reason
+	user.SuspendedUntil = suspendedUntil
+	user.StatusChangedAt = &now
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Account Status] User %s changed from %s to %s", user.UserID, from, status)
+
+	if err := ctrl.Repository.DeleteCachedUserStatus(ctx, user.UserID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Status] Failed to drop cached status for user: %s", user.UserID)
+	}
+
+	if status != entity.UserStatusActive {
+		if err := ctrl.RevokeUserSessions(ctx, user.UserID, ""); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Status] Failed to revoke sessions for user: %s", user.UserID)
+		}
+	}
+
+	event := provider.UserStatusChangedEvent{
+		UserID:         user.UserID.String(),
+		PreviousStatus: from,
+		Status:         status,
+		Reason:         ctrl.CheckNullString(reason),
+		SuspendedUntil: suspendedUntil,
+		ChangedAt:      now,
+	}
+	if changedBy != uuid.Nil {
+		event.ChangedBy = changedBy.String()
+	}
+	if err := ctrl.Provider.EventProducer.PublishUserStatusChanged(ctx, event); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Account Status] Failed to publish status change for user: %s", user.UserID)
+	}
+
+	return nil
+}
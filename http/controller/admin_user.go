@@ -0,0 +1,605 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+	"gorm.io/gorm"
+)
+
+// Actions stored in the admin audit log
+const (
+	adminActionUserUpdate       = "user.update"
+	adminActionPermissionChange = "user.permission_change"
+	adminActionEmailForceVerify = "user.email_force_verify"
+	adminActionMFAReset         = "user.mfa_reset"
+	adminActionSessionsRevoke   = "user.sessions_revoke"
+	adminActionStatusChange     = "user.status_change"
+	adminActionRoleGrant        = "role.grant"
+	adminActionRoleRevoke       = "role.revoke"
+)
+
+// Login attempts included in the user detail view
+const adminRecentLoginHistoryLength = 10
+
+// RecordAdminAction writes an admin audit log entry. targetUserID may be uuid.Nil for actions without a target user.
+func (ctrl *Controller) RecordAdminAction(c *gin.Context, adminID, targetUserID uuid.UUID, action string, details gin.H) {
+	ctx := c.Request.Context()
+
+	entry := &entity.AdminAuditLog{
+		AdminID:   adminID,
+		Action:    action,
+		IPAddress: c.ClientIP(),
+	}
+	if targetUserID != uuid.Nil {
+		entry.TargetUserID = &targetUserID
+	}
+	if details != nil {
+		if raw, err := json.Marshal(details); err == nil {
+			entry.Details = string(raw)
+		}
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Admin Audit] Admin %s performed %s on user: %s, details: %s", adminID, action, targetUserID, entry.Details)
+	if err := ctrl.Repository.CreateAdminAuditLog(entry); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin Audit] Failed to store %s by admin: %s", action, adminID)
+	}
+}
+
+// adminTarget reads the acting admin from the context and loads the user named in the path.
+// It writes the error response itself and returns ok=false when the request cannot go on.
+func (ctrl *Controller) adminTarget(c *gin.Context) (uuid.UUID, *entity.User, bool) {
+	ctx := c.Request.Context()
+
+	adminID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Admin] %v", err)
+		utils.JSON400(c, err.Error())
+		return uuid.Nil, nil, false
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid user ID")
+		return uuid.Nil, nil, false
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		utils.JSON404(c, "User not found")
+		return uuid.Nil, nil, false
+	}
+	return adminID, user, true
+}
+
+func (ctrl *Controller) adminUserSummary(user entity.User) UserBasicInfoResponse {
+	return UserBasicInfoResponse{
+		UserId:      user.UserID,
+		FullName:    ctrl.CheckNullString(user.FullName),
+		Email:       ctrl.CheckNullString(user.Email),
+		Phone:       ctrl.CheckNullString(user.Phone),
+		DateOfBirth: user.DateOfBirth,
+		AvatarURL:   ctrl.CheckNullString(user.AvatarURL),
+		GithubUrl:   ctrl.CheckNullString(user.GithubURL),
+		FacebookUrl: ctrl.CheckNullString(user.FacebookURL),
+		Username:    ctrl.CheckNullString(user.Username),
+		Gender:      ctrl.CheckNullString(user.Gender),
+		Permission:  user.Permission,
+		Status:      user.EffectiveStatus(time.Now()),
+	}
+}
+
+// AdminListUsers returns a page of users. Query: q, email, username, phone, name, permission, status, page, limit.
+func (ctrl *Controller) AdminListUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	page, limit, err := ParsePagination(c)
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	filter := repository.UserSearchFilter{
+		Query:      c.Query("q"),
+		Email:      c.Query("email"),
+		Username:   c.Query("username"),
+		Phone:      c.Query("phone"),
+		Name:       c.Query("name"),
+		Permission: c.Query("permission"),
+		Status:     c.Query("status"),
+	}
+
+	users, total, err := ctrl.Repository.SearchUsers(filter, (page-1)*limit, limit)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to search users")
+		utils.JSON500(c, "Failed to get users")
+		return
+	}
+
+	infos := make([]UserBasicInfoResponse, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, ctrl.adminUserSummary(user))
+	}
+
+	utils.JSON200(c, gin.H{
+		"users": infos,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+// AdminGetUser returns everything support needs about one account: profile, verifications, MFA
+// factors, roles, signed-in devices and the latest login attempts
+func (ctrl *Controller) AdminGetUser(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	_, user, ok := ctrl.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	verifications, err := ctrl.Repository.GetUserVerifications(user.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get verifications for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to get user")
+		return
+	}
+	mfas, err := ctrl.Repository.GetUserMFAs(user.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get MFAs for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to get user")
+		return
+	}
+	roles, err := ctrl.Repository.GetUserRoles(user.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get roles for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to get user")
+		return
+	}
+	devices, err := ctrl.Repository.GetActiveUserDevices(user.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get devices for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to get user")
+		return
+	}
+	events, _, err := ctrl.Repository.GetLoginEvents(user.UserID, 0, adminRecentLoginHistoryLength)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get login history for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to get user")
+		return
+	}
+	passkeys, err := ctrl.Repository.CountWebAuthnCredentials(user.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to count passkeys for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to get user")
+		return
+	}
+
+	info := AdminUserDetailResponse{
+		UserBasicInfoResponse: ctrl.adminUserSummary(*user),
+		StatusReason:          user.StatusReason,
+		SuspendedUntil:        user.SuspendedUntil,
+		StatusChangedAt:       user.StatusChangedAt,
+		Verifications:         make([]UserVerificationInfo, 0, len(verifications)),
+		MFAs:                  make([]UserMFAInfo, 0, len(mfas)),
+		PasskeyCount:          passkeys,
+		Roles:                 make([]RoleInfo, 0, len(roles)),
+		Sessions:              make([]UserDeviceInfo, 0, len(devices)),
+		RecentLogins:          make([]LoginEventInfo, 0, len(events)),
+	}
+	for _, verification := range verifications {
+		info.Verifications = append(info.Verifications, UserVerificationInfo{
+			ID:         verification.ID,
+			Method:     verification.Method,
+			Value:      verification.Value,
+			IsVerified: verification.IsVerified,
+			VerifiedAt: verification.VerifiedAt,
+		})
+		if verification.Method == "email" && verification.IsVerified && verification.Value == info.Email {
+			info.IsEmailVerified = true
+		}
+		if verification.Method == "phone" && verification.IsVerified && verification.Value == info.Phone {
+			info.IsPhoneVerified = true
+		}
+	}
+	for _, mfa := range mfas {
+		info.MFAs = append(info.MFAs, UserMFAInfo{
+			ID:         mfa.ID,
+			Type:       mfa.Type,
+			Enabled:    mfa.Enabled,
+			VerifiedAt: mfa.VerifiedAt,
+		})
+	}
+	for _, role := range roles {
+		info.Roles = append(info.Roles, roleInfo(role))
+	}
+	for _, device := range devices {
+		info.Sessions = append(info.Sessions, UserDeviceInfo{
+			DeviceID:    device.DeviceID,
+			UserAgent:   device.UserAgent,
+			IPAddress:   device.IPAddress,
+			LoginMethod: device.LoginMethod,
+			FirstSeenAt: device.FirstSeenAt,
+			LastSeenAt:  device.LastSeenAt,
+		})
+	}
+	for _, event := range events {
+		info.RecentLogins = append(info.RecentLogins, LoginEventInfo{
+			ID:            event.ID,
+			Method:        event.Method,
+			Success:       event.Success,
+			FailureReason: event.FailureReason,
+			IPAddress:     event.IPAddress,
+			UserAgent:     event.UserAgent,
+			DeviceID:      event.DeviceID,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+
+	utils.JSON200(c, gin.H{
+		"user_info": info,
+	})
+}
+
+// AdminUpdateUser edits profile fields of an account. The email address is changed by its owner only.
+func (ctrl *Controller) AdminUpdateUser(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, user, ok := ctrl.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	var req AdminUserUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	if req.Username != nil {
+		username := strings.TrimSpace(*req.Username)
+		if username == "" {
+			utils.JSON400(c, "Username cannot be empty")
+			return
+		}
+		if existing, err := ctrl.Repository.GetUserByIdentifier("username", username); err == nil && existing.UserID != user.UserID {
+			utils.JSON409(c, "Username is already in use")
+			return
+		}
+		req.Username = &username
+	}
+
+	if req.Phone != nil && !ctrl.IsValidPhone(*req.Phone) {
+		utils.JSON400(c, "Invalid phone format")
+		return
+	}
+
+	updateData := &entity.User{
+		UserID:      user.UserID,
+		Username:    utils.Coalesce(req.Username, user.Username),
+		FullName:    utils.Coalesce(req.FullName, user.FullName),
+		Email:       user.Email, // Keep existing email
+		Phone:       utils.Coalesce(req.Phone, user.Phone),
+		DateOfBirth: utils.Coalesce(req.DateOfBirth, user.DateOfBirth),
+		Gender:      utils.Coalesce(req.Gender, user.Gender),
+		FacebookURL: utils.Coalesce(req.FacebookURL, user.FacebookURL),
+		GithubURL:   utils.Coalesce(req.GitHubURL, user.GithubURL),
+		AvatarURL:   user.AvatarURL,
+		Permission:  user.Permission,
+	}
+
+	tx := ctrl.Repository.BeginTransaction()
+	if tx.Error != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, tx.Error, "[Admin] Failed to start transaction for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to update user")
+		return
+	}
+
+	if _, err := ctrl.Repository.UpdateUserWithTransaction(tx, updateData); err != nil {
+		tx.Rollback()
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to update user: %s", user.UserID)
+		utils.JSON500(c, "Failed to update user")
+		return
+	}
+
+	// A new number starts unverified, as in the owner's own profile update
+	phoneChanged := req.Phone != nil && (user.Phone == nil || *req.Phone != *user.Phone)
+	if phoneChanged {
+		if err := ctrl.Repository.ResetPhoneVerificationWithTransaction(tx, user.UserID, *req.Phone); err != nil {
+			tx.Rollback()
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to reset phone verification for user: %s", user.UserID)
+			utils.JSON500(c, "Failed to update user")
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to commit user update: %s", user.UserID)
+		utils.JSON500(c, "Failed to update user")
+		return
+	}
+
+	changes := gin.H{}
+	addFieldChange(changes, "username", user.Username, updateData.Username)
+	addFieldChange(changes, "fullname", user.FullName, updateData.FullName)
+	addFieldChange(changes, "phone", user.Phone, updateData.Phone)
+	addFieldChange(changes, "gender", user.Gender, updateData.Gender)
+	addFieldChange(changes, "facebook_url", user.FacebookURL, updateData.FacebookURL)
+	addFieldChange(changes, "github_url", user.GithubURL, updateData.GithubURL)
+	if !sameTime(user.DateOfBirth, updateData.DateOfBirth) {
+		changes["date_of_birth"] = gin.H{"from": user.DateOfBirth, "to": updateData.DateOfBirth}
+	}
+	ctrl.RecordAdminAction(c, adminID, user.UserID, adminActionUserUpdate, gin.H{"changes": changes})
+
+	utils.JSON200(c, gin.H{
+		"message":   "User updated successfully",
+		"user_info": ctrl.adminUserSummary(*updateData),
+	})
+}
+
+// addFieldChange records the old and new value of a field when an update changed it
+func addFieldChange[T comparable](changes gin.H, field string, from, to *T) {
+	if from == nil && to == nil || from != nil && to != nil && *from == *to {
+		return
+	}
+	changes[field] = gin.H{"from": from, "to": to}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// AdminUpdateUserPermission replaces the legacy permission string, swaps the role of the old value for the
+// role of the new one and signs the user out everywhere
+func (ctrl *Controller) AdminUpdateUserPermission(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, user, ok := ctrl.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	var req AdminPermissionUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	if user.UserID == adminID {
+		utils.JSON400(c, "You cannot change the permission of your own account")
+		return
+	}
+
+	if _, err := ctrl.Repository.GetRoleByName(req.Permission); errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSON400(c, "Unknown permission, it must name an existing role")
+		return
+	} else if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get role %s", req.Permission)
+		utils.JSON500(c, "Failed to update permission")
+		return
+	}
+
+	if err := ctrl.Repository.UpdateUserPermission(user.UserID, req.Permission); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to update permission for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to update permission")
+		return
+	}
+
+	// The old role is gone, sign the user out so no token keeps its roles and scopes claims
+	ctrl.dropCachedPermissions(ctx, user.UserID)
+	if err := ctrl.RevokeUserSessions(ctx, user.UserID, ""); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to revoke sessions for user: %s", user.UserID)
+	}
+
+	ctrl.RecordAdminAction(c, adminID, user.UserID, adminActionPermissionChange, gin.H{"from": user.Permission, "to": req.Permission})
+
+	utils.JSON200(c, gin.H{
+		"message": "Permission updated successfully",
+	})
+}
+
+// AdminVerifyUserEmail marks the current email address of an account as verified
+func (ctrl *Controller) AdminVerifyUserEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, user, ok := ctrl.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if user.Email == nil || *user.Email == "" {
+		utils.JSON400(c, "User has no email address")
+		return
+	}
+
+	if err := ctrl.Repository.MarkEmailVerified(user.UserID, *user.Email); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to verify email for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to verify email")
+		return
+	}
+
+	ctrl.RecordAdminAction(c, adminID, user.UserID, adminActionEmailForceVerify, gin.H{"email": *user.Email})
+
+	utils.JSON200(c, gin.H{
+		"message": "Email marked as verified",
+	})
+}
+
+// AdminResetUserMFA removes every second factor so the owner can sign in with the password alone
+func (ctrl *Controller) AdminResetUserMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, user, ok := ctrl.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	mfas, err := ctrl.Repository.GetUserMFAs(user.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get MFAs for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to reset MFA")
+		return
+	}
+
+	if err := ctrl.Repository.ResetUserMFA(user.UserID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to reset MFA for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to reset MFA")
+		return
+	}
+
+	removed := make([]string, 0, len(mfas))
+	for _, mfa := range mfas {
+		removed = append(removed, mfa.Type)
+		if err := ctrl.Repository.ClearTOTPStep(ctx, mfa.ID); err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Admin] Failed to clear TOTP step for MFA %s: %v", mfa.ID, err)
+		}
+	}
+
+	ctrl.RecordAdminAction(c, adminID, user.UserID, adminActionMFAReset, gin.H{"factors": removed})
+	ctrl.SendSecurityWarning(ctx, user, "Bộ phận hỗ trợ đã tắt toàn bộ xác thực hai lớp (ứng dụng xác thực, mã OTP, passkey và mã khôi phục) trên tài khoản Gauas của bạn theo yêu cầu.\n\nHãy bật lại xác thực hai lớp sau khi đăng nhập. Nếu bạn không yêu cầu thay đổi này, hãy đặt lại mật khẩu ngay lập tức.")
+
+	utils.JSON200(c, gin.H{
+		"message": "MFA reset successfully",
+	})
+}
+
+// AdminRevokeUserSessions signs an account out of every device
+func (ctrl *Controller) AdminRevokeUserSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, user, ok := ctrl.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.RevokeUserSessions(ctx, user.UserID, ""); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to revoke sessions for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to revoke sessions")
+		return
+	}
+
+	ctrl.RecordAdminAction(c, adminID, user.UserID, adminActionSessionsRevoke, nil)
+
+	utils.JSON200(c, gin.H{
+		"message": "User signed out of every device",
+	})
+}
+
+// AdminUpdateUserStatus suspends, bans, reactivates or deletes an account. Accounts that can no longer
+// be used are signed out of every device.
+func (ctrl *Controller) AdminUpdateUserStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, user, ok := ctrl.adminTarget(c)
+	if !ok {
+		return
+	}
+
+	var req AdminStatusUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	if !entity.IsValidUserStatus(req.Status) {
+		utils.JSON400(c, "Unknown status")
+		return
+	}
+	if user.UserID == adminID {
+		utils.JSON400(c, "You cannot change the status of your own account")
+		return
+	}
+	if req.SuspendedUntil != nil {
+		if req.Status != entity.UserStatusSuspended {
+			utils.JSON400(c, "suspended_until is only allowed when suspending")
+			return
+		}
+		if !req.SuspendedUntil.After(time.Now()) {
+			utils.JSON400(c, "suspended_until must be in the future")
+			return
+		}
+	}
+	if req.Reason != nil {
+		reason := strings.TrimSpace(*req.Reason)
+		if len(reason) > 500 {
+			utils.JSON400(c, "Reason must be at most 500 characters")
+			return
+		}
+		req.Reason = &reason
+		if reason == "" {
+			req.Reason = nil
+		}
+	}
+
+	from := user.EffectiveStatus(time.Now())
+	if err := ctrl.ChangeUserStatus(ctx, user, req.Status, req.Reason, req.SuspendedUntil, adminID); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidStatusTransition):
+			utils.JSON409(c, "Cannot change status from "+from+" to "+req.Status)
+		case errors.Is(err, ErrStatusChangeConflict):
+			utils.JSON409(c, "Status was changed by another request, please reload the user")
+		default:
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to change status of user: %s", user.UserID)
+			utils.JSON500(c, "Failed to change status")
+		}
+		return
+	}
+
+	ctrl.RecordAdminAction(c, adminID, user.UserID, adminActionStatusChange, gin.H{
+		"from":            from,
+		"to":              req.Status,
+		"reason":          req.Reason,
+		"suspended_until": req.SuspendedUntil,
+	})
+
+	utils.JSON200(c, gin.H{
+		"message":   "Status updated successfully",
+		"user_info": ctrl.adminUserSummary(*user),
+	})
+}
+
+// AdminListAuditLogs returns the admin audit log, newest first. Query: target_user_id, page, limit.
+func (ctrl *Controller) AdminListAuditLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	page, limit, err := ParsePagination(c)
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var targetUserID *uuid.UUID
+	if raw := c.Query("target_user_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			utils.JSON400(c, "Invalid target_user_id")
+			return
+		}
+		targetUserID = &id
+	}
+
+	logs, total, err := ctrl.Repository.GetAdminAuditLogs(targetUserID, (page-1)*limit, limit)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Admin] Failed to get audit logs")
+		utils.JSON500(c, "Failed to get audit logs")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"logs":  logs,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
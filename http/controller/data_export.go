@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// An export still pending after this long is assumed lost and no longer blocks a new request
+const dataExportStaleAfter = time.Hour
+
+func dataExportInfo(export *entity.UserDataExport) DataExportInfo {
+	return DataExportInfo{
+		ID:           export.ID,
+		Status:       export.Status,
+		Size:         export.Size,
+		CreatedAt:    export.CreatedAt,
+		CompletedAt:  export.CompletedAt,
+		ExpiresAt:    export.ExpiresAt,
+		DownloadedAt: export.DownloadedAt,
+	}
+}
+
+// RequestDataExport queues a copy of the signed-in user's personal data. The consumer builds the
+// archive and emails a single-use download link.
+func (ctrl *Controller) RequestDataExport(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Data Export] Export request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Data Export] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Data Export] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+	if user.Email == nil || *user.Email == "" {
+		utils.JSON400(c, "An email address is required to receive the download link")
+		return
+	}
+
+	latest, err := ctrl.Repository.GetLatestDataExport(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Data Export] Failed to get latest export for user: %s", userID.String())
+		utils.JSON500(c, "Failed to request data export")
+		return
+	}
+	if latest != nil {
+		age := time.Since(latest.CreatedAt)
+		switch latest.Status {
+		case entity.DataExportPending, entity.DataExportProcessing:
+			if age < dataExportStaleAfter {
+				utils.JSON409(c, "A data export is already being prepared")
+				return
+			}
+		case entity.DataExportReady:
+			interval := time.Duration(ctrl.Config.EnvConfig.DataExport.RequestInterval) * time.Second
+			if age < interval {
+				utils.JSON429(c, "A data export was requested recently, please try again later", secondsCeil(interval-age))
+				return
+			}
+		}
+	}
+
+	export := &entity.UserDataExport{
+		UserID: userID,
+		Status: entity.DataExportPending,
+	}
+	if err := ctrl.Repository.CreateDataExport(export); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Data Export] Failed to create export for user: %s", userID.String())
+		utils.JSON500(c, "Failed to request data export")
+		return
+	}
+
+	event := provider.DataExportRequestedEvent{
+		ExportID:    export.ID.String(),
+		UserID:      userID.String(),
+		RequestedAt: export.CreatedAt,
+	}
+	if err := ctrl.Provider.EventProducer.PublishDataExportRequested(ctx, event); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Data Export] Failed to queue export %s for user: %s", export.ID, userID.String())
+		if err := ctrl.Repository.FailDataExport(export.ID, "could not be queued"); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Data Export] Failed to mark export %s as failed", export.ID)
+		}
+		utils.JSON500(c, "Failed to request data export")
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Data Export] Export %s queued for user: %s", export.ID, userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message": "Your data export is being prepared, a download link will be sent by email",
+		"export":  dataExportInfo(export),
+	})
+}
+
+// GetDataExportStatus returns the latest export of the signed-in user
+func (ctrl *Controller) GetDataExportStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Data Export] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	latest, err := ctrl.Repository.GetLatestDataExport(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Data Export] Failed to get latest export for user: %s", userID.String())
+		utils.JSON500(c, "Failed to get data export")
+		return
+	}
+	if latest == nil {
+		utils.JSON404(c, "No data export has been requested")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"export": dataExportInfo(latest),
+	})
+}
+
+// DownloadDataExport serves the archive behind an emailed link. The link works once and until it expires.
+// It is a POST so that mail scanners and link previews fetching the page do not use up the link.
+func (ctrl *Controller) DownloadDataExport(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Data Export] Download received")
+
+	var req DataExportDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	export, err := ctrl.Repository.ConsumeDataExportDownload(ctrl.hashToken(req.Token))
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Data Export] Failed to load export for download")
+		utils.JSON500(c, "Failed to download data export")
+		return
+	}
+	if export == nil || len(export.Archive) == 0 {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Data Export] Invalid, expired or used download token")
+		utils.JSON404(c, "Invalid, expired or already used download link")
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Data Export] Export %s downloaded by user: %s", export.ID, export.UserID)
+
+	filename := fmt.Sprintf("gauas-data-export-%s.zip", export.CreatedAt.Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(200, "application/zip", export.Archive)
+}
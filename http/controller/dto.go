@@ -39,6 +39,7 @@ type UserBasicInfoResponse struct {
 	Username    string     `json:"username,omitempty"`
 	Gender      string     `json:"gender,omitempty"`
 	Permission  string     `json:"permission,omitempty"`
+	Status      string     `json:"status,omitempty"`
 }
 
 // User security information response structure (verification & MFA only)
@@ -132,14 +133,234 @@ type UserCompleteInfoUpdateReq struct {
 
 // Client request structure for google login
 type ClientRequestGoogleAuthentication struct {
-	Token string `json:"token" binding:"required"`
+	Token     string  `json:"token" binding:"required"`
+	KeepLogin *string `json:"keepMeLogin,omitempty"`
 }
 
 type TOTPEnableRequest struct {
 	OTPCode string `json:"otp_code" binding:"required"`
 }
 
-type TOTPVerifyRequest struct {
-	OTPCode  string `json:"otp_code" binding:"required"`
-	DeviceID string `json:"device_id,omitempty"`
+// Forgot password request structure (email or phone)
+type PasswordResetRequest struct {
+	Email *string `json:"email,omitempty"`
+	Phone *string `json:"phone,omitempty"`
+}
+
+// Password reset confirmation request structure
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// Email change request structure
+type EmailChangeRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// Confirmation or revert of an email change
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// Self-service account deletion, confirmed with the current password or a valid OTP
+type AccountDeletionRequest struct {
+	Password *string `json:"password,omitempty"`
+	OTPCode  *string `json:"otp_code,omitempty"`
+}
+
+// Cancel link of a scheduled account deletion
+type AccountDeletionCancelRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type DataExportDownloadRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// State of a personal data export; the archive itself is only sent through the emailed link
+type DataExportInfo struct {
+	ID           uuid.UUID  `json:"id"`
+	Status       string     `json:"status"`
+	Size         int64      `json:"size,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty"`
+}
+
+// Passwordless login request structure. Mode is "link" (default) or "code".
+type MagicLoginSendRequest struct {
+	Email string `json:"email" binding:"required"`
+	Mode  string `json:"mode,omitempty"`
+}
+
+// Passwordless login redemption, either the link token or the email and code
+type MagicLoginVerifyRequest struct {
+	Token     *string `json:"token,omitempty"`
+	Email     *string `json:"email,omitempty"`
+	Code      *string `json:"code,omitempty"`
+	KeepLogin *string `json:"keepMeLogin,omitempty"`
+}
+
+// Change password request structure. An account without a password sends OTPCode instead of CurrentPassword:
+// the code emailed by /profile/password/code or one from an enabled MFA factor.
+type ChangePasswordRequest struct {
+	CurrentPassword     *string `json:"current_password,omitempty"`
+	OTPCode             *string `json:"otp_code,omitempty"`
+	NewPassword         string  `json:"new_password" binding:"required"`
+	SignOutOtherDevices *bool   `json:"sign_out_other_devices,omitempty"`
+}
+
+// Second step of an MFA login. Factor defaults to the first factor listed in the challenge.
+// Passkeys (factor "webauthn") send the assertion and the session ID from /mfa/challenge/webauthn instead of a code.
+type MFAChallengeCompleteRequest struct {
+	ChallengeID string                       `json:"challenge_id" binding:"required"`
+	Factor      string                       `json:"factor,omitempty"`
+	Code        string                       `json:"code,omitempty"`
+	SessionID   string                       `json:"session_id,omitempty"`
+	Credential  *WebAuthnAssertionCredential `json:"credential,omitempty"`
+}
+
+// Re-authentication for sensitive MFA changes: either the current password or a valid OTP
+type MFAReauthRequest struct {
+	Password *string `json:"password,omitempty"`
+	OTPCode  *string `json:"otp_code,omitempty"`
+}
+
+// Request a one-time code for a factor of a pending MFA challenge, e.g. "email_otp" or "sms_otp"
+type MFAChallengeSendRequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+	Factor      string `json:"factor" binding:"required"`
+}
+
+// Response of navigator.credentials.create(); binary fields are base64url encoded
+type WebAuthnAttestationCredential struct {
+	ID       string `json:"id" binding:"required"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject" binding:"required"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// Response of navigator.credentials.get(); binary fields are base64url encoded
+type WebAuthnAssertionCredential struct {
+	ID       string `json:"id" binding:"required"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type WebAuthnRegisterFinishRequest struct {
+	SessionID  string                        `json:"session_id" binding:"required"`
+	Name       string                        `json:"name,omitempty"`
+	Credential WebAuthnAttestationCredential `json:"credential"`
+}
+
+// Passkey login start. Identifiers are optional; without them the browser offers discoverable passkeys.
+type WebAuthnLoginBeginRequest struct {
+	Username *string `json:"username,omitempty"`
+	Email    *string `json:"email,omitempty"`
+	Phone    *string `json:"phone,omitempty"`
+}
+
+type WebAuthnLoginFinishRequest struct {
+	SessionID  string                      `json:"session_id" binding:"required"`
+	Credential WebAuthnAssertionCredential `json:"credential"`
+	KeepLogin  *string                     `json:"keepMeLogin,omitempty"`
+}
+
+type WebAuthnMFABeginRequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+}
+
+type WebAuthnCredentialRenameRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// Registered passkey information for response
+type WebAuthnCredentialInfo struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Transports []string   `json:"transports,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Signed-in device information for response
+type UserDeviceInfo struct {
+	DeviceID    string    `json:"device_id"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	IPAddress   string    `json:"ip_address,omitempty"`
+	LoginMethod string    `json:"login_method,omitempty"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	Current     bool      `json:"current"`
+}
+
+// Login history entry for response
+type LoginEventInfo struct {
+	ID            uuid.UUID `json:"id"`
+	Method        string    `json:"method"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IPAddress     string    `json:"ip_address,omitempty"`
+	UserAgent     string    `json:"user_agent,omitempty"`
+	DeviceID      string    `json:"device_id,omitempty"`
+	CurrentDevice bool      `json:"current_device"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type RoleGrantRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// Role information for response
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// Profile fields support staff may change; email stays with the owner's own change flow
+type AdminUserUpdateRequest struct {
+	FullName    *string    `json:"fullname,omitempty"`
+	Username    *string    `json:"username,omitempty"`
+	Phone       *string    `json:"phone,omitempty"`
+	Gender      *string    `json:"gender,omitempty"`
+	DateOfBirth *time.Time `json:"date_of_birth,omitempty"`
+	FacebookURL *string    `json:"facebook_url,omitempty"`
+	GitHubURL   *string    `json:"github_url,omitempty"`
+}
+
+type AdminPermissionUpdateRequest struct {
+	Permission string `json:"permission" binding:"required"`
+}
+
+type AdminStatusUpdateRequest struct {
+	Status         string     `json:"status" binding:"required"`
+	Reason         *string    `json:"reason"`
+	SuspendedUntil *time.Time `json:"suspended_until"` // only for "suspended", omit for an indefinite suspension
+}
+
+// Account detail for support staff
+type AdminUserDetailResponse struct {
+	UserBasicInfoResponse
+	StatusReason    *string                `json:"status_reason,omitempty"`
+	SuspendedUntil  *time.Time             `json:"suspended_until,omitempty"`
+	StatusChangedAt *time.Time             `json:"status_changed_at,omitempty"`
+	IsEmailVerified bool                   `json:"is_email_verified"`
+	IsPhoneVerified bool                   `json:"is_phone_verified"`
+	Verifications   []UserVerificationInfo `json:"verifications"`
+	MFAs            []UserMFAInfo          `json:"mfas"`
+	PasskeyCount    int64                  `json:"passkey_count"`
+	Roles           []RoleInfo             `json:"roles"`
+	Sessions        []UserDeviceInfo       `json:"sessions"`
+	RecentLogins    []LoginEventInfo       `json:"recent_logins"`
 }
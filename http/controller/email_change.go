@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+var ErrEmailInUse = errors.New("email is already in use")
+
+// IsEmailTaken reports whether another account already uses the address
+func (ctrl *Controller) IsEmailTaken(userID uuid.UUID, email string) bool {
+	owner, err := ctrl.Repository.GetUserByEmail(email)
+	return err == nil && owner.UserID != userID
+}
+
+// RequestEmailChange records a pending change to newEmail, mails a confirmation link to the new
+// address and a revert link to the current one. User.Email is left untouched until confirmation.
+func (ctrl *Controller) RequestEmailChange(ctx context.Context, user *entity.User, newEmail string) error {
+	newEmail = strings.TrimSpace(newEmail)
+	if ctrl.IsEmailTaken(user.UserID, newEmail) {
+		return ErrEmailInUse
+	}
+
+	oldEmail := ctrl.CheckNullString(user.Email)
+	confirmToken, revertToken, err := ctrl.Repository.CreateEmailChange(ctx, &repository.EmailChange{
+		UserID:   user.UserID.String(),
+		OldEmail: oldEmail,
+		NewEmail: newEmail,
+	})
+	if err != nil {
+		return err
+	}
+
+	recipientName := ctrl.CheckNullString(user.FullName)
+	if recipientName == "" {
+		recipientName = ctrl.CheckNullString(user.Username)
+	}
+	domain := ctrl.Config.EnvConfig.CORS.DomainName
+
+	confirmLink := fmt.Sprintf("https://%s/confirm-email-change?token=%s", domain, confirmToken)
+	confirmContent := fmt.Sprintf("Xin chào %s,\n\nChúng tôi nhận được yêu cầu dùng địa chỉ email này cho tài khoản Gauas của bạn. Nhấp vào liên kết bên dưới để xác nhận. Liên kết sẽ hết hạn sau %d giờ.\n\nNếu bạn không yêu cầu thay đổi này, hãy bỏ qua email này.",
+		recipientName, int(ctrl.Repository.EmailChangeConfirmTTL().Hours()))
+	if err := ctrl.Provider.EmailProducer.SendEmailConfirmation(ctx, newEmail, recipientName, confirmContent, confirmLink); err != nil {
+		return fmt.Errorf("failed to send confirmation email: %w", err)
+	}
+
+	if oldEmail != "" {
+		revertLink := fmt.Sprintf("https://%s/revert-email-change?token=%s", domain, revertToken)
+		revertContent := fmt.Sprintf("Xin chào %s,\n\nCó yêu cầu đổi email đăng nhập tài khoản Gauas của bạn sang %s.\n\nNếu bạn không thực hiện thay đổi này, hãy nhấp vào liên kết bên dưới trong vòng %d ngày để hủy hoặc hoàn tác thay đổi. Mọi phiên đăng nhập sẽ bị đăng xuất.",
+			recipientName, newEmail, int(math.Round(ctrl.Repository.EmailChangeRevertTTL().Hours()/24)))
+		if err := ctrl.Provider.EmailProducer.SendEmailWarning(ctx, oldEmail, recipientName, revertContent, revertLink); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Email Change] Failed to notify old address for user: %s", user.UserID)
+		}
+	}
+
+	return nil
+}
+
+// ChangeEmail starts an email change for the signed-in user
+func (ctrl *Controller) ChangeEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Email Change] Change request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Email Change] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req EmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	newEmail := strings.TrimSpace(req.Email)
+	if !ctrl.IsValidEmail(newEmail) {
+		utils.JSON400(c, "Invalid email format")
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Email Change] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	if user.Email != nil && *user.Email == newEmail {
+		utils.JSON400(c, "This is already your email address")
+		return
+	}
+
+	if err := ctrl.RequestEmailChange(ctx, user, newEmail); err != nil {
+		if errors.Is(err, ErrEmailInUse) {
+			utils.JSON409(c, "Email is already in use")
+			return
+		}
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Email Change] Failed to start email change for user: %s", userID.String())
+		utils.JSON500(c, "Failed to start email change")
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Email Change] Confirmation sent for user: %s", userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message":       "A confirmation link has been sent to the new email address",
+		"pending_email": newEmail,
+		"expires_in":    int(ctrl.Repository.EmailChangeConfirmTTL().Seconds()),
+	})
+}
+
+// ConfirmEmailChange applies a pending change once the new address has followed its link
+func (ctrl *Controller) ConfirmEmailChange(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Email Change] Confirmation received")
+
+	var req EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	change, err := ctrl.Repository.ConsumeEmailChangeConfirmation(ctx, req.Token)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Email Change] Invalid or expired confirmation token")
+		utils.JSON400(c, "Invalid or expired confirmation link")
+		return
+	}
+
+	userID, err := uuid.Parse(change.UserID)
+	if err != nil {
+		utils.JSON400(c, "Invalid or expired confirmation link")
+		return
+	}
+
+	if ctrl.IsEmailTaken(userID, change.NewEmail) {
+		utils.JSON409(c, "Email is already in use")
+		return
+	}
+
+	if err := ctrl.Repository.SwapUserEmail(userID, change.OldEmail, change.NewEmail); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Email Change] Failed to apply email change for user: %s", change.UserID)
+		utils.JSON409(c, "The account email changed in the meantime, please request the change again")
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Email Change] Email changed for user: %s", change.UserID)
+
+	utils.JSON200(c, gin.H{
+		"message": "Email address updated successfully",
+		"email":   change.NewEmail,
+	})
+}
+
+// RevertEmailChange lets the previous address cancel a pending change or undo a confirmed one.
+// Undoing a change signs the account out everywhere since the session that made it may be stolen.
+func (ctrl *Controller) RevertEmailChange(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Email Change] Revert received")
+
+	var req EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	change, err := ctrl.Repository.ConsumeEmailChangeRevert(ctx, req.Token)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Email Change] Invalid or expired revert token")
+		utils.JSON400(c, "Invalid or expired link")
+		return
+	}
+
+	userID, err := uuid.Parse(change.UserID)
+	if err != nil {
+		utils.JSON400(c, "Invalid or expired link")
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Email Change] User not found: %s", change.UserID)
+		utils.JSON400(c, "Invalid or expired link")
+		return
+	}
+
+	reverted := false
+	if user.Email != nil && *user.Email == change.NewEmail {
+		if ctrl.IsEmailTaken(userID, change.OldEmail) {
+			utils.JSON409(c, "The previous email address is now used by another account")
+			return
+		}
+		if err := ctrl.Repository.SwapUserEmail(userID, change.NewEmail, change.OldEmail); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Email Change] Failed to revert email change for user: %s", change.UserID)
+			utils.JSON500(c, "Failed to revert email change")
+			return
+		}
+		reverted = true
+
+		if err := ctrl.RevokeUserSessions(ctx, userID, ""); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Email Change] Failed to revoke sessions for user: %s", change.UserID)
+		}
+		user.Email = &change.OldEmail
+		ctrl.SendSecurityWarning(ctx, user, fmt.Sprintf("Email tài khoản Gauas của bạn đã được khôi phục về địa chỉ này và mọi phiên đăng nhập đã bị đăng xuất. Địa chỉ %s không còn được liên kết với tài khoản.\n\nHãy đặt lại mật khẩu ngay để bảo vệ tài khoản.", change.NewEmail))
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Email Change] Email change for user %s cancelled (reverted: %v)", change.UserID, reverted)
+
+	message := "The pending email change has been cancelled"
+	if reverted {
+		message = "Your previous email address has been restored and all sessions were signed out"
+	}
+	utils.JSON200(c, gin.H{
+		"message":  message,
+		"reverted": reverted,
+	})
+}
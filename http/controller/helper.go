@@ -1,55 +1,448 @@
 package controller
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/mozillazg/go-unidecode"
 	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+	"github.com/tnqbao/gau-account-service/shared/utils"
 	"gorm.io/gorm"
 )
 
+// setAuthCookie writes an HttpOnly cookie with the configured domain, path, Secure and SameSite.
+// A maxAge of 0 makes it a session cookie that the browser drops when it closes.
+func (ctrl *Controller) setAuthCookie(c *gin.Context, name, value string, maxAge int) {
+	cookieConfig := ctrl.Config.EnvConfig.Cookie
+
+	sameSite := http.SameSiteLaxMode
+	switch cookieConfig.SameSite {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		MaxAge:   maxAge,
+		Path:     cookieConfig.Path,
+		Domain:   cookieConfig.Domain,
+		Secure:   cookieConfig.Secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
 func (ctrl *Controller) SetAccessCookie(c *gin.Context, token string, timeExpired int) {
-	globalDomain := ctrl.Config.EnvConfig.CORS.GlobalDomain
-	c.SetCookie("access_token", token, timeExpired, "/", globalDomain, false, true)
+	ctrl.setAuthCookie(c, "access_token", token, timeExpired)
 }
 
 func (ctrl *Controller) SetRefreshCookie(c *gin.Context, token string, timeExpired int) {
-	globalDomain := ctrl.Config.EnvConfig.CORS.GlobalDomain
-	c.SetCookie("refresh_token", token, timeExpired, "/", globalDomain, false, true)
+	ctrl.setAuthCookie(c, "refresh_token", token, timeExpired)
+}
+
+// SetSessionCookies stores a fresh token pair. Without keep-login both cookies only live for the browser session.
+func (ctrl *Controller) SetSessionCookies(c *gin.Context, accessToken string, expiresIn int, refreshToken string, keepLogin bool) {
+	if !keepLogin {
+		ctrl.SetAccessCookie(c, accessToken, 0)
+		ctrl.SetRefreshCookie(c, refreshToken, 0)
+		return
+	}
+	ctrl.SetAccessCookie(c, accessToken, expiresIn)
+	ctrl.SetRefreshCookie(c, refreshToken, int(ctrl.RefreshTokenTTL(true).Seconds()))
+}
+
+// ParseKeepLogin reads the "keepMeLogin" flag sent by clients as a string
+func ParseKeepLogin(value *string) bool {
+	if value == nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(*value)) {
+	case "true", "1", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+// RefreshTokenTTL returns the refresh token lifetime requested from the authorization service
+func (ctrl *Controller) RefreshTokenTTL(keepLogin bool) time.Duration {
+	if keepLogin {
+		return time.Duration(ctrl.Config.EnvConfig.Session.RememberTTL) * time.Second
+	}
+	return time.Duration(ctrl.Config.EnvConfig.Session.DefaultTTL) * time.Second
+}
+
+const (
+	paginationDefaultLimit = 20
+	paginationMaxLimit     = 100
+)
+
+// ParsePagination reads the page (from 1) and limit query parameters, capping limit at paginationMaxLimit
+func ParsePagination(c *gin.Context) (int, int, error) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, fmt.Errorf("invalid page")
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(paginationDefaultLimit)))
+	if err != nil || limit < 1 {
+		return 0, 0, fmt.Errorf("invalid limit")
+	}
+	if limit > paginationMaxLimit {
+		limit = paginationMaxLimit
+	}
+	return page, limit, nil
+}
+
+// GetUserIDFromContext returns the authenticated user ID injected by AuthMiddleware
+func (ctrl *Controller) GetUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
+	userID, exists := c.Get("user_id")
+	if !exists || userID == nil {
+		return uuid.Nil, fmt.Errorf("user ID is required")
+	}
+
+	switch v := userID.(type) {
+	case string:
+		parsed, err := uuid.Parse(v)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("invalid user ID format")
+		}
+		return parsed, nil
+	case uuid.UUID:
+		return v, nil
+	default:
+		return uuid.Nil, fmt.Errorf("invalid user ID type")
+	}
 }
 
 func isValidLoginRequest(req ClientRequestBasicLogin) bool {
 	return req.Password != nil && (req.Username != nil || req.Email != nil || req.Phone != nil)
 }
 
-func (ctrl *Controller) HashPassword(password string) string {
-	hasher := sha256.New()
-	hasher.Write([]byte(password))
-	return hex.EncodeToString(hasher.Sum(nil))
+// HashPassword hashes a password with the configured algorithm (Argon2id by default)
+func (ctrl *Controller) HashPassword(password string) (string, error) {
+	return utils.NewPasswordHasher(ctrl.Config.EnvConfig).Hash(password)
 }
 
-func (ctrl *Controller) AuthenticateUser(req *ClientRequestBasicLogin, c *gin.Context) (*entity.User, error) {
-	hashedPassword := ctrl.HashPassword(*req.Password)
+// VerifyPassword checks a password against a stored hash and reports whether the hash should be upgraded
+func (ctrl *Controller) VerifyPassword(password, hashedPassword string) (bool, bool, error) {
+	return utils.NewPasswordHasher(ctrl.Config.EnvConfig).Verify(password, hashedPassword)
+}
 
+// LookupLoginUser finds the account a password login request refers to, without checking the password
+func (ctrl *Controller) LookupLoginUser(req *ClientRequestBasicLogin) (*entity.User, error) {
 	if req.Username != nil {
-		return ctrl.Repository.GetUserByIdentifierAndPassword("username", *req.Username, hashedPassword)
+		return ctrl.Repository.GetUserByIdentifier("username", *req.Username)
 	} else if req.Email != nil {
-		return ctrl.Repository.GetUserByIdentifierAndPassword("email", *req.Email, hashedPassword)
+		return ctrl.Repository.GetUserByIdentifier("email", *req.Email)
 	} else if req.Phone != nil {
-		return ctrl.Repository.GetUserByIdentifierAndPassword("phone", *req.Phone, hashedPassword)
+		return ctrl.Repository.GetUserByIdentifier("phone", *req.Phone)
 	}
 	return nil, fmt.Errorf("missing login identifier")
 }
 
+func (ctrl *Controller) AuthenticateUser(req *ClientRequestBasicLogin, c *gin.Context) (*entity.User, error) {
+	ctx := c.Request.Context()
+
+	user, err := ctrl.LookupLoginUser(req)
+	if err != nil {
+		utils.NewPasswordHasher(ctrl.Config.EnvConfig).VerifyDummy(*req.Password)
+		return nil, err
+	}
+
+	if user.Password == nil || *user.Password == "" {
+		utils.NewPasswordHasher(ctrl.Config.EnvConfig).VerifyDummy(*req.Password)
+		return nil, fmt.Errorf("user %s has no password set", user.UserID)
+	}
+
+	match, needsRehash, err := ctrl.VerifyPassword(*req.Password, *user.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !match {
+		return nil, fmt.Errorf("invalid password for user %s", user.UserID)
+	}
+
+	// Upgrade legacy SHA-256 hashes and hashes with outdated parameters transparently
+	if needsRehash {
+		newHash, err := ctrl.HashPassword(*req.Password)
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Auth] Failed to rehash password for user: %s", user.UserID)
+		} else if err := ctrl.Repository.UpdateUserPassword(user.UserID, newHash); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Auth] Failed to store rehashed password for user: %s", user.UserID)
+		} else {
+			user.Password = &newHash
+			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Auth] Password hash upgraded for user: %s", user.UserID)
+		}
+	}
+
+	// Checked after the password so the status of an account is only revealed to its owner
+	if err := CheckAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ValidatePasswordPolicy checks a new password against the configured password policy
+func (ctrl *Controller) ValidatePasswordPolicy(password string) error {
+	policy := ctrl.Config.EnvConfig.Password
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters", policy.MinLength)
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		return fmt.Errorf("password must be at most %d characters", policy.MaxLength)
+	}
+
+	var hasLetter, hasDigit bool
+	for _, char := range password {
+		switch {
+		case unicode.IsLetter(char):
+			hasLetter = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("password must contain both letters and digits")
+	}
+	return nil
+}
+
+// CreateUserSession issues tokens for a device and records the session so it can be revoked later.
+// method is how the user signed in ("password", "google", "passkey", ...) and is kept in the device registry.
+func (ctrl *Controller) CreateUserSession(c *gin.Context, user *entity.User, deviceID, method string, keepLogin bool) (string, string, int, error) {
+	ctx := c.Request.Context()
+	access, err := ctrl.ResolveTokenAccess(user)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	accessToken, refreshToken, expiresAt, err := ctrl.Provider.AuthorizationServiceProvider.CreateNewToken(user.UserID, access, deviceID, ctrl.RefreshTokenTTL(keepLogin))
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	// The tokens are valid even if the index write fails, the device just cannot be signed out remotely
+	if err := ctrl.saveSession(ctx, user.UserID, deviceID, refreshToken, keepLogin); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to record session for user: %s, device: %s", user.UserID, deviceID)
+	}
+
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	ctrl.RecordSuccessfulLogin(c, user, deviceID, method)
+
+	// Signing in during the grace period keeps the account
+	if _, err := ctrl.CancelScheduledDeletion(ctx, user); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to cancel scheduled deletion for user: %s", user.UserID)
+	}
+
+	device := &entity.UserDevice{
+		UserID:      user.UserID,
+		DeviceID:    deviceID,
+		UserAgent:   userAgent,
+		IPAddress:   c.ClientIP(),
+		LoginMethod: method,
+	}
+	if err := ctrl.Repository.RecordUserDeviceLogin(device); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to record device for user: %s, device: %s", user.UserID, deviceID)
+	}
+
+	return accessToken, refreshToken, int(time.Until(expiresAt).Seconds()), nil
+}
+
+// saveSession records the refresh token of a device in the session index, hashed and sealed
+func (ctrl *Controller) saveSession(ctx context.Context, userID uuid.UUID, deviceID, refreshToken string, keepLogin bool) error {
+	secretCipher, err := utils.NewSecretCipher(ctrl.Config.EnvConfig)
+	if err != nil {
+		return err
+	}
+	sealed, err := secretCipher.Encrypt(refreshToken, utils.SessionTokenAssociatedData(userID.String(), deviceID))
+	if err != nil {
+		return err
+	}
+	return ctrl.Repository.SaveUserSession(ctx, userID.String(), deviceID, repository.UserSession{
+		TokenHash:   repository.HashSessionToken(refreshToken),
+		SealedToken: sealed,
+		KeepLogin:   keepLogin,
+		ExpiresAt:   time.Now().Add(ctrl.RefreshTokenTTL(keepLogin)),
+	})
+}
+
+// revokeSessionToken opens the refresh token of an indexed session and revokes it at the authorization service
+func (ctrl *Controller) revokeSessionToken(userID uuid.UUID, deviceID string, session *repository.UserSession) error {
+	secretCipher, err := utils.NewSecretCipher(ctrl.Config.EnvConfig)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := secretCipher.Decrypt(session.SealedToken, utils.SessionTokenAssociatedData(userID.String(), deviceID))
+	if err != nil {
+		return fmt.Errorf("failed to open session token: %w", err)
+	}
+	return ctrl.Provider.AuthorizationServiceProvider.RevokeToken(refreshToken, deviceID)
+}
+
+// RevokeUserSessions signs a user out of every recorded device except keepDeviceID (pass "" to revoke all)
+func (ctrl *Controller) RevokeUserSessions(ctx context.Context, userID uuid.UUID, keepDeviceID string) error {
+	sessions, err := ctrl.Repository.GetUserSessions(ctx, userID.String())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for deviceID, session := range sessions {
+		if keepDeviceID != "" && deviceID == keepDeviceID {
+			continue
+		}
+		if err := ctrl.revokeSessionToken(userID, deviceID, &session); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to revoke token for user: %s, device: %s", userID, deviceID)
+			errs = append(errs, err)
+			continue
+		}
+		if err := ctrl.Repository.DeleteUserSession(ctx, userID.String(), deviceID); err != nil {
+			errs = append(errs, err)
+		}
+		if err := ctrl.Repository.MarkUserDeviceRevoked(userID, deviceID); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to mark device revoked for user: %s, device: %s", userID, deviceID)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ErrReauthLocked is returned by ReauthenticateUser while too many recent attempts of the user have failed
+var ErrReauthLocked = errors.New("too many failed verification attempts, try again later")
+
+// ErrReauthMissing is returned by ReauthenticateUser when the request carries neither a password nor a code
+var ErrReauthMissing = errors.New("password or OTP code is required")
+
+// ReauthenticateUser confirms the caller still controls the account with either the current password or a code from an enabled MFA factor.
+// Failures are counted per user and lock re-authentication with the login lock policy.
+func (ctrl *Controller) ReauthenticateUser(ctx context.Context, user *entity.User, password, otpCode *string) (bool, error) {
+	hasPassword := password != nil && *password != ""
+	hasOTP := otpCode != nil && *otpCode != ""
+	if !hasPassword && !hasOTP {
+		return false, ErrReauthMissing
+	}
+
+	userID := user.UserID.String()
+	if lock, err := ctrl.Repository.GetReauthLock(ctx, userID); err != nil {
+		return false, err
+	} else if lock > 0 {
+		return false, ErrReauthLocked
+	}
+
+	ok, err := ctrl.verifyReauthentication(ctx, user, password, otpCode)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		if err := ctrl.Repository.ClearReauthFailures(ctx, userID); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Reauth] Failed to clear failures for user: %s", userID)
+		}
+		return true, nil
+	}
+
+	locked, err := ctrl.Repository.RecordReauthFailure(ctx, userID, ctrl.LoginThrottlePolicy())
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Reauth] Failed to record failure for user: %s", userID)
+	}
+	if locked {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Reauth] Re-authentication locked for user: %s", userID)
+		ctrl.SendSecurityWarning(ctx, user, "Có nhiều lần xác minh lại mật khẩu hoặc mã xác thực sai liên tiếp trên tài khoản Gauas của bạn, thao tác này đã bị tạm khóa.\n\nNếu đó không phải là bạn, hãy đặt lại mật khẩu và đăng xuất khỏi các thiết bị lạ ngay lập tức.")
+	}
+	return false, nil
+}
+
+func (ctrl *Controller) verifyReauthentication(ctx context.Context, user *entity.User, password, otpCode *string) (bool, error) {
+	if password != nil && *password != "" {
+		if user.Password == nil || *user.Password == "" {
+			return false, nil
+		}
+		match, _, err := ctrl.VerifyPassword(*password, *user.Password)
+		return match, err
+	}
+
+	factors, err := ctrl.GetEnabledMFAFactors(user.UserID)
+	if err != nil {
+		return false, err
+	}
+	for _, factor := range factors {
+		if valid, err := ctrl.VerifyMFACode(ctx, user, factor, *otpCode); err == nil && valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequireReauthentication runs ReauthenticateUser for a sensitive handler and writes the error response
+// when it fails. It returns false when the handler must stop.
+func (ctrl *Controller) RequireReauthentication(c *gin.Context, user *entity.User, password, otpCode *string, tag string) bool {
+	ctx := c.Request.Context()
+	ok, err := ctrl.ReauthenticateUser(ctx, user, password, otpCode)
+	if errors.Is(err, ErrReauthLocked) {
+		ctrl.RespondReauthLocked(c, user)
+		return false
+	}
+	if errors.Is(err, ErrReauthMissing) {
+		utils.JSON400(c, err.Error())
+		return false
+	}
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to verify re-authentication for user: %s", tag, user.UserID.String())
+		utils.JSON500(c, "Failed to verify your identity")
+		return false
+	}
+	if !ok {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[%s] Invalid credentials for user: %s", tag, user.UserID.String())
+		utils.JSON401(c, "Invalid password or OTP code")
+		return false
+	}
+	return true
+}
+
+// RespondReauthLocked answers 429 with the time left on the user's re-authentication lock
+func (ctrl *Controller) RespondReauthLocked(c *gin.Context, user *entity.User) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Reauth] Re-authentication is locked for user: %s", user.UserID.String())
+	lock, err := ctrl.Repository.GetReauthLock(ctx, user.UserID.String())
+	if err != nil || lock <= 0 {
+		lock = ctrl.LoginThrottlePolicy().LockDuration
+	}
+	utils.JSON429(c, ErrReauthLocked.Error(), secondsCeil(lock))
+}
+
+// SendSecurityWarning emails the user about a sensitive account change with a link to secure the account
+func (ctrl *Controller) SendSecurityWarning(ctx context.Context, user *entity.User, content string) {
+	if user.Email == nil || *user.Email == "" {
+		return
+	}
+	actionUrl := fmt.Sprintf("https://%s/forgot-password", ctrl.Config.EnvConfig.CORS.DomainName)
+	if err := ctrl.Provider.EmailProducer.SendEmailWarning(ctx, *user.Email, ctrl.CheckNullString(user.FullName), content, actionUrl); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Security Warning] Failed to send warning email for user: %s", user.UserID)
+	}
+}
+
 func (ctrl *Controller) GenerateToken() string {
 	return uuid.NewString() + uuid.NewString()
 }
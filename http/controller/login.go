@@ -1,7 +1,7 @@
 package controller
 
 import (
-	"time"
+	"errors"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tnqbao/gau-account-service/shared/utils"
@@ -19,7 +19,7 @@ func (ctrl *Controller) LoginWithIdentifierAndPassword(c *gin.Context) {
 	}
 
 	if !isValidLoginRequest(req) {
-		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Basic Login] Invalid login request for identifier: %s - missing required fields", req)
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Basic Login] Invalid login request - missing required fields")
 		utils.JSON400(c, "Email/Username/Phone and Password are required")
 		return
 	}
@@ -31,28 +31,88 @@ func (ctrl *Controller) LoginWithIdentifierAndPassword(c *gin.Context) {
 		return
 	}
 
+	keepLogin := ParseKeepLogin(req.KeepLogin)
+	identifier := ctrl.LoginIdentifier(&req)
+	clientIP := c.ClientIP()
+
+	lockRemaining, err := ctrl.Repository.GetLoginLock(ctx, identifier)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Basic Login] Failed to check login lock")
+	} else if lockRemaining > 0 {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Basic Login] Login attempt on locked account from IP: %s, device: %s", clientIP, deviceID)
+		loginUser, _ := ctrl.LookupLoginUser(&req)
+		ctrl.RecordFailedLogin(c, loginUser, identifier, deviceID, "password", loginFailureAccountLocked)
+		utils.JSON423(c, "Account is temporarily locked due to too many failed login attempts", secondsCeil(lockRemaining))
+		return
+	}
+
+	delayRemaining, err := ctrl.Repository.GetLoginDelay(ctx, identifier, clientIP)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Basic Login] Failed to check login delay")
+	} else if delayRemaining > 0 {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Basic Login] Login attempt during back-off from IP: %s, device: %s", clientIP, deviceID)
+		loginUser, _ := ctrl.LookupLoginUser(&req)
+		ctrl.RecordFailedLogin(c, loginUser, identifier, deviceID, "password", loginFailureThrottled)
+		utils.JSON429(c, "Too many failed login attempts, please wait before trying again", secondsCeil(delayRemaining))
+		return
+	}
+
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Basic Login] Starting authentication for device: %s", deviceID)
 
 	user, err := ctrl.AuthenticateUser(&req, c)
+	var statusErr *AccountStatusError
+	if errors.As(err, &statusErr) {
+		// The password was right, so this does not count towards the lockout
+		ctrl.RefuseAccountStatus(c, statusErr, identifier, deviceID, "password")
+		return
+	}
 	if err != nil {
 		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Basic Login] Authentication failed for, device: %s", deviceID)
+		loginUser, _ := ctrl.LookupLoginUser(&req)
+		ctrl.RecordFailedLogin(c, loginUser, identifier, deviceID, "password", loginFailureInvalidCredentials)
+
+		result, recordErr := ctrl.Repository.RecordLoginFailure(ctx, identifier, clientIP, ctrl.LoginThrottlePolicy())
+		if recordErr != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, recordErr, "[Basic Login] Failed to record login failure")
+		} else if result.Locked {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Basic Login] Account locked after %d failures from IP: %s", result.IdentifierFailures, clientIP)
+			if result.FirstLockout {
+				ctrl.SendLockoutWarning(ctx, &req, clientIP, result.LockDuration)
+			}
+			utils.JSON423(c, "Account is temporarily locked due to too many failed login attempts", secondsCeil(result.LockDuration))
+			return
+		}
+
 		utils.JSON401(c, "Failed to authenticate user")
 		return
 	}
 
+	if err := ctrl.Repository.ClearLoginFailures(ctx, identifier, clientIP); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Basic Login] Failed to clear login failures for UserID: %s", user.UserID)
+	}
+
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Basic Login] User authenticated successfully - UserID: %s, Device: %s", user.UserID, deviceID)
 
-	accessToken, refreshToken, expiresAt, err := ctrl.Provider.AuthorizationServiceProvider.CreateNewToken(user.UserID, user.Permission, deviceID)
+	challengeID, factors, err := ctrl.StartMFAChallenge(ctx, user, deviceID, "password", keepLogin)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Basic Login] Failed to start MFA challenge for UserID: %s", user.UserID)
+		utils.JSON500(c, "Could not start MFA challenge")
+		return
+	}
+	if challengeID != "" {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Basic Login] MFA required for UserID: %s, factors: %v", user.UserID, factors)
+		ctrl.RespondMFARequired(c, challengeID, factors)
+		return
+	}
+
+	accessToken, refreshToken, expiresIn, err := ctrl.CreateUserSession(c, user, deviceID, "password", keepLogin)
 	if err != nil {
 		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Basic Login] Failed to create token for UserID: %s, Device: %s", user.UserID, deviceID)
 		utils.JSON500(c, "Could not create token")
 		return
 	}
 
-	expiresIn := int(time.Until(expiresAt).Seconds())
-
-	ctrl.SetAccessCookie(c, accessToken, expiresIn)
-	ctrl.SetRefreshCookie(c, refreshToken, 30*24*60*60)
+	ctrl.SetSessionCookies(c, accessToken, expiresIn, refreshToken, keepLogin)
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Basic Login] Login completed successfully - UserID: %s, Device: %s, ExpiresIn: %d", user.UserID, deviceID, expiresIn)
 
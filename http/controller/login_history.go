@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// Failure reasons stored in the login history
+const (
+	loginFailureInvalidCredentials = "invalid_credentials"
+	loginFailureAccountLocked      = "account_locked"
+	loginFailureThrottled          = "throttled"
+	loginFailureInvalidCode        = "invalid_code"
+	loginFailureReplayedCode       = "replayed_code"
+	loginFailureTooManyAttempts    = "too_many_attempts"
+	loginFailureInvalidToken       = "invalid_token"
+	loginFailureDeviceMismatch     = "device_mismatch"
+	loginFailureAccountPending     = "account_pending"
+	loginFailureAccountSuspended   = "account_suspended"
+	loginFailureAccountBanned      = "account_banned"
+	loginFailureAccountDeleted     = "account_deleted"
+)
+
+// loginNetwork groups an address by its /24 (IPv4) or /64 (IPv6) network so that a changing
+// address from the same provider is not reported as a new location
+func loginNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+func newLoginEvent(c *gin.Context, user *entity.User, deviceID, method string) *entity.LoginEvent {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	event := &entity.LoginEvent{
+		Method:    method,
+		IPAddress: c.ClientIP(),
+		Network:   loginNetwork(c.ClientIP()),
+		UserAgent: userAgent,
+		DeviceID:  deviceID,
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		event.UserID = &user.UserID
+	}
+	return event
+}
+
+// RecordFailedLogin stores a rejected login attempt. user may be nil when the account is unknown,
+// identifier is the normalised login identifier when the method has one.
+func (ctrl *Controller) RecordFailedLogin(c *gin.Context, user *entity.User, identifier, deviceID, method, reason string) {
+	event := newLoginEvent(c, user, deviceID, method)
+	event.Identifier = identifier
+	event.FailureReason = reason
+	if err := ctrl.Repository.CreateLoginEvent(event); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(c.Request.Context(), err, "[Login History] Failed to record failed %s login", method)
+	}
+}
+
+// RecordSuccessfulLogin stores a completed login and warns the user by email when it comes from a
+// device or network that never signed in to the account before
+func (ctrl *Controller) RecordSuccessfulLogin(c *gin.Context, user *entity.User, deviceID, method string) {
+	ctx := c.Request.Context()
+	event := newLoginEvent(c, user, deviceID, method)
+	event.Success = true
+
+	familiarity, err := ctrl.Repository.GetLoginFamiliarity(user.UserID, deviceID, event.Network)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Login History] Failed to check previous logins for user: %s", user.UserID)
+	}
+
+	if err := ctrl.Repository.CreateLoginEvent(event); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Login History] Failed to record login for user: %s", user.UserID)
+	}
+
+	// The first login of an account is expected to come from somewhere new
+	if familiarity == nil || !familiarity.HasHistory || (familiarity.KnownDevice && familiarity.KnownNetwork) {
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Login History] New device or network for user: %s, device: %s, ip: %s", user.UserID, deviceID, event.IPAddress)
+	ctrl.SendSecurityWarning(ctx, user, fmt.Sprintf("Tài khoản Gauas của bạn vừa được đăng nhập từ một thiết bị hoặc mạng mới.\n\nThời gian: %s\nĐịa chỉ IP: %s\nTrình duyệt/thiết bị: %s\nPhương thức: %s\n\nNếu đó không phải là bạn, hãy đặt lại mật khẩu và đăng xuất khỏi các thiết bị khác ngay lập tức.",
+		event.CreatedAt.Format("15:04 02/01/2006"), event.IPAddress, event.UserAgent, method))
+}
+
+// GetLoginHistory returns the caller's login attempts, newest first. Query: page (from 1), limit.
+func (ctrl *Controller) GetLoginHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Login History] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	page, limit, err := ParsePagination(c)
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	events, total, err := ctrl.Repository.GetLoginEvents(userID, (page-1)*limit, limit)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Login History] Failed to get login history for user: %s", userID.String())
+		utils.JSON500(c, "Failed to get login history")
+		return
+	}
+
+	currentDeviceID := c.GetHeader("X-Device-ID")
+	infos := make([]LoginEventInfo, 0, len(events))
+	for _, event := range events {
+		infos = append(infos, LoginEventInfo{
+			ID:            event.ID,
+			Method:        event.Method,
+			Success:       event.Success,
+			FailureReason: event.FailureReason,
+			IPAddress:     event.IPAddress,
+			UserAgent:     event.UserAgent,
+			DeviceID:      event.DeviceID,
+			CurrentDevice: currentDeviceID != "" && event.DeviceID == currentDeviceID,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+
+	utils.JSON200(c, gin.H{
+		"events": infos,
+		"page":   page,
+		"limit":  limit,
+		"total":  total,
+	})
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+)
+
+// LoginIdentifier builds the normalised throttle key for a basic login request, e.g. "email:user@example.com"
+func (ctrl *Controller) LoginIdentifier(req *ClientRequestBasicLogin) string {
+	switch {
+	case req.Username != nil:
+		return "username:" + strings.ToLower(strings.TrimSpace(*req.Username))
+	case req.Email != nil:
+		return "email:" + strings.ToLower(strings.TrimSpace(*req.Email))
+	case req.Phone != nil:
+		return "phone:" + strings.TrimSpace(*req.Phone)
+	}
+	return ""
+}
+
+// LoginIdentifiersForUser returns every throttle key that can refer to the user
+func (ctrl *Controller) LoginIdentifiersForUser(user *entity.User) []string {
+	var identifiers []string
+	if user.Username != nil && *user.Username != "" {
+		identifiers = append(identifiers, "username:"+strings.ToLower(*user.Username))
+	}
+	if user.Email != nil && *user.Email != "" {
+		identifiers = append(identifiers, "email:"+strings.ToLower(*user.Email))
+	}
+	if user.Phone != nil && *user.Phone != "" {
+		identifiers = append(identifiers, "phone:"+*user.Phone)
+	}
+	return identifiers
+}
+
+func (ctrl *Controller) LoginThrottlePolicy() repository.LoginThrottlePolicy {
+	cfg := ctrl.Config.EnvConfig.LoginProtection
+	return repository.LoginThrottlePolicy{
+		FailureWindow:    time.Duration(cfg.FailureWindow) * time.Second,
+		DelayThreshold:   int64(cfg.DelayThreshold),
+		MaxDelay:         time.Duration(cfg.MaxDelay) * time.Second,
+		LockThreshold:    int64(cfg.LockThreshold),
+		LockDuration:     time.Duration(cfg.LockDuration) * time.Second,
+		IPDelayThreshold: int64(cfg.IPDelayThreshold),
+	}
+}
+
+// SendLockoutWarning notifies the owner of an identifier that their account was locked after repeated failures
+func (ctrl *Controller) SendLockoutWarning(ctx context.Context, req *ClientRequestBasicLogin, ip string, lockDuration time.Duration) {
+	var user *entity.User
+	var err error
+	switch {
+	case req.Username != nil:
+		user, err = ctrl.Repository.GetUserByIdentifier("username", *req.Username)
+	case req.Email != nil:
+		user, err = ctrl.Repository.GetUserByIdentifier("email", *req.Email)
+	case req.Phone != nil:
+		user, err = ctrl.Repository.GetUserByIdentifier("phone", *req.Phone)
+	}
+	if err != nil || user == nil || user.Email == nil || *user.Email == "" {
+		return
+	}
+
+	content := fmt.Sprintf("Tài khoản Gauas của bạn đã bị tạm khóa trong %d phút do có nhiều lần đăng nhập sai mật khẩu liên tiếp (địa chỉ IP gần nhất: %s).\n\nNếu đó không phải là bạn, hãy đặt lại mật khẩu ngay để bảo vệ tài khoản.",
+		int(math.Ceil(lockDuration.Minutes())), ip)
+	resetLink := fmt.Sprintf("https://%s/forgot-password", ctrl.Config.EnvConfig.CORS.DomainName)
+
+	if err := ctrl.Provider.EmailProducer.SendEmailWarning(ctx, *user.Email, ctrl.CheckNullString(user.FullName), content, resetLink); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Login Protection] Failed to send lockout warning for user: %s", user.UserID)
+	}
+}
+
+// secondsCeil converts a duration into whole seconds for Retry-After style responses
+func secondsCeil(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -1,7 +1,12 @@
 package controller
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/repository"
 	"github.com/tnqbao/gau-account-service/shared/utils"
 )
 
@@ -37,8 +42,28 @@ func (ctrl *Controller) Logout(c *gin.Context) {
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Logout] Token revoked successfully for device: %s", deviceID)
 
-	c.SetCookie("access_token", "", -1, "/", "", false, true)
-	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	// Stop the access token here right away instead of waiting for the cached revocation status to expire
+	if accessToken := utils.ExtractToken(c); accessToken != "" {
+		ttl := time.Duration(ctrl.Config.EnvConfig.JWT.Expire) * time.Second
+		if err := ctrl.Repository.SetAccessTokenStatus(ctx, accessToken, repository.AccessTokenRevoked, ttl); err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Logout] Failed to cache access token revocation for device %s: %v", deviceID, err)
+		}
+	}
+
+	if userID, ok := c.Get("user_id"); ok {
+		if err := ctrl.Repository.DeleteUserSession(ctx, fmt.Sprint(userID), deviceID); err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Logout] Failed to remove session record for device %s: %v", deviceID, err)
+		}
+		if id, err := uuid.Parse(fmt.Sprint(userID)); err == nil {
+			if err := ctrl.Repository.MarkUserDeviceRevoked(id, deviceID); err != nil {
+				ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Logout] Failed to mark device %s as signed out: %v", deviceID, err)
+			}
+		}
+	}
+
+	// Same domain and path as when they were set, otherwise the browser keeps them
+	ctrl.SetAccessCookie(c, "", -1)
+	ctrl.SetRefreshCookie(c, "", -1)
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Logout] Logout completed successfully for device: %s", deviceID)
 
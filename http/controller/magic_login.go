@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// Login codes are bound to the requesting device through the OTP subject "<user_id>:<device_id>"
+const otpPurposeMagicLogin = "magic_login"
+
+// SendMagicLogin emails a one-click login link or a 6-digit login code. The response never reveals whether the account exists.
+func (ctrl *Controller) SendMagicLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Magic Login] Send request received")
+
+	var req MagicLoginSendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to bind request")
+		utils.JSON400(c, "Invalid request format")
+		return
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = "link"
+	}
+	if mode != "link" && mode != "code" {
+		utils.JSON400(c, "Mode must be either link or code")
+		return
+	}
+
+	deviceID := c.GetHeader("X-Device-ID")
+	if deviceID == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Magic Login] Missing device ID")
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	genericResponse := gin.H{
+		"message": "If an account matches this email, a sign-in email has been sent",
+		"mode":    mode,
+	}
+
+	user, err := ctrl.Repository.GetUserByIdentifier("email", strings.TrimSpace(req.Email))
+	if err != nil {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Magic Login] No matching account, returning generic response")
+		utils.JSON200(c, genericResponse)
+		return
+	}
+
+	// Only a verified mailbox may stand in for the password
+	email, ok := ctrl.GetVerifiedEmail(user)
+	if !ok {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Magic Login] User %s has no verified email", user.UserID)
+		utils.JSON200(c, genericResponse)
+		return
+	}
+
+	wait, err := ctrl.Repository.AcquireOTPCooldown(ctx, otpPurposeMagicLogin, user.UserID.String())
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to check cooldown for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to send sign-in email")
+		return
+	}
+	if wait > 0 {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Magic Login] Cooldown active for user: %s", user.UserID)
+		utils.JSON200(c, genericResponse)
+		return
+	}
+
+	recipientName := ctrl.CheckNullString(user.FullName)
+	if recipientName == "" {
+		recipientName = ctrl.CheckNullString(user.Username)
+	}
+
+	if mode == "code" {
+		code, err := ctrl.Repository.GenerateOTPCode(ctx, otpPurposeMagicLogin, user.UserID.String()+":"+deviceID)
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to generate login code for user: %s", user.UserID)
+			utils.JSON500(c, "Failed to send sign-in email")
+			return
+		}
+
+		content := fmt.Sprintf("Mã đăng nhập Gauas của bạn là: %s\n\nMã có hiệu lực trong %d phút và chỉ dùng được trên thiết bị đã yêu cầu. Nếu bạn không yêu cầu đăng nhập, hãy bỏ qua email này.",
+			code, int(math.Ceil(ctrl.Repository.OTPCodeTTL().Minutes())))
+		if err := ctrl.Provider.EmailProducer.SendEmailOTP(ctx, email, recipientName, content); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to send login code for user: %s", user.UserID)
+			utils.JSON500(c, "Failed to send sign-in email")
+			return
+		}
+	} else {
+		token, err := ctrl.Repository.GenerateMagicLinkToken(ctx, user.UserID.String(), deviceID)
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to generate login link for user: %s", user.UserID)
+			utils.JSON500(c, "Failed to send sign-in email")
+			return
+		}
+
+		loginLink := fmt.Sprintf("https://%s/magic-login?token=%s", ctrl.Config.EnvConfig.CORS.DomainName, token)
+		content := fmt.Sprintf("Xin chào %s,\n\nNhấp vào liên kết bên dưới để đăng nhập vào tài khoản Gauas của bạn. Liên kết chỉ dùng được một lần, trên thiết bị đã yêu cầu, và sẽ hết hạn sau %d phút.\n\nNếu bạn không yêu cầu đăng nhập, hãy bỏ qua email này.",
+			recipientName, int(ctrl.Repository.MagicLinkTTL().Minutes()))
+		if err := ctrl.Provider.EmailProducer.SendMagicLink(ctx, email, recipientName, content, loginLink); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to send login link for user: %s", user.UserID)
+			utils.JSON500(c, "Failed to send sign-in email")
+			return
+		}
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Magic Login] Sign-in %s sent for user: %s, device: %s", mode, user.UserID, deviceID)
+	utils.JSON200(c, genericResponse)
+}
+
+// VerifyMagicLogin redeems a login link token or an emailed code and signs the requesting device in,
+// going through the MFA challenge first when the account has a second factor
+func (ctrl *Controller) VerifyMagicLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Magic Login] Verify request received")
+
+	var req MagicLoginVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to bind request")
+		utils.JSON400(c, "Invalid request format")
+		return
+	}
+
+	deviceID := c.GetHeader("X-Device-ID")
+	if deviceID == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Magic Login] Missing device ID")
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	var user *entity.User
+	var method string
+	switch {
+	case req.Token != nil && *req.Token != "":
+		method = "magic_link"
+		link, err := ctrl.Repository.ConsumeMagicLinkToken(ctx, *req.Token)
+		if err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Magic Login] Invalid or expired login link")
+			ctrl.RecordFailedLogin(c, nil, "", deviceID, method, loginFailureInvalidToken)
+			utils.JSON401(c, "Invalid or expired sign-in link or code")
+			return
+		}
+		if link.DeviceID != deviceID {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Magic Login] Link for user %s opened on another device", link.UserID)
+			if linkUserID, err := uuid.Parse(link.UserID); err == nil {
+				ctrl.RecordFailedLogin(c, &entity.User{UserID: linkUserID}, "", deviceID, method, loginFailureDeviceMismatch)
+			}
+			utils.JSON401(c, "This sign-in link must be opened on the device that requested it")
+			return
+		}
+		userID, err := uuid.Parse(link.UserID)
+		if err == nil {
+			user, err = ctrl.Repository.GetUserById(userID)
+		}
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] User not found for login link: %s", link.UserID)
+			utils.JSON401(c, "Invalid or expired sign-in link or code")
+			return
+		}
+
+	case req.Email != nil && *req.Email != "" && req.Code != nil && *req.Code != "":
+		method = "email_code"
+		identifier := "email:" + strings.ToLower(strings.TrimSpace(*req.Email))
+		found, err := ctrl.Repository.GetUserByIdentifier("email", strings.TrimSpace(*req.Email))
+		if err != nil {
+			ctrl.RecordFailedLogin(c, nil, identifier, deviceID, method, loginFailureInvalidCredentials)
+			utils.JSON401(c, "Invalid or expired sign-in link or code")
+			return
+		}
+		valid, err := ctrl.Repository.VerifyOTPCode(ctx, otpPurposeMagicLogin, found.UserID.String()+":"+deviceID, strings.TrimSpace(*req.Code))
+		if err != nil || !valid {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Magic Login] Invalid login code for user: %s", found.UserID)
+			ctrl.RecordFailedLogin(c, found, identifier, deviceID, method, loginFailureInvalidCode)
+			utils.JSON401(c, "Invalid or expired sign-in link or code")
+			return
+		}
+		user = found
+
+	default:
+		utils.JSON400(c, "Either token or email and code are required")
+		return
+	}
+
+	if ctrl.RejectInactiveAccount(c, user, "", deviceID, method) {
+		return
+	}
+
+	keepLogin := ParseKeepLogin(req.KeepLogin)
+	challengeID, factors, err := ctrl.StartMFAChallenge(ctx, user, deviceID, method, keepLogin)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to start MFA challenge for user: %s", user.UserID)
+		utils.JSON500(c, "Could not start MFA challenge")
+		return
+	}
+	if challengeID != "" {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Magic Login] MFA required for user: %s, factors: %v", user.UserID, factors)
+		ctrl.RespondMFARequired(c, challengeID, factors)
+		return
+	}
+
+	accessToken, refreshToken, expiresIn, err := ctrl.CreateUserSession(c, user, deviceID, method, keepLogin)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Magic Login] Failed to create token for user: %s, device: %s", user.UserID, deviceID)
+		utils.JSON500(c, "Could not create token")
+		return
+	}
+
+	ctrl.SetSessionCookies(c, accessToken, expiresIn, refreshToken, keepLogin)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Magic Login] Login completed for user: %s, device: %s, method: %s", user.UserID, deviceID, method)
+
+	utils.JSON200(c, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    expiresIn,
+	})
+}
@@ -1,17 +1,136 @@
 package controller
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
 	"github.com/tnqbao/gau-account-service/shared/entity"
 	"github.com/tnqbao/gau-account-service/shared/utils"
 )
 
+// TOTPOptions returns the configured TOTP skew, digits and algorithm
+func (ctrl *Controller) TOTPOptions() *utils.TOTPOptions {
+	return utils.NewTOTPOptions(ctrl.Config.EnvConfig)
+}
+
+// TOTPOptionsFor returns the options a TOTP record was enrolled with, changing TOTP_DIGITS or
+// TOTP_ALGORITHM does not break authenticator apps set up before
+func (ctrl *Controller) TOTPOptionsFor(mfa *entity.UserMFA) *utils.TOTPOptions {
+	return ctrl.TOTPOptions().WithParameters(mfa.TOTPDigits, mfa.TOTPAlgorithm)
+}
+
+// SealTOTPSecret stores a freshly generated TOTP secret together with the configured digits and algorithm
+func (ctrl *Controller) SealTOTPSecret(mfa *entity.UserMFA, secret string) error {
+	opts := ctrl.TOTPOptions()
+	mfa.TOTPDigits = opts.Digits.Length()
+	mfa.TOTPAlgorithm = opts.Algorithm.String()
+	return ctrl.SealMFASecret(mfa, secret)
+}
+
+// SealMFASecret encrypts a plaintext secret and stores it on the MFA record. The record ID must be set.
+func (ctrl *Controller) SealMFASecret(mfa *entity.UserMFA, secret string) error {
+	secretCipher, err := utils.NewSecretCipher(ctrl.Config.EnvConfig)
+	if err != nil {
+		return err
+	}
+	sealed, err := secretCipher.Encrypt(secret, utils.MFASecretAssociatedData(mfa.ID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt MFA secret: %w", err)
+	}
+	mfa.Secret = &sealed
+	return nil
+}
+
+// OpenMFASecret returns the plaintext secret of the MFA record, accepting rows not yet encrypted
+func (ctrl *Controller) OpenMFASecret(mfa *entity.UserMFA) (string, error) {
+	if mfa.Secret == nil {
+		return "", fmt.Errorf("MFA record has no secret")
+	}
+	secretCipher, err := utils.NewSecretCipher(ctrl.Config.EnvConfig)
+	if err != nil {
+		return "", err
+	}
+	secret, err := secretCipher.Decrypt(*mfa.Secret, utils.MFASecretAssociatedData(mfa.ID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt MFA secret: %w", err)
+	}
+	return secret, nil
+}
+
+// ValidateTOTPCode checks a code against the record's secret and burns its time step, so the same
+// code or an older one is rejected with utils.ErrTOTPReplayedCode afterwards
+func (ctrl *Controller) ValidateTOTPCode(ctx context.Context, mfa *entity.UserMFA, code string) error {
+	if mfa.Secret == nil {
+		return utils.ErrTOTPInvalidCode
+	}
+	secret, err := ctrl.OpenMFASecret(mfa)
+	if err != nil {
+		return err
+	}
+
+	opts := ctrl.TOTPOptionsFor(mfa)
+	step, ok := opts.MatchStep(strings.TrimSpace(code), secret, time.Now())
+	if !ok {
+		return utils.ErrTOTPInvalidCode
+	}
+
+	claimed, err := ctrl.Repository.ClaimTOTPStep(ctx, mfa.ID, step, opts.StepTTL())
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return utils.ErrTOTPReplayedCode
+	}
+	return nil
+}
+
+// respondTOTPError maps ValidateTOTPCode errors to a response with an error_code
+func (ctrl *Controller) respondTOTPError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrTOTPReplayedCode):
+		utils.JSON400Code(c, "OTP code has already been used, wait for the next code", "otp_replayed")
+	case errors.Is(err, utils.ErrTOTPInvalidCode):
+		utils.JSON400Code(c, "Invalid OTP code", "otp_invalid")
+	default:
+		utils.JSON500(c, "Failed to verify OTP code")
+	}
+}
+
+// GenerateTOTPKey creates a fresh TOTP secret for the user and returns the key, its base32 secret and the account label
+func (ctrl *Controller) GenerateTOTPKey(user *entity.User) (*otp.Key, string, string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	secretString := base32.StdEncoding.EncodeToString(secret)
+
+	// Create account name from user email or username
+	accountName := ctrl.CheckNullString(user.Email)
+	if accountName == "" {
+		accountName = ctrl.CheckNullString(user.Username)
+	}
+	if accountName == "" {
+		accountName = user.UserID.String()
+	}
+
+	key, err := totp.Generate(ctrl.TOTPOptions().GenerateOpts("Gauas Account Service", accountName, secret))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to generate TOTP key: %w", err)
+	}
+
+	return key, secretString, accountName, nil
+}
+
 // GenerateTOTPQR generates QR code for TOTP setup
 func (ctrl *Controller) GenerateTOTPQR(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -72,34 +191,7 @@ func (ctrl *Controller) GenerateTOTPQR(c *gin.Context) {
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Generating TOTP secret for user: %s", uuidUserID.String())
 
-	// Generate a random secret for TOTP
-	secret := make([]byte, 20)
-	_, err = rand.Read(secret)
-	if err != nil {
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to generate secret for user: %s", uuidUserID.String())
-		utils.JSON500(c, "Failed to generate secret")
-		return
-	}
-
-	secretString := base32.StdEncoding.EncodeToString(secret)
-
-	// Create account name from user email or username
-	accountName := ctrl.CheckNullString(user.Email)
-	if accountName == "" {
-		accountName = ctrl.CheckNullString(user.Username)
-	}
-	if accountName == "" {
-		accountName = user.UserID.String()
-	}
-
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Creating TOTP key for user: %s, account: %s", uuidUserID.String(), accountName)
-
-	// Generate TOTP key
-	key, err := totp.Generate(totp.GenerateOpts{
-		Issuer:      "Gauas Account Service",
-		AccountName: accountName,
-		Secret:      secret,
-	})
+	key, secretString, accountName, err := ctrl.GenerateTOTPKey(user)
 	if err != nil {
 		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to generate TOTP key for user: %s", uuidUserID.String())
 		utils.JSON500(c, "Failed to generate TOTP key")
@@ -111,7 +203,6 @@ func (ctrl *Controller) GenerateTOTPQR(c *gin.Context) {
 		ID:      uuid.New(),
 		UserID:  uuidUserID,
 		Type:    "totp",
-		Secret:  &secretString,
 		Enabled: false,
 	}
 
@@ -126,15 +217,27 @@ func (ctrl *Controller) GenerateTOTPQR(c *gin.Context) {
 
 	if existingMFA != nil {
 		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Updating existing disabled TOTP record for user: %s", uuidUserID.String())
-		existingMFA.Secret = &secretString
+		if err := ctrl.SealTOTPSecret(existingMFA, secretString); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to encrypt TOTP secret for user: %s", uuidUserID.String())
+			utils.JSON500(c, "Failed to update MFA record")
+			return
+		}
 		if err := ctrl.Repository.UpdateUserMFA(existingMFA); err != nil {
 			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to update MFA record for user: %s", uuidUserID.String())
 			utils.JSON500(c, "Failed to update MFA record")
 			return
 		}
+		if err := ctrl.Repository.ClearTOTPStep(ctx, existingMFA.ID); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to clear TOTP step for user: %s", uuidUserID.String())
+		}
 		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] MFA record updated successfully for user: %s", uuidUserID.String())
 	} else {
 		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Creating new TOTP record for user: %s", uuidUserID.String())
+		if err := ctrl.SealTOTPSecret(&mfaRecord, secretString); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to encrypt TOTP secret for user: %s", uuidUserID.String())
+			utils.JSON500(c, "Failed to create MFA record")
+			return
+		}
 		if err := ctrl.Repository.CreateUserMFA(&mfaRecord); err != nil {
 			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to create MFA record for user: %s", uuidUserID.String())
 			utils.JSON500(c, "Failed to create MFA record")
@@ -225,10 +328,9 @@ func (ctrl *Controller) EnableTOTP(c *gin.Context) {
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Verifying OTP code for user: %s", uuidUserID.String())
 
 	// Verify the OTP code
-	valid := totp.Validate(req.OTPCode, *totpMFA.Secret)
-	if !valid {
-		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] Invalid OTP code provided for user: %s", uuidUserID.String())
-		utils.JSON400(c, "Invalid OTP code")
+	if err := ctrl.ValidateTOTPCode(ctx, totpMFA, req.OTPCode); err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] OTP code rejected for user: %s: %v", uuidUserID.String(), err)
+		ctrl.respondTOTPError(c, err)
 		return
 	}
 
@@ -247,117 +349,155 @@ func (ctrl *Controller) EnableTOTP(c *gin.Context) {
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] TOTP enabled successfully for user: %s", uuidUserID.String())
 
-	utils.JSON200(c, gin.H{
+	response := gin.H{
 		"message": "TOTP has been successfully enabled",
 		"enabled": true,
-	})
-}
-
-// VerifyTOTP verifies TOTP code during authentication
-func (ctrl *Controller) VerifyTOTP(c *gin.Context) {
-	ctx := c.Request.Context()
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] TOTP verification request received")
+	}
 
-	userID := c.MustGet("user_id")
-	if userID == nil {
-		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] User ID is missing from context")
-		utils.JSON400(c, "User ID is required")
+	recoveryCodes, err := ctrl.IssueRecoveryCodesIfNone(uuidUserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to generate recovery codes for user: %s", uuidUserID.String())
+		utils.JSON500(c, "TOTP enabled but recovery codes could not be generated, please regenerate them")
 		return
 	}
+	if recoveryCodes != nil {
+		response["recovery_codes"] = recoveryCodes
+	}
 
-	var uuidUserID uuid.UUID
-	switch v := userID.(type) {
-	case string:
-		parsed, err := uuid.Parse(v)
-		if err != nil {
-			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Invalid User ID format: %s", v)
-			utils.JSON400(c, "Invalid User ID format")
-			return
-		}
-		uuidUserID = parsed
-	case uuid.UUID:
-		uuidUserID = v
-	default:
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, nil, "[MFA] Invalid User ID type: %T", v)
-		utils.JSON400(c, "Invalid User ID type")
+	utils.JSON200(c, response)
+}
+
+// DisableTOTP turns TOTP off after the user re-authenticates with their password or a current OTP
+func (ctrl *Controller) DisableTOTP(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Disable TOTP request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] %v", err)
+		utils.JSON400(c, err.Error())
 		return
 	}
 
-	var req TOTPVerifyRequest
+	var req MFAReauthRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to bind JSON request for user: %s", uuidUserID.String())
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to bind JSON request for user: %s", userID.String())
 		utils.JSON400(c, "Invalid request format: "+err.Error())
 		return
 	}
 
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Verifying TOTP for user: %s, device: %s", uuidUserID.String(), req.DeviceID)
-
-	// Get user info
-	user, err := ctrl.Repository.GetUserById(uuidUserID)
+	user, err := ctrl.Repository.GetUserById(userID)
 	if err != nil {
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] User not found: %s", uuidUserID.String())
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] User not found: %s", userID.String())
 		utils.JSON404(c, "User not found")
 		return
 	}
 
-	// Get user's TOTP MFA record
-	mfas, err := ctrl.Repository.GetUserMFAs(uuidUserID)
-	if err != nil {
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Error getting user MFA records for user: %s", uuidUserID.String())
-		utils.JSON500(c, "Error getting user MFA records")
+	totpMFA, err := ctrl.Repository.GetUserMFAByType(userID, "totp")
+	if err != nil || !totpMFA.Enabled {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] TOTP is not enabled for user: %s", userID.String())
+		utils.JSON400(c, "TOTP is not enabled for this user")
 		return
 	}
 
-	var totpMFA *entity.UserMFA
-	for _, mfa := range mfas {
-		if mfa.Type == "totp" && mfa.Enabled {
-			totpMFA = &mfa
-			break
-		}
+	if !ctrl.RequireReauthentication(c, user, req.Password, req.OTPCode, "MFA") {
+		return
 	}
 
-	if totpMFA == nil || totpMFA.Secret == nil {
-		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] TOTP is not enabled for user: %s", uuidUserID.String())
-		utils.JSON400(c, "TOTP is not enabled for this user")
+	if err := ctrl.Repository.DeleteUserMFA(totpMFA.ID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to disable TOTP for user: %s", userID.String())
+		utils.JSON500(c, "Failed to disable TOTP")
 		return
 	}
+	if err := ctrl.Repository.ClearTOTPStep(ctx, totpMFA.ID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to clear TOTP step for user: %s", userID.String())
+	}
 
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Validating OTP code for user: %s", uuidUserID.String())
+	ctrl.DropRecoveryCodesWithoutMFA(ctx, userID, "MFA")
 
-	// Verify the OTP code
-	valid := totp.Validate(req.OTPCode, *totpMFA.Secret)
-	if !valid {
-		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] Invalid OTP code provided for user: %s", uuidUserID.String())
-		utils.JSON400(c, "Invalid OTP code")
+	ctrl.SendSecurityWarning(ctx, user, "Xác thực hai lớp (ứng dụng Authenticator) trên tài khoản Gauas của bạn vừa bị tắt.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu và bật lại xác thực hai lớp ngay lập tức.")
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] TOTP disabled successfully for user: %s", userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message": "TOTP has been disabled",
+		"enabled": false,
+	})
+}
+
+// ResetTOTP replaces the TOTP secret after re-authentication. TOTP stays disabled until the
+// new secret is confirmed through EnableTOTP.
+func (ctrl *Controller) ResetTOTP(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] Reset TOTP request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req MFAReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to bind JSON request for user: %s", userID.String())
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
 		return
 	}
 
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] OTP code verified successfully, generating tokens for user: %s", uuidUserID.String())
+	totpMFA, err := ctrl.Repository.GetUserMFAByType(userID, "totp")
+	if err != nil || !totpMFA.Enabled {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA] TOTP is not enabled for user: %s", userID.String())
+		utils.JSON400(c, "TOTP is not enabled for this user")
+		return
+	}
 
-	// Generate new JWT tokens after successful TOTP verification
-	accessToken, refreshToken, expiresAt, err := ctrl.Provider.AuthorizationServiceProvider.CreateNewToken(
-		user.UserID,
-		user.Permission,
-		req.DeviceID,
-	)
+	if !ctrl.RequireReauthentication(c, user, req.Password, req.OTPCode, "MFA") {
+		return
+	}
+
+	key, secretString, accountName, err := ctrl.GenerateTOTPKey(user)
 	if err != nil {
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to generate tokens for user: %s", uuidUserID.String())
-		utils.JSON500(c, "Failed to generate tokens")
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to generate TOTP key for user: %s", userID.String())
+		utils.JSON500(c, "Failed to generate TOTP key")
 		return
 	}
 
-	expiresIn := int(time.Until(expiresAt).Seconds())
+	if err := ctrl.SealTOTPSecret(totpMFA, secretString); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to encrypt TOTP secret for user: %s", userID.String())
+		utils.JSON500(c, "Failed to reset TOTP")
+		return
+	}
+	totpMFA.Enabled = false
+	totpMFA.VerifiedAt = nil
+	if err := ctrl.Repository.UpdateUserMFA(totpMFA); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to reset TOTP for user: %s", userID.String())
+		utils.JSON500(c, "Failed to reset TOTP")
+		return
+	}
+	// The re-authentication above may have burned the current step with the old secret
+	if err := ctrl.Repository.ClearTOTPStep(ctx, totpMFA.ID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA] Failed to clear TOTP step for user: %s", userID.String())
+	}
+	// TOTP stays off until the new secret is confirmed, so like DisableTOTP drop the codes when no other factor is left
+	ctrl.DropRecoveryCodesWithoutMFA(ctx, userID, "MFA")
 
-	ctrl.SetAccessCookie(c, accessToken, expiresIn)
-	ctrl.SetRefreshCookie(c, refreshToken, 30*24*60*60)
+	ctrl.SendSecurityWarning(ctx, user, "Khóa xác thực hai lớp (ứng dụng Authenticator) trên tài khoản Gauas của bạn vừa được tạo lại. Mã từ thiết bị cũ sẽ không còn hiệu lực.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu ngay lập tức.")
 
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] TOTP verification completed successfully for user: %s, device: %s, expires_in: %d",
-		uuidUserID.String(), req.DeviceID, expiresIn)
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA] TOTP secret reset for user: %s", userID.String())
 
 	utils.JSON200(c, gin.H{
-		"message":       "TOTP verification successful",
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
-		"expires_in":    expiresIn,
+		"qr_code": key.URL(),
+		"secret":  secretString,
+		"account": accountName,
+		"issuer":  "Gauas Account Service",
+		"message": "Scan this QR code with your authenticator app and confirm it to enable TOTP again",
 	})
 }
@@ -0,0 +1,369 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+const maxMFAChallengeAttempts = 5
+
+// GetEnabledMFAFactors returns the MFA types the user has enabled, e.g. ["totp"]
+func (ctrl *Controller) GetEnabledMFAFactors(userID uuid.UUID) ([]string, error) {
+	mfas, err := ctrl.Repository.GetUserMFAs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var factors []string
+	for _, mfa := range mfas {
+		if mfa.Enabled {
+			factors = append(factors, mfa.Type)
+		}
+	}
+	return factors, nil
+}
+
+// StartMFAChallenge opens a second-factor challenge when the user has MFA enabled.
+// An empty challenge ID means no second factor is required and tokens can be issued directly.
+func (ctrl *Controller) StartMFAChallenge(ctx context.Context, user *entity.User, deviceID, method string, keepLogin bool) (string, []string, error) {
+	factors, err := ctrl.GetEnabledMFAFactors(user.UserID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get MFA factors: %w", err)
+	}
+	if len(factors) == 0 {
+		return "", nil, nil
+	}
+
+	passkeys, err := ctrl.Repository.CountWebAuthnCredentials(user.UserID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to count passkeys: %w", err)
+	}
+	if passkeys > 0 {
+		factors = append(factors, "webauthn")
+	}
+
+	remaining, err := ctrl.Repository.CountUnusedRecoveryCodes(user.UserID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	if remaining > 0 {
+		factors = append(factors, "recovery_code")
+	}
+
+	challengeID, err := ctrl.Repository.CreateMFAChallenge(ctx, &repository.MFAChallenge{
+		UserID:    user.UserID.String(),
+		DeviceID:  deviceID,
+		Method:    method,
+		Factors:   factors,
+		KeepLogin: keepLogin,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return challengeID, factors, nil
+}
+
+// RespondMFARequired tells the client to complete the login with a second factor
+func (ctrl *Controller) RespondMFARequired(c *gin.Context, challengeID string, factors []string) {
+	utils.JSON200(c, gin.H{
+		"mfa_required": true,
+		"challenge_id": challengeID,
+		"factors":      factors,
+		"expires_in":   int(ctrl.Repository.MFAChallengeTTL().Seconds()),
+	})
+}
+
+// VerifyMFACode checks a code against one of the user's enabled factors
+func (ctrl *Controller) VerifyMFACode(ctx context.Context, user *entity.User, factor, code string) (bool, error) {
+	switch factor {
+	case "totp":
+		mfa, err := ctrl.Repository.GetUserMFAByType(user.UserID, "totp")
+		if err != nil || mfa == nil || !mfa.Enabled || mfa.Secret == nil {
+			return false, fmt.Errorf("TOTP is not enabled for this user")
+		}
+		err = ctrl.ValidateTOTPCode(ctx, mfa, code)
+		if errors.Is(err, utils.ErrTOTPInvalidCode) {
+			return false, nil
+		}
+		return err == nil, err
+	case "email_otp":
+		return ctrl.VerifyOTPFactorCode(ctx, ctrl.emailOTPFactor(), user, code)
+	case "sms_otp":
+		return ctrl.VerifyOTPFactorCode(ctx, ctrl.smsOTPFactor(), user, code)
+	case "recovery_code":
+		return ctrl.Repository.UseRecoveryCode(user.UserID, ctrl.hashRecoveryCode(code))
+	default:
+		return false, fmt.Errorf("unsupported MFA factor: %s", factor)
+	}
+}
+
+// rejectLockedMFA answers 429 while the user's second factor is locked after too many wrong codes.
+// The lock is per user, so opening a new challenge with the password does not reset it.
+func (ctrl *Controller) rejectLockedMFA(c *gin.Context, user *entity.User) bool {
+	ctx := c.Request.Context()
+	lock, err := ctrl.Repository.GetMFALock(ctx, user.UserID.String())
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to check second-factor lock for user: %s", user.UserID)
+		return false
+	}
+	if lock <= 0 {
+		return false
+	}
+	ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Second factor is locked for user: %s", user.UserID)
+	utils.JSON429(c, "Too many invalid codes, please try again later", secondsCeil(lock))
+	return true
+}
+
+// SendMFAChallengeCode delivers a one-time code for factors that need one (email or SMS OTP) during the login challenge
+func (ctrl *Controller) SendMFAChallengeCode(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Challenge] Send code request received")
+
+	var req MFAChallengeSendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to bind JSON request")
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	deviceID := c.GetHeader("X-Device-ID")
+	if deviceID == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Missing device ID")
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	challenge, err := ctrl.Repository.GetMFAChallenge(ctx, req.ChallengeID)
+	if err != nil || challenge.DeviceID != deviceID {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Invalid or expired challenge")
+		utils.JSON401(c, "Invalid or expired MFA challenge")
+		return
+	}
+
+	allowed := false
+	for _, f := range challenge.Factors {
+		if f == req.Factor {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		utils.JSON400(c, "MFA factor is not available for this account")
+		return
+	}
+
+	userID, err := uuid.Parse(challenge.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Invalid user ID in challenge")
+		utils.JSON500(c, "Invalid MFA challenge")
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] User not found: %s", challenge.UserID)
+		utils.JSON401(c, "Invalid or expired MFA challenge")
+		return
+	}
+
+	if ctrl.rejectLockedMFA(c, user) {
+		return
+	}
+
+	var factor *otpFactor
+	switch req.Factor {
+	case "email_otp":
+		factor = ctrl.emailOTPFactor()
+	case "sms_otp":
+		factor = ctrl.smsOTPFactor()
+	default:
+		utils.JSON400(c, "This MFA factor does not need a code to be sent")
+		return
+	}
+	destination, ok := factor.Destination(user)
+	if !ok {
+		utils.JSON400(c, fmt.Sprintf("A verified %s is required", factor.Contact))
+		return
+	}
+	wait, err := factor.Send(ctx, user, destination, factor.LoginPurpose)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to send %s code for user: %s", req.Factor, challenge.UserID)
+		utils.JSON500(c, "Failed to send verification code")
+		return
+	}
+	if wait > 0 {
+		utils.JSON429(c, "Please wait before requesting another code", secondsCeil(wait))
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Challenge] %s code sent for user: %s", req.Factor, challenge.UserID)
+
+	utils.JSON200(c, gin.H{
+		"message":    "A verification code has been sent",
+		"expires_in": int(ctrl.Repository.OTPCodeTTL().Seconds()),
+	})
+}
+
+// CompleteMFAChallenge exchanges a challenge ID and a second-factor code for access and refresh tokens
+func (ctrl *Controller) CompleteMFAChallenge(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Challenge] Complete challenge request received")
+
+	var req MFAChallengeCompleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to bind JSON request")
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	deviceID := c.GetHeader("X-Device-ID")
+	if deviceID == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Missing device ID")
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	challenge, err := ctrl.Repository.GetMFAChallenge(ctx, req.ChallengeID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Invalid or expired challenge")
+		utils.JSON401(c, "Invalid or expired MFA challenge")
+		return
+	}
+
+	if challenge.DeviceID != deviceID {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Device mismatch for user: %s", challenge.UserID)
+		utils.JSON401(c, "Invalid or expired MFA challenge")
+		return
+	}
+
+	factor := req.Factor
+	if factor == "" {
+		factor = challenge.Factors[0]
+	}
+	allowed := false
+	for _, f := range challenge.Factors {
+		if f == factor {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Factor %s not available for user: %s", factor, challenge.UserID)
+		utils.JSON400(c, "MFA factor is not available for this account")
+		return
+	}
+
+	userID, err := uuid.Parse(challenge.UserID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Invalid user ID in challenge")
+		utils.JSON500(c, "Invalid MFA challenge")
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] User not found: %s", challenge.UserID)
+		utils.JSON401(c, "Invalid or expired MFA challenge")
+		return
+	}
+
+	if ctrl.rejectLockedMFA(c, user) {
+		_ = ctrl.Repository.DeleteMFAChallenge(ctx, req.ChallengeID)
+		return
+	}
+
+	var valid bool
+	if factor == "webauthn" {
+		valid, err = ctrl.VerifyWebAuthnSecondFactor(ctx, user, req.ChallengeID, req.SessionID, req.Credential)
+	} else if req.Code == "" {
+		utils.JSON400(c, "MFA code is required")
+		return
+	} else {
+		valid, err = ctrl.VerifyMFACode(ctx, user, factor, req.Code)
+	}
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to verify %s code for user: %s", factor, challenge.UserID)
+	}
+	if !valid {
+		locked, lockErr := ctrl.Repository.RecordMFAFailure(ctx, challenge.UserID, ctrl.LoginThrottlePolicy())
+		if lockErr != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, lockErr, "[MFA Challenge] Failed to record second-factor failure for user: %s", challenge.UserID)
+		}
+		if locked {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Second factor locked for user: %s", challenge.UserID)
+			ctrl.RecordFailedLogin(c, user, "", deviceID, factor, loginFailureTooManyAttempts)
+			_ = ctrl.Repository.DeleteMFAChallenge(ctx, req.ChallengeID)
+			ctrl.SendSecurityWarning(ctx, user, "Có nhiều lần nhập sai mã xác thực hai lớp liên tiếp khi đăng nhập vào tài khoản Gauas của bạn, nghĩa là mật khẩu của bạn có thể đã bị lộ. Đăng nhập bằng xác thực hai lớp đã bị tạm khóa.\n\nNếu đó không phải là bạn, hãy đặt lại mật khẩu ngay lập tức.")
+			ctrl.rejectLockedMFA(c, user)
+			return
+		}
+
+		attempts, countErr := ctrl.Repository.IncrementMFAChallengeAttempts(ctx, req.ChallengeID)
+		if countErr != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, countErr, "[MFA Challenge] Failed to count attempt for user: %s", challenge.UserID)
+		}
+		if attempts >= maxMFAChallengeAttempts {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Too many invalid codes, discarding challenge for user: %s", challenge.UserID)
+			ctrl.RecordFailedLogin(c, user, "", deviceID, factor, loginFailureTooManyAttempts)
+			_ = ctrl.Repository.DeleteMFAChallenge(ctx, req.ChallengeID)
+			utils.JSON401(c, "Too many invalid codes, please log in again")
+			return
+		}
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Invalid %s code for user: %s", factor, challenge.UserID)
+		if errors.Is(err, utils.ErrTOTPReplayedCode) {
+			ctrl.RecordFailedLogin(c, user, "", deviceID, factor, loginFailureReplayedCode)
+			utils.JSON400Code(c, "MFA code has already been used, wait for the next code", "otp_replayed")
+			return
+		}
+		ctrl.RecordFailedLogin(c, user, "", deviceID, factor, loginFailureInvalidCode)
+		utils.JSON400Code(c, "Invalid MFA code", "otp_invalid")
+		return
+	}
+
+	if err := ctrl.Repository.DeleteMFAChallenge(ctx, req.ChallengeID); err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Challenge] Challenge already used for user: %s", challenge.UserID)
+		utils.JSON401(c, "Invalid or expired MFA challenge")
+		return
+	}
+
+	if err := ctrl.Repository.ClearMFAFailures(ctx, challenge.UserID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to clear second-factor failures for user: %s", challenge.UserID)
+	}
+
+	// The status may have changed while the challenge was open
+	if ctrl.RejectInactiveAccount(c, user, "", deviceID, challenge.Method) {
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Challenge] %s verified, creating tokens for user: %s, device: %s", factor, challenge.UserID, deviceID)
+
+	if factor == "recovery_code" {
+		remaining, _ := ctrl.Repository.CountUnusedRecoveryCodes(user.UserID)
+		ctrl.SendSecurityWarning(ctx, user, fmt.Sprintf("Một mã khôi phục vừa được dùng để đăng nhập vào tài khoản Gauas của bạn. Bạn còn %d mã khôi phục chưa sử dụng.\n\nNếu đó không phải là bạn, hãy đặt lại mật khẩu ngay lập tức.", remaining))
+	}
+
+	accessToken, refreshToken, expiresIn, err := ctrl.CreateUserSession(c, user, deviceID, challenge.Method, challenge.KeepLogin)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to generate tokens for user: %s", challenge.UserID)
+		utils.JSON500(c, "Failed to generate tokens")
+		return
+	}
+
+	ctrl.SetSessionCookies(c, accessToken, expiresIn, refreshToken, challenge.KeepLogin)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Challenge] Login completed for user: %s, device: %s, method: %s", challenge.UserID, deviceID, challenge.Method)
+
+	utils.JSON200(c, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    expiresIn,
+	})
+}
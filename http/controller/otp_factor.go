@@ -0,0 +1,326 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// otpFactor is a second factor that delivers one-time codes to a verified contact of the user.
+// Email and SMS OTP share their setup, enable, disable and send flows and only differ in these fields.
+type otpFactor struct {
+	Type          string // UserMFA type, "email_otp" | "sms_otp"
+	Name          string // used in responses, "Email OTP"
+	Tag           string // log tag, "MFA Email"
+	Channel       string // used in warning emails, "email" | "SMS"
+	Contact       string // what must be verified first, "email address" | "phone number"
+	SentTo        string // where codes go in responses, "your email"
+	LoginPurpose  string
+	EnrollPurpose string
+
+	// Destination returns the verified contact codes are delivered to
+	Destination func(user *entity.User) (string, bool)
+	// Send delivers a code for the purpose, a non-zero duration means nothing was sent yet
+	Send func(ctx context.Context, user *entity.User, destination, purpose string) (time.Duration, error)
+	// Verify checks a code sent for the purpose to destination
+	Verify func(ctx context.Context, user *entity.User, destination, purpose, code string) (bool, error)
+}
+
+func (ctrl *Controller) emailOTPFactor() *otpFactor {
+	return &otpFactor{
+		Type:          "email_otp",
+		Name:          "Email OTP",
+		Tag:           "MFA Email",
+		Channel:       "email",
+		Contact:       "email address",
+		SentTo:        "your email",
+		LoginPurpose:  otpPurposeEmailMFA,
+		EnrollPurpose: otpPurposeEmailEnroll,
+		Destination:   ctrl.GetVerifiedEmail,
+		Send: func(ctx context.Context, user *entity.User, _, purpose string) (time.Duration, error) {
+			return ctrl.SendEmailOTPCode(ctx, user, purpose)
+		},
+		Verify: func(ctx context.Context, user *entity.User, _, purpose, code string) (bool, error) {
+			return ctrl.Repository.VerifyOTPCode(ctx, purpose, user.UserID.String(), code)
+		},
+	}
+}
+
+func (ctrl *Controller) smsOTPFactor() *otpFactor {
+	return &otpFactor{
+		Type:          "sms_otp",
+		Name:          "SMS OTP",
+		Tag:           "MFA SMS",
+		Channel:       "SMS",
+		Contact:       "phone number",
+		SentTo:        "your phone",
+		LoginPurpose:  otpPurposeSMSMFA,
+		EnrollPurpose: otpPurposeSMSEnroll,
+		Destination:   ctrl.GetVerifiedPhone,
+		Send:          ctrl.SendSMSOTPCode,
+		Verify: func(ctx context.Context, user *entity.User, phone, purpose, code string) (bool, error) {
+			return ctrl.Repository.VerifyOTPCode(ctx, purpose, smsOTPSubject(user, phone), code)
+		},
+	}
+}
+
+// VerifyOTPFactorCode checks a login or re-authentication code of an enabled OTP factor
+func (ctrl *Controller) VerifyOTPFactorCode(ctx context.Context, factor *otpFactor, user *entity.User, code string) (bool, error) {
+	mfa, err := ctrl.Repository.GetUserMFAByType(user.UserID, factor.Type)
+	if err != nil || mfa == nil || !mfa.Enabled {
+		return false, fmt.Errorf("%s is not enabled for this user", factor.Name)
+	}
+	destination, ok := factor.Destination(user)
+	if !ok {
+		return false, fmt.Errorf("user has no verified %s", factor.Contact)
+	}
+	return factor.Verify(ctx, user, destination, factor.LoginPurpose, strings.TrimSpace(code))
+}
+
+// setupOTPFactor sends an enrolment code to the user's verified contact
+func (ctrl *Controller) setupOTPFactor(c *gin.Context, factor *otpFactor) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[%s] Setup %s request received", factor.Tag, factor.Name)
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[%s] %v", factor.Tag, err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] User not found: %s", factor.Tag, userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	destination, ok := factor.Destination(user)
+	if !ok {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[%s] User %s has no verified %s", factor.Tag, userID.String(), factor.Contact)
+		utils.JSON400(c, fmt.Sprintf("A verified %s is required to enable %s", factor.Contact, factor.Name))
+		return
+	}
+
+	mfa, err := ctrl.Repository.GetUserMFAByType(userID, factor.Type)
+	if err == nil && mfa.Enabled {
+		utils.JSON400(c, factor.Name+" is already enabled for this user")
+		return
+	}
+	if err != nil {
+		mfa = &entity.UserMFA{
+			ID:      uuid.New(),
+			UserID:  userID,
+			Type:    factor.Type,
+			Enabled: false,
+		}
+		if err := ctrl.Repository.CreateUserMFA(mfa); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to create MFA record for user: %s", factor.Tag, userID.String())
+			utils.JSON500(c, "Failed to create MFA record")
+			return
+		}
+	}
+
+	wait, err := factor.Send(ctx, user, destination, factor.EnrollPurpose)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to send enrolment code for user: %s", factor.Tag, userID.String())
+		utils.JSON500(c, "Failed to send verification code")
+		return
+	}
+	if wait > 0 {
+		utils.JSON429(c, "Please wait before requesting another code", secondsCeil(wait))
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[%s] Enrolment code sent for user: %s", factor.Tag, userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message":    "A verification code has been sent to " + factor.SentTo,
+		"expires_in": int(ctrl.Repository.OTPCodeTTL().Seconds()),
+	})
+}
+
+// enableOTPFactor confirms the enrolment code and turns the factor on
+func (ctrl *Controller) enableOTPFactor(c *gin.Context, factor *otpFactor) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[%s] Enable %s request received", factor.Tag, factor.Name)
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[%s] %v", factor.Tag, err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req TOTPEnableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to bind JSON request for user: %s", factor.Tag, userID.String())
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] User not found: %s", factor.Tag, userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	mfa, err := ctrl.Repository.GetUserMFAByType(userID, factor.Type)
+	if err != nil {
+		utils.JSON400(c, fmt.Sprintf("No %s setup found. Please request a code first", factor.Name))
+		return
+	}
+	if mfa.Enabled {
+		utils.JSON400(c, factor.Name+" is already enabled for this user")
+		return
+	}
+
+	destination, ok := factor.Destination(user)
+	if !ok {
+		utils.JSON400(c, fmt.Sprintf("A verified %s is required to enable %s", factor.Contact, factor.Name))
+		return
+	}
+
+	valid, err := factor.Verify(ctx, user, destination, factor.EnrollPurpose, strings.TrimSpace(req.OTPCode))
+	if err != nil || !valid {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[%s] Invalid enrolment code for user: %s", factor.Tag, userID.String())
+		utils.JSON400(c, "Invalid or expired OTP code")
+		return
+	}
+
+	mfa.Enabled = true
+	now := time.Now()
+	mfa.VerifiedAt = &now
+	if err := ctrl.Repository.UpdateUserMFA(mfa); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to enable %s for user: %s", factor.Tag, factor.Name, userID.String())
+		utils.JSON500(c, "Failed to enable "+factor.Name)
+		return
+	}
+
+	response := gin.H{
+		"message": factor.Name + " has been successfully enabled",
+		"enabled": true,
+	}
+
+	recoveryCodes, err := ctrl.IssueRecoveryCodesIfNone(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to generate recovery codes for user: %s", factor.Tag, userID.String())
+	} else if recoveryCodes != nil {
+		response["recovery_codes"] = recoveryCodes
+	}
+
+	ctrl.SendSecurityWarning(ctx, user, fmt.Sprintf("Xác thực hai lớp qua %s vừa được bật cho tài khoản Gauas của bạn.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu ngay lập tức.", factor.Channel))
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[%s] %s enabled for user: %s", factor.Tag, factor.Name, userID.String())
+
+	utils.JSON200(c, response)
+}
+
+// disableOTPFactor turns the factor off after re-authentication
+func (ctrl *Controller) disableOTPFactor(c *gin.Context, factor *otpFactor) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[%s] Disable %s request received", factor.Tag, factor.Name)
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[%s] %v", factor.Tag, err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req MFAReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to bind JSON request for user: %s", factor.Tag, userID.String())
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] User not found: %s", factor.Tag, userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	mfa, err := ctrl.Repository.GetUserMFAByType(userID, factor.Type)
+	if err != nil || !mfa.Enabled {
+		utils.JSON400(c, factor.Name+" is not enabled for this user")
+		return
+	}
+
+	if !ctrl.RequireReauthentication(c, user, req.Password, req.OTPCode, factor.Tag) {
+		return
+	}
+
+	if err := ctrl.Repository.DeleteUserMFA(mfa.ID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to disable %s for user: %s", factor.Tag, factor.Name, userID.String())
+		utils.JSON500(c, "Failed to disable "+factor.Name)
+		return
+	}
+
+	ctrl.DropRecoveryCodesWithoutMFA(ctx, userID, factor.Tag)
+
+	ctrl.SendSecurityWarning(ctx, user, fmt.Sprintf("Xác thực hai lớp qua %s trên tài khoản Gauas của bạn vừa bị tắt.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu và bật lại xác thực hai lớp ngay lập tức.", factor.Channel))
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[%s] %s disabled for user: %s", factor.Tag, factor.Name, userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message": factor.Name + " has been disabled",
+		"enabled": false,
+	})
+}
+
+// sendOTPFactorCode sends a code to a signed-in user, used to re-authenticate sensitive changes
+func (ctrl *Controller) sendOTPFactorCode(c *gin.Context, factor *otpFactor) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[%s] %v", factor.Tag, err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] User not found: %s", factor.Tag, userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	mfa, err := ctrl.Repository.GetUserMFAByType(userID, factor.Type)
+	if err != nil || !mfa.Enabled {
+		utils.JSON400(c, factor.Name+" is not enabled for this user")
+		return
+	}
+
+	destination, ok := factor.Destination(user)
+	if !ok {
+		utils.JSON400(c, fmt.Sprintf("A verified %s is required", factor.Contact))
+		return
+	}
+
+	wait, err := factor.Send(ctx, user, destination, factor.LoginPurpose)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to send code for user: %s", factor.Tag, userID.String())
+		utils.JSON500(c, "Failed to send verification code")
+		return
+	}
+	if wait > 0 {
+		utils.JSON429(c, "Please wait before requesting another code", secondsCeil(wait))
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"message":    "A verification code has been sent to " + factor.SentTo,
+		"expires_in": int(ctrl.Repository.OTPCodeTTL().Seconds()),
+	})
+}
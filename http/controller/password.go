@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+const otpPurposePasswordSetup = "password_setup"
+
+// verifyPasswordSetupCode accepts the code emailed for setting a first password or a code from an enabled MFA factor
+func (ctrl *Controller) verifyPasswordSetupCode(ctx context.Context, user *entity.User, code string) (bool, error) {
+	if valid, err := ctrl.Repository.VerifyOTPCode(ctx, otpPurposePasswordSetup, user.UserID.String(), code); err == nil && valid {
+		return true, nil
+	}
+	return ctrl.ReauthenticateUser(ctx, user, nil, &code)
+}
+
+// SendPasswordSetupCode emails the code an account without a password needs to set its first one
+func (ctrl *Controller) SendPasswordSetupCode(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Change Password] Setup code request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Change Password] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] User not found: %s", userID)
+		utils.JSON404(c, "User not found")
+		return
+	}
+	if user.Password != nil && *user.Password != "" {
+		utils.JSON400(c, "Account already has a password, use the current password to change it")
+		return
+	}
+	if _, ok := ctrl.GetVerifiedEmail(user); !ok {
+		utils.JSON400(c, "A verified email is required to set a password")
+		return
+	}
+
+	wait, err := ctrl.SendEmailOTPCode(ctx, user, otpPurposePasswordSetup)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to send setup code for user: %s", userID)
+		utils.JSON500(c, "Failed to send verification code")
+		return
+	}
+	if wait > 0 {
+		utils.JSON429(c, "A code was sent recently, please wait before requesting another", secondsCeil(wait))
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Change Password] Setup code sent for user: %s", userID)
+	utils.JSON200(c, gin.H{
+		"message":    "Verification code sent to your email",
+		"expires_in": int(ctrl.Repository.OTPCodeTTL().Seconds()),
+	})
+}
+
+// ChangePassword changes the password of the authenticated user, or sets the first password for SSO-only accounts
+func (ctrl *Controller) ChangePassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Change Password] Request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Change Password] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to bind request for user: %s", userID)
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] User not found: %s", userID)
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	hasPassword := user.Password != nil && *user.Password != ""
+	if hasPassword {
+		if req.CurrentPassword == nil || *req.CurrentPassword == "" {
+			utils.JSON400(c, "Current password is required")
+			return
+		}
+
+		match, err := ctrl.ReauthenticateUser(ctx, user, req.CurrentPassword, nil)
+		if errors.Is(err, ErrReauthLocked) {
+			ctrl.RespondReauthLocked(c, user)
+			return
+		}
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to verify current password for user: %s", userID)
+			utils.JSON500(c, "Failed to change password")
+			return
+		}
+		if !match {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Change Password] Wrong current password for user: %s", userID)
+			utils.JSON401(c, "Current password is incorrect")
+			return
+		}
+
+		if *req.CurrentPassword == req.NewPassword {
+			utils.JSON400(c, "New password must be different from the current password")
+			return
+		}
+	} else {
+		// A stolen session must not be able to add a password and take the account over
+		if req.OTPCode == nil || *req.OTPCode == "" {
+			utils.JSON400(c, "A verification code is required to set the first password")
+			return
+		}
+		verified, err := ctrl.verifyPasswordSetupCode(ctx, user, *req.OTPCode)
+		if errors.Is(err, ErrReauthLocked) {
+			ctrl.RespondReauthLocked(c, user)
+			return
+		}
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to verify setup code for user: %s", userID)
+			utils.JSON500(c, "Failed to change password")
+			return
+		}
+		if !verified {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Change Password] Invalid setup code for user: %s", userID)
+			utils.JSON401(c, "Invalid or expired verification code")
+			return
+		}
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Change Password] User %s has no password yet, setting first password", userID)
+	}
+
+	if err := ctrl.ValidatePasswordPolicy(req.NewPassword); err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	hashedPassword, err := ctrl.HashPassword(req.NewPassword)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to hash password for user: %s", userID)
+		utils.JSON500(c, "Failed to change password")
+		return
+	}
+
+	if err := ctrl.Repository.UpdateUserPassword(userID, hashedPassword); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to update password for user: %s", userID)
+		utils.JSON500(c, "Failed to change password")
+		return
+	}
+
+	signOutOthers := req.SignOutOtherDevices == nil || *req.SignOutOtherDevices
+	if signOutOthers {
+		currentDeviceID := c.GetHeader("X-Device-ID")
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Change Password] Signing out other devices for user: %s, keeping device: %s", userID, currentDeviceID)
+		if currentDeviceID == "" {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Change Password] No X-Device-ID header, every device of user %s will be signed out", userID)
+		}
+		if err := ctrl.RevokeUserSessions(ctx, userID, currentDeviceID); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to revoke some sessions for user: %s", userID)
+		}
+	}
+
+	if user.Email != nil && *user.Email != "" {
+		content := "Mật khẩu tài khoản Gauas của bạn vừa được thay đổi.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu ngay lập tức."
+		if !hasPassword {
+			content = "Tài khoản Gauas của bạn vừa được thiết lập mật khẩu đăng nhập.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu ngay lập tức."
+		}
+		if err := ctrl.Provider.EmailProducer.SendEmailNotification(ctx, *user.Email, ctrl.CheckNullString(user.FullName), content, ""); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Change Password] Failed to send notification email for user: %s", userID)
+		}
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Change Password] Password changed successfully for user: %s", userID)
+
+	message := "Password changed successfully"
+	if !hasPassword {
+		message = "Password set successfully"
+	}
+	utils.JSON200(c, gin.H{
+		"message":                  message,
+		"signed_out_other_devices": signOutOthers,
+	})
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// RequestPasswordReset emails a single-use reset link. The response never reveals whether the account exists.
+func (ctrl *Controller) RequestPasswordReset(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Password Reset] Reset request received")
+
+	var req PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to bind request")
+		utils.JSON400(c, "Invalid request format")
+		return
+	}
+
+	if (req.Email == nil || *req.Email == "") && (req.Phone == nil || *req.Phone == "") {
+		utils.JSON400(c, "Email or phone is required")
+		return
+	}
+
+	genericResponse := gin.H{
+		"message": "If an account matches the provided information, a password reset link has been sent",
+	}
+
+	var user *entity.User
+	var err error
+	if req.Email != nil && *req.Email != "" {
+		user, err = ctrl.Repository.GetUserByIdentifier("email", *req.Email)
+	} else {
+		user, err = ctrl.Repository.GetUserByIdentifier("phone", *req.Phone)
+	}
+	if err != nil {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Password Reset] No matching account, returning generic response")
+		utils.JSON200(c, genericResponse)
+		return
+	}
+
+	if user.Email == nil || *user.Email == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Password Reset] User %s has no email to send the reset link to", user.UserID)
+		utils.JSON200(c, genericResponse)
+		return
+	}
+
+	token, err := ctrl.Repository.GeneratePasswordResetToken(ctx, user.UserID.String())
+	if err != nil {
+		// Answered like every other request, an error here would only happen for existing accounts
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to generate reset token for user: %s", user.UserID)
+		utils.JSON200(c, genericResponse)
+		return
+	}
+
+	resetLink := fmt.Sprintf("https://%s/reset-password?token=%s", ctrl.Config.EnvConfig.CORS.DomainName, token)
+
+	recipientName := ctrl.CheckNullString(user.FullName)
+	if recipientName == "" {
+		recipientName = ctrl.CheckNullString(user.Username)
+	}
+
+	content := fmt.Sprintf("Xin chào %s,\n\nChúng tôi đã nhận được yêu cầu đặt lại mật khẩu cho tài khoản Gauas của bạn.\n\nNhấp vào liên kết bên dưới để đặt mật khẩu mới. Liên kết này chỉ dùng được một lần và sẽ hết hạn sau %d phút.\n\nNếu bạn không yêu cầu đặt lại mật khẩu, hãy bỏ qua email này.",
+		recipientName, int(ctrl.Repository.PasswordResetTokenTTL().Minutes()))
+
+	if err := ctrl.Provider.EmailProducer.SendPasswordReset(ctx, *user.Email, recipientName, content, resetLink); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to send reset email for user: %s", user.UserID)
+		utils.JSON200(c, genericResponse)
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Password Reset] Reset email sent for user: %s", user.UserID)
+	utils.JSON200(c, genericResponse)
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func (ctrl *Controller) ResetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Password Reset] Reset confirmation received")
+
+	var req PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to bind request")
+		utils.JSON400(c, "Invalid request format")
+		return
+	}
+
+	if err := ctrl.ValidatePasswordPolicy(req.NewPassword); err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	userIDStr, err := ctrl.Repository.ConsumePasswordResetToken(ctx, req.Token)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Password Reset] Invalid or expired reset token")
+		utils.JSON400(c, "Invalid or expired reset token")
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Invalid user ID in token: %s", userIDStr)
+		utils.JSON400(c, "Invalid or expired reset token")
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] User not found: %s", userID)
+		utils.JSON400(c, "Invalid or expired reset token")
+		return
+	}
+
+	hashedPassword, err := ctrl.HashPassword(req.NewPassword)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to hash password for user: %s", userID)
+		utils.JSON500(c, "Failed to reset password")
+		return
+	}
+
+	if err := ctrl.Repository.UpdateUserPassword(userID, hashedPassword); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to update password for user: %s", userID)
+		utils.JSON500(c, "Failed to reset password")
+		return
+	}
+
+	if err := ctrl.Repository.ClearLoginLocks(ctx, ctrl.LoginIdentifiersForUser(user)...); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to clear login locks for user: %s", userID)
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Password Reset] Password updated, revoking sessions for user: %s", userID)
+
+	if err := ctrl.RevokeUserSessions(ctx, userID, ""); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to revoke some sessions for user: %s", userID)
+	}
+
+	if user.Email != nil && *user.Email != "" {
+		recipientName := ctrl.CheckNullString(user.FullName)
+		content := "Mật khẩu tài khoản Gauas của bạn vừa được đặt lại và tất cả các thiết bị đã bị đăng xuất.\n\nNếu bạn không thực hiện thay đổi này, hãy liên hệ với chúng tôi ngay lập tức."
+		if err := ctrl.Provider.EmailProducer.SendEmailNotification(ctx, *user.Email, recipientName, content, ""); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Password Reset] Failed to send confirmation email for user: %s", userID)
+		}
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Password Reset] Password reset completed for user: %s", userID)
+
+	utils.JSON200(c, gin.H{
+		"message": "Password has been reset successfully",
+	})
+}
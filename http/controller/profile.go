@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -363,6 +364,22 @@ func (ctrl *Controller) UpdateAccountInfo(c *gin.Context) {
 		return
 	}
 
+	// The email change is started first: when it fails nothing else is saved, so the request can simply be retried
+	emailChanged := req.Email != nil && (user.Email == nil || *req.Email != *user.Email)
+	var pendingEmail *string
+	if emailChanged {
+		if err := ctrl.RequestEmailChange(ctx, user, *req.Email); err != nil {
+			if errors.Is(err, ErrEmailInUse) {
+				utils2.JSON409(c, "Email is already in use")
+				return
+			}
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Failed to start email change for user: %s", userID.String())
+			utils2.JSON500(c, "The email change could not be started, nothing was updated")
+			return
+		}
+		pendingEmail = req.Email
+	}
+
 	// Start a database transaction
 	tx := ctrl.Repository.Db.Begin()
 	if tx.Error != nil {
@@ -383,7 +400,7 @@ func (ctrl *Controller) UpdateAccountInfo(c *gin.Context) {
 		UserID:      user.UserID,
 		Username:    utils2.Coalesce(req.Username, user.Username),
 		FullName:    utils2.Coalesce(req.FullName, user.FullName),
-		Email:       utils2.Coalesce(req.Email, user.Email),
+		Email:       user.Email, // changed only through RequestEmailChange
 		Phone:       utils2.Coalesce(req.Phone, user.Phone),
 		DateOfBirth: utils2.Coalesce(req.DateOfBirth, user.DateOfBirth),
 		Gender:      utils2.Coalesce(req.Gender, user.Gender),
@@ -402,72 +419,15 @@ func (ctrl *Controller) UpdateAccountInfo(c *gin.Context) {
 		return
 	}
 
-	// Handle email verification if email is being updated
-	if req.Email != nil && (user.Email == nil || *req.Email != *user.Email) {
-		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Email change detected for user: %s - creating verification record", userID.String())
-
-		// Check if verification record already exists for this email
-		existingVerification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(userID, "email", *req.Email)
-		if err != nil {
-			tx.Rollback()
-			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error checking email verification for user: %s", userID.String())
-			utils2.JSON500(c, "Error checking email verification")
-			return
-		}
-
-		if existingVerification == nil {
-			// Create new email verification record
-			emailVerification := entity2.UserVerification{
-				ID:         uuid.New(),
-				UserID:     userID,
-				Method:     "email",
-				Value:      *req.Email,
-				IsVerified: false,
-			}
-			if err := ctrl.Repository.CreateUserVerificationWithTransaction(tx, &emailVerification); err != nil {
-				tx.Rollback()
-				ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error creating email verification for user: %s", userID.String())
-				utils2.JSON500(c, "Error creating email verification")
-				return
-			}
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Email verification record created for user: %s", userID.String())
-		} else {
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Email verification record already exists for user: %s", userID.String())
-		}
-	}
-
-	// Handle phone verification if phone is being updated
+	// A new number starts unverified, whatever was verified before
 	if req.Phone != nil && (user.Phone == nil || *req.Phone != *user.Phone) {
-		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone change detected for user: %s - creating verification record", userID.String())
-
-		// Check if verification record already exists for this phone
-		existingVerification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(userID, "phone", *req.Phone)
-		if err != nil {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone change detected for user: %s - resetting phone verification", userID.String())
+		if err := ctrl.Repository.ResetPhoneVerificationWithTransaction(tx, userID, *req.Phone); err != nil {
 			tx.Rollback()
-			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error checking phone verification for user: %s", userID.String())
-			utils2.JSON500(c, "Error checking phone verification")
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error resetting phone verification for user: %s", userID.String())
+			utils2.JSON500(c, "Error creating phone verification")
 			return
 		}
-
-		if existingVerification == nil {
-			// Create new phone verification record
-			phoneVerification := entity2.UserVerification{
-				ID:         uuid.New(),
-				UserID:     userID,
-				Method:     "phone",
-				Value:      *req.Phone,
-				IsVerified: false,
-			}
-			if err := ctrl.Repository.CreateUserVerificationWithTransaction(tx, &phoneVerification); err != nil {
-				tx.Rollback()
-				ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error creating phone verification for user: %s", userID.String())
-				utils2.JSON500(c, "Error creating phone verification")
-				return
-			}
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone verification record created for user: %s", userID.String())
-		} else {
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone verification record already exists for user: %s", userID.String())
-		}
 	}
 
 	// Commit transaction
@@ -480,8 +440,9 @@ func (ctrl *Controller) UpdateAccountInfo(c *gin.Context) {
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Successfully updated account info for user: %s", userID.String())
 
 	utils2.JSON200(c, gin.H{
-		"message":   "User information updated successfully",
-		"user_info": updatedUser,
+		"message":       "User information updated successfully",
+		"user_info":     updatedUser,
+		"pending_email": pendingEmail,
 	})
 }
 
@@ -605,6 +566,22 @@ func (ctrl *Controller) UpdateAccountSecurityInfo(c *gin.Context) {
 		return
 	}
 
+	// The email change is started first: when it fails nothing else is saved, so the request can simply be retried
+	emailChanged := req.Email != nil && (user.Email == nil || *req.Email != *user.Email)
+	var pendingEmail *string
+	if emailChanged {
+		if err := ctrl.RequestEmailChange(ctx, user, *req.Email); err != nil {
+			if errors.Is(err, ErrEmailInUse) {
+				utils2.JSON409(c, "Email is already in use")
+				return
+			}
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Failed to start email change for user: %s", userID.String())
+			utils2.JSON500(c, "The email change could not be started, nothing was updated")
+			return
+		}
+		pendingEmail = req.Email
+	}
+
 	// Start a database transaction
 	tx := ctrl.Repository.Db.Begin()
 	if tx.Error != nil {
@@ -621,7 +598,7 @@ func (ctrl *Controller) UpdateAccountSecurityInfo(c *gin.Context) {
 		UserID:      user.UserID,
 		Username:    user.Username, // Keep existing
 		FullName:    user.FullName, // Keep existing
-		Email:       utils2.Coalesce(req.Email, user.Email),
+		Email:       user.Email,    // changed only through RequestEmailChange
 		Phone:       utils2.Coalesce(req.Phone, user.Phone),
 		DateOfBirth: user.DateOfBirth, // Keep existing
 		Gender:      user.Gender,      // Keep existing
@@ -640,72 +617,15 @@ func (ctrl *Controller) UpdateAccountSecurityInfo(c *gin.Context) {
 		return
 	}
 
-	// Handle email verification if email is being updated
-	if req.Email != nil && (user.Email == nil || *req.Email != *user.Email) {
-		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Email change detected for user: %s - creating verification record", userID.String())
-
-		// Check if verification record already exists for this email
-		existingVerification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(userID, "email", *req.Email)
-		if err != nil {
-			tx.Rollback()
-			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error checking email verification for user: %s", userID.String())
-			utils2.JSON500(c, "Error checking email verification")
-			return
-		}
-
-		if existingVerification == nil {
-			// Create new email verification record
-			emailVerification := entity2.UserVerification{
-				ID:         uuid.New(),
-				UserID:     userID,
-				Method:     "email",
-				Value:      *req.Email,
-				IsVerified: false,
-			}
-			if err := ctrl.Repository.CreateUserVerificationWithTransaction(tx, &emailVerification); err != nil {
-				tx.Rollback()
-				ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error creating email verification for user: %s", userID.String())
-				utils2.JSON500(c, "Error creating email verification")
-				return
-			}
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Email verification record created for user: %s", userID.String())
-		} else {
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Email verification record already exists for user: %s", userID.String())
-		}
-	}
-
-	// Handle phone verification if phone is being updated
+	// A new number starts unverified, whatever was verified before
 	if req.Phone != nil && (user.Phone == nil || *req.Phone != *user.Phone) {
-		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone change detected for user: %s - creating verification record", userID.String())
-
-		// Check if verification record already exists for this phone
-		existingVerification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(userID, "phone", *req.Phone)
-		if err != nil {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone change detected for user: %s - resetting phone verification", userID.String())
+		if err := ctrl.Repository.ResetPhoneVerificationWithTransaction(tx, userID, *req.Phone); err != nil {
 			tx.Rollback()
-			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error checking phone verification for user: %s", userID.String())
-			utils2.JSON500(c, "Error checking phone verification")
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error resetting phone verification for user: %s", userID.String())
+			utils2.JSON500(c, "Error creating phone verification")
 			return
 		}
-
-		if existingVerification == nil {
-			// Create new phone verification record
-			phoneVerification := entity2.UserVerification{
-				ID:         uuid.New(),
-				UserID:     userID,
-				Method:     "phone",
-				Value:      *req.Phone,
-				IsVerified: false,
-			}
-			if err := ctrl.Repository.CreateUserVerificationWithTransaction(tx, &phoneVerification); err != nil {
-				tx.Rollback()
-				ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Error creating phone verification for user: %s", userID.String())
-				utils2.JSON500(c, "Error creating phone verification")
-				return
-			}
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone verification record created for user: %s", userID.String())
-		} else {
-			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Profile Update] Phone verification record already exists for user: %s", userID.String())
-		}
 	}
 
 	// Commit the transaction
@@ -715,13 +635,15 @@ func (ctrl *Controller) UpdateAccountSecurityInfo(c *gin.Context) {
 	}
 
 	utils2.JSON200(c, gin.H{
-		"message":   "Security information updated successfully",
-		"user_info": updatedUser,
+		"message":       "Security information updated successfully",
+		"user_info":     updatedUser,
+		"pending_email": pendingEmail,
 	})
 }
 
 // UpdateAccountCompleteInfo updates all account information (basic + security)
 func (ctrl *Controller) UpdateAccountCompleteInfo(c *gin.Context) {
+	ctx := c.Request.Context()
 	userIdRaw := c.MustGet("user_id")
 	if userIdRaw == nil {
 		utils2.JSON400(c, "User ID is required")
@@ -771,6 +693,22 @@ func (ctrl *Controller) UpdateAccountCompleteInfo(c *gin.Context) {
 		return
 	}
 
+	// The email change is started first: when it fails nothing else is saved, so the request can simply be retried
+	emailChanged := req.Email != nil && (user.Email == nil || *req.Email != *user.Email)
+	var pendingEmail *string
+	if emailChanged {
+		if err := ctrl.RequestEmailChange(ctx, user, *req.Email); err != nil {
+			if errors.Is(err, ErrEmailInUse) {
+				utils2.JSON409(c, "Email is already in use")
+				return
+			}
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Profile Update] Failed to start email change for user: %s", userID.String())
+			utils2.JSON500(c, "The email change could not be started, nothing was updated")
+			return
+		}
+		pendingEmail = req.Email
+	}
+
 	// Start a database transaction
 	tx := ctrl.Repository.Db.Begin()
 	if tx.Error != nil {
@@ -787,7 +725,7 @@ func (ctrl *Controller) UpdateAccountCompleteInfo(c *gin.Context) {
 		UserID:      user.UserID,
 		Username:    utils2.Coalesce(req.Username, user.Username),
 		FullName:    utils2.Coalesce(req.FullName, user.FullName),
-		Email:       utils2.Coalesce(req.Email, user.Email),
+		Email:       user.Email, // changed only through RequestEmailChange
 		Phone:       utils2.Coalesce(req.Phone, user.Phone),
 		DateOfBirth: utils2.Coalesce(req.DateOfBirth, user.DateOfBirth),
 		Gender:      utils2.Coalesce(req.Gender, user.Gender),
@@ -806,58 +744,13 @@ func (ctrl *Controller) UpdateAccountCompleteInfo(c *gin.Context) {
 		return
 	}
 
-	// Handle email verification if email is being updated
-	if req.Email != nil && (user.Email == nil || *req.Email != *user.Email) {
-		// Check if verification record already exists for this email
-		existingVerification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(userID, "email", *req.Email)
-		if err != nil {
-			tx.Rollback()
-			utils2.JSON500(c, "Error checking email verification")
-			return
-		}
-
-		if existingVerification == nil {
-			// Create new email verification record
-			emailVerification := entity2.UserVerification{
-				ID:         uuid.New(),
-				UserID:     userID,
-				Method:     "email",
-				Value:      *req.Email,
-				IsVerified: false,
-			}
-			if err := ctrl.Repository.CreateUserVerificationWithTransaction(tx, &emailVerification); err != nil {
-				tx.Rollback()
-				utils2.JSON500(c, "Error creating email verification")
-				return
-			}
-		}
-	}
-
-	// Handle phone verification if phone is being updated
+	// A new number starts unverified, whatever was verified before
 	if req.Phone != nil && (user.Phone == nil || *req.Phone != *user.Phone) {
-		// Check if verification record already exists for this phone
-		existingVerification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(userID, "phone", *req.Phone)
-		if err != nil {
+		if err := ctrl.Repository.ResetPhoneVerificationWithTransaction(tx, userID, *req.Phone); err != nil {
 			tx.Rollback()
-			utils2.JSON500(c, "Error checking phone verification")
+			utils2.JSON500(c, "Error creating phone verification")
 			return
 		}
-
-		if existingVerification == nil {
-			// Create new phone verification record
-			phoneVerification := entity2.UserVerification{
-				ID:         uuid.New(),
-				UserID:     userID,
-				Method:     "phone",
-				Value:      *req.Phone,
-				IsVerified: false,
-			}
-			if err := ctrl.Repository.CreateUserVerificationWithTransaction(tx, &phoneVerification); err != nil {
-				tx.Rollback()
-				utils2.JSON500(c, "Error creating phone verification")
-				return
-			}
-		}
 	}
 
 	// Commit the transaction
@@ -867,8 +760,9 @@ func (ctrl *Controller) UpdateAccountCompleteInfo(c *gin.Context) {
 	}
 
 	utils2.JSON200(c, gin.H{
-		"message":   "Complete user information updated successfully",
-		"user_info": updatedUser,
+		"message":       "Complete user information updated successfully",
+		"user_info":     updatedUser,
+		"pending_email": pendingEmail,
 	})
 }
 
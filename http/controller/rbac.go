@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/provider/dto"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+	"gorm.io/gorm"
+)
+
+// ResolveTokenAccess collects the role and permission names that go into a user's access token
+func (ctrl *Controller) ResolveTokenAccess(user *entity.User) (dto.TokenAccess, error) {
+	access := dto.TokenAccess{Permission: user.Permission}
+
+	roles, err := ctrl.Repository.GetUserRoles(user.UserID)
+	if err != nil {
+		return access, err
+	}
+
+	scopes := map[string]struct{}{}
+	for _, role := range roles {
+		access.Roles = append(access.Roles, role.Name)
+		for _, permission := range role.Permissions {
+			scopes[permission.Name] = struct{}{}
+		}
+	}
+	for scope := range scopes {
+		access.Scopes = append(access.Scopes, scope)
+	}
+	sort.Strings(access.Scopes)
+
+	return access, nil
+}
+
+func roleInfo(role entity.Role) RoleInfo {
+	info := RoleInfo{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: make([]string, 0, len(role.Permissions)),
+	}
+	for _, permission := range role.Permissions {
+		info.Permissions = append(info.Permissions, permission.Name)
+	}
+	sort.Strings(info.Permissions)
+	return info
+}
+
+// ListRoles returns every role with its permissions
+func (ctrl *Controller) ListRoles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	roles, err := ctrl.Repository.ListRoles()
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[RBAC] Failed to list roles")
+		utils.JSON500(c, "Failed to get roles")
+		return
+	}
+
+	infos := make([]RoleInfo, 0, len(roles))
+	for _, role := range roles {
+		infos = append(infos, roleInfo(role))
+	}
+
+	utils.JSON200(c, gin.H{
+		"roles": infos,
+	})
+}
+
+// GetUserRoles returns the roles granted to the user in the path
+func (ctrl *Controller) GetUserRoles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid user ID")
+		return
+	}
+	if _, err := ctrl.Repository.GetUserById(userID); err != nil {
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	roles, err := ctrl.Repository.GetUserRoles(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[RBAC] Failed to get roles for user: %s", userID.String())
+		utils.JSON500(c, "Failed to get user roles")
+		return
+	}
+
+	infos := make([]RoleInfo, 0, len(roles))
+	for _, role := range roles {
+		infos = append(infos, roleInfo(role))
+	}
+
+	utils.JSON200(c, gin.H{
+		"user_id": userID,
+		"roles":   infos,
+	})
+}
+
+// GrantUserRole gives a role to the user in the path. Protected routes see it at once, the scopes of
+// tokens issued before are only updated on their next login.
+func (ctrl *Controller) GrantUserRole(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[RBAC] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid user ID")
+		return
+	}
+
+	var req RoleGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	if _, err := ctrl.Repository.GetUserById(userID); err != nil {
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	role, err := ctrl.Repository.GetRoleByName(req.Role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSON404(c, "Role not found")
+		return
+	}
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[RBAC] Failed to get role %s", req.Role)
+		utils.JSON500(c, "Failed to grant role")
+		return
+	}
+
+	if err := ctrl.Repository.GrantUserRole(userID, role.ID, &adminID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[RBAC] Failed to grant role %s to user: %s", role.Name, userID.String())
+		utils.JSON500(c, "Failed to grant role")
+		return
+	}
+
+	ctrl.dropCachedPermissions(ctx, userID)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[RBAC] Role %s granted to user: %s by: %s", role.Name, userID.String(), adminID.String())
+	ctrl.RecordAdminAction(c, adminID, userID, adminActionRoleGrant, gin.H{"role": role.Name})
+
+	utils.JSON200(c, gin.H{
+		"message": "Role granted successfully",
+	})
+}
+
+// RevokeUserRole takes a role away from the user in the path. Protected routes stop accepting it at once.
+func (ctrl *Controller) RevokeUserRole(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[RBAC] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid user ID")
+		return
+	}
+
+	role, err := ctrl.Repository.GetRoleByName(c.Param("role"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSON404(c, "Role not found")
+		return
+	}
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[RBAC] Failed to get role %s", c.Param("role"))
+		utils.JSON500(c, "Failed to revoke role")
+		return
+	}
+
+	revoked, err := ctrl.Repository.RevokeUserRole(userID, role.ID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[RBAC] Failed to revoke role %s from user: %s", role.Name, userID.String())
+		utils.JSON500(c, "Failed to revoke role")
+		return
+	}
+	if !revoked {
+		utils.JSON404(c, "User does not have this role")
+		return
+	}
+
+	ctrl.dropCachedPermissions(ctx, userID)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[RBAC] Role %s revoked from user: %s by: %s", role.Name, userID.String(), adminID.String())
+	ctrl.RecordAdminAction(c, adminID, userID, adminActionRoleRevoke, gin.H{"role": role.Name})
+
+	utils.JSON200(c, gin.H{
+		"message": "Role revoked successfully",
+	})
+}
+
+// dropCachedPermissions makes RequirePermission reload the user's permissions after a role change
+func (ctrl *Controller) dropCachedPermissions(ctx context.Context, userID uuid.UUID) {
+	if err := ctrl.Repository.DeleteCachedUserPermissions(ctx, userID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[RBAC] Failed to drop cached permissions for user: %s", userID.String())
+	}
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+const (
+	recoveryCodeCount = 10
+	// Lowercase base32 without 0/1/l/o so codes are easy to read and type
+	recoveryCodeAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+)
+
+// GenerateRecoveryCodes replaces the user's recovery codes and returns the new plain codes.
+// Only hashes are stored, so the plain codes can be shown to the user exactly once.
+func (ctrl *Controller) GenerateRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		var sb strings.Builder
+		for j, b := range raw {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		code := sb.String()
+		codes = append(codes, code)
+		hashes = append(hashes, ctrl.hashRecoveryCode(code))
+	}
+
+	if err := ctrl.Repository.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// IssueRecoveryCodesIfNone generates recovery codes when the user has no unused ones left and returns them.
+// It returns nil when a set already exists: enabling another factor must not silently invalidate codes the
+// user stored before, only RegenerateRecoveryCodes replaces them.
+func (ctrl *Controller) IssueRecoveryCodesIfNone(userID uuid.UUID) ([]string, error) {
+	remaining, err := ctrl.Repository.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		return nil, nil
+	}
+	return ctrl.GenerateRecoveryCodes(userID)
+}
+
+// DropRecoveryCodesWithoutMFA deletes the recovery codes once no MFA factor is left enabled,
+// recovery codes only make sense as a fallback for another factor
+func (ctrl *Controller) DropRecoveryCodesWithoutMFA(ctx context.Context, userID uuid.UUID, tag string) {
+	factors, err := ctrl.GetEnabledMFAFactors(userID)
+	if err != nil || len(factors) > 0 {
+		return
+	}
+	if err := ctrl.Repository.DeleteRecoveryCodes(userID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[%s] Failed to delete recovery codes for user: %s", tag, userID.String())
+	}
+}
+
+// hashRecoveryCode normalises user input (case, dashes, spaces) before hashing
+func (ctrl *Controller) hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return ctrl.hashToken(normalized)
+}
+
+// RegenerateRecoveryCodes issues a fresh set of recovery codes after re-authentication and invalidates the old set
+func (ctrl *Controller) RegenerateRecoveryCodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Recovery] Regenerate recovery codes request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Recovery] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req MFAReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Recovery] Failed to bind JSON request for user: %s", userID.String())
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Recovery] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	factors, err := ctrl.GetEnabledMFAFactors(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Recovery] Error getting MFA factors for user: %s", userID.String())
+		utils.JSON500(c, "Error getting user MFA records")
+		return
+	}
+	if len(factors) == 0 {
+		utils.JSON400(c, "MFA is not enabled for this user")
+		return
+	}
+
+	if !ctrl.RequireReauthentication(c, user, req.Password, req.OTPCode, "MFA Recovery") {
+		return
+	}
+
+	codes, err := ctrl.GenerateRecoveryCodes(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Recovery] Failed to generate recovery codes for user: %s", userID.String())
+		utils.JSON500(c, "Failed to generate recovery codes")
+		return
+	}
+
+	ctrl.SendSecurityWarning(ctx, user, "Bộ mã khôi phục xác thực hai lớp của tài khoản Gauas vừa được tạo lại. Các mã cũ không còn sử dụng được.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu ngay lập tức.")
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Recovery] Recovery codes regenerated for user: %s", userID.String())
+
+	utils.JSON200(c, gin.H{
+		"recovery_codes": codes,
+		"message":        "Store these recovery codes in a safe place, they will not be shown again",
+	})
+}
+
+// GetRecoveryCodesStatus returns how many unused recovery codes the user has left
+func (ctrl *Controller) GetRecoveryCodesStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[MFA Recovery] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	remaining, err := ctrl.Repository.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Recovery] Failed to count recovery codes for user: %s", userID.String())
+		utils.JSON500(c, "Failed to get recovery codes")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"remaining": remaining,
+	})
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -23,8 +24,6 @@ func (ctrl *Controller) RegisterWithIdentifierAndPassword(c *gin.Context) {
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Register] Processing registration for user with email: %v, phone: %v", req.Email != nil, req.Phone != nil)
 
-	req.Password = ctrl.HashPassword(req.Password)
-
 	if req.Email != nil && !ctrl.IsValidEmail(*req.Email) {
 		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Register] Invalid email format provided: %s", *req.Email)
 		utils.JSON400(c, "Invalid email format")
@@ -44,6 +43,14 @@ func (ctrl *Controller) RegisterWithIdentifierAndPassword(c *gin.Context) {
 		return
 	}
 
+	hashedPassword, err := ctrl.HashPassword(req.Password)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Register] Failed to hash password")
+		utils.JSON500(c, "Internal server error")
+		return
+	}
+	req.Password = hashedPassword
+
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Register] Starting user creation transaction")
 
 	// Start a database transaction
@@ -71,6 +78,10 @@ func (ctrl *Controller) RegisterWithIdentifierAndPassword(c *gin.Context) {
 		FullName:    &req.FullName,
 		Gender:      &req.Gender,
 	}
+	// VerifyEmail activates the account
+	if ctrl.Config.EnvConfig.Registration.RequireEmailVerification && req.Email != nil && *req.Email != "" {
+		user.Status = entity2.UserStatusPending
+	}
 
 	if req.Username == nil {
 		generatedUsername, err := ctrl.GenerateUsernameFromFullNameWithTransaction(tx, req.FullName)
@@ -176,5 +187,6 @@ func (ctrl *Controller) RegisterWithIdentifierAndPassword(c *gin.Context) {
 	utils.JSON200(c, gin.H{
 		"message": "Registration successful",
 		"user_id": user.UserID,
+		"status":  user.EffectiveStatus(time.Now()),
 	})
 }
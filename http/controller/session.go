@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// ListSessions returns the devices currently signed in to the account. The device sending the
+// request (X-Device-ID) is flagged as current.
+func (ctrl *Controller) ListSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Session] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	devices, err := ctrl.Repository.GetActiveUserDevices(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to list devices for user: %s", userID.String())
+		utils.JSON500(c, "Failed to get sessions")
+		return
+	}
+
+	currentDeviceID := c.GetHeader("X-Device-ID")
+	infos := make([]UserDeviceInfo, 0, len(devices))
+	for _, device := range devices {
+		infos = append(infos, UserDeviceInfo{
+			DeviceID:    device.DeviceID,
+			UserAgent:   device.UserAgent,
+			IPAddress:   device.IPAddress,
+			LoginMethod: device.LoginMethod,
+			FirstSeenAt: device.FirstSeenAt,
+			LastSeenAt:  device.LastSeenAt,
+			Current:     currentDeviceID != "" && device.DeviceID == currentDeviceID,
+		})
+	}
+
+	utils.JSON200(c, gin.H{
+		"sessions": infos,
+	})
+}
+
+// RevokeSession signs one device out of the account. The current device must use logout instead.
+func (ctrl *Controller) RevokeSession(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Session] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	deviceID := c.Param("device_id")
+	if deviceID == "" {
+		utils.JSON400(c, "Device ID is required")
+		return
+	}
+	if deviceID == c.GetHeader("X-Device-ID") {
+		utils.JSON400(c, "Use logout to sign out the current device")
+		return
+	}
+
+	device, err := ctrl.Repository.GetUserDevice(userID, deviceID)
+	if err != nil || device.RevokedAt != nil {
+		utils.JSON404(c, "Session not found")
+		return
+	}
+
+	sessions, err := ctrl.Repository.GetUserSessions(ctx, userID.String())
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to get sessions for user: %s", userID.String())
+		utils.JSON500(c, "Failed to revoke session")
+		return
+	}
+
+	// The refresh token may already have expired out of the session index, the device is still marked signed out
+	if session, ok := sessions[deviceID]; ok {
+		if err := ctrl.revokeSessionToken(userID, deviceID, &session); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to revoke token for user: %s, device: %s", userID.String(), deviceID)
+			utils.JSON500(c, "Failed to revoke session")
+			return
+		}
+		if err := ctrl.Repository.DeleteUserSession(ctx, userID.String(), deviceID); err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Session] Failed to remove session record for device %s: %v", deviceID, err)
+		}
+	}
+
+	if err := ctrl.Repository.MarkUserDeviceRevoked(userID, deviceID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to mark device revoked for user: %s, device: %s", userID.String(), deviceID)
+		utils.JSON500(c, "Failed to revoke session")
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Session] Device %s signed out for user: %s", deviceID, userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeOtherSessions signs every device out of the account except the one sending the request
+func (ctrl *Controller) RevokeOtherSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Session] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	currentDeviceID := c.GetHeader("X-Device-ID")
+	if currentDeviceID == "" {
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	if err := ctrl.RevokeUserSessions(ctx, userID, currentDeviceID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Session] Failed to revoke other sessions for user: %s", userID.String())
+		utils.JSON500(c, "Failed to revoke other sessions")
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Session] Other devices signed out for user: %s", userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message": "Other sessions revoked successfully",
+	})
+}
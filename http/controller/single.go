@@ -30,6 +30,7 @@ func (ctrl *Controller) LoginWithGoogle(c *gin.Context) {
 	googleUser, err := provider.GetUserInfoFromGoogle(req.Token)
 	if err != nil {
 		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Google Login] Invalid Google token provided")
+		ctrl.RecordFailedLogin(c, nil, "", c.GetHeader("X-Device-ID"), "google", loginFailureInvalidToken)
 		utils.JSON401(c, "invalid Google token")
 		return
 	}
@@ -217,21 +218,35 @@ func (ctrl *Controller) LoginWithGoogle(c *gin.Context) {
 		return
 	}
 
+	if ctrl.RejectInactiveAccount(c, user, "", deviceID, "google") {
+		return
+	}
+
+	keepLogin := ParseKeepLogin(req.KeepLogin)
+	challengeID, factors, err := ctrl.StartMFAChallenge(ctx, user, deviceID, "google", keepLogin)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Google Login] Failed to start MFA challenge for user: %s", user.UserID.String())
+		utils.JSON500(c, "Failed to start MFA challenge")
+		return
+	}
+	if challengeID != "" {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Google Login] MFA required for user: %s, factors: %v", user.UserID.String(), factors)
+		ctrl.RespondMFARequired(c, challengeID, factors)
+		return
+	}
+
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Google Login] Creating tokens for user: %s with device: %s", user.UserID.String(), deviceID)
 
-	accessToken, refreshToken, expiresAt, err := ctrl.Provider.AuthorizationServiceProvider.CreateNewToken(user.UserID, user.Permission, deviceID)
+	accessToken, refreshToken, expiresIn, err := ctrl.CreateUserSession(c, user, deviceID, "google", keepLogin)
 	if err != nil {
 		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Google Login] Failed to create tokens for user: %s", user.UserID.String())
 		utils.JSON500(c, "Failed to create authentication tokens")
 		return
 	}
 
-	expiresIn := int(time.Until(expiresAt).Seconds())
-
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Google Login] Tokens created successfully for user: %s", user.UserID.String())
 
-	ctrl.SetAccessCookie(c, accessToken, expiresIn)
-	ctrl.SetRefreshCookie(c, refreshToken, 30*24*60*60)
+	ctrl.SetSessionCookies(c, accessToken, expiresIn, refreshToken, keepLogin)
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Google Login] Google login completed successfully for user: %s", user.UserID.String())
 
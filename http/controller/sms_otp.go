@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+const (
+	otpPurposePhoneVerification = "phone_verification"
+	otpPurposeSMSMFA            = "mfa_sms"
+	otpPurposeSMSEnroll         = "mfa_sms_enroll"
+)
+
+// GetVerifiedPhone returns the user's current phone number when it has a verified phone verification row
+func (ctrl *Controller) GetVerifiedPhone(user *entity.User) (string, bool) {
+	if user.Phone == nil || *user.Phone == "" {
+		return "", false
+	}
+
+	verification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(user.UserID, "phone", *user.Phone)
+	if err != nil || verification == nil || !verification.IsVerified {
+		return "", false
+	}
+	return *user.Phone, true
+}
+
+// smsOTPSubject binds an SMS code to the number it was sent to, so it stops working once the profile phone changes
+func smsOTPSubject(user *entity.User, phone string) string {
+	return user.UserID.String() + ":" + phone
+}
+
+// SendSMSOTPCode generates a one-time code for the purpose and texts it to the phone number.
+// A non-zero duration means the resend cooldown or the per-number limit was hit and nothing was sent.
+func (ctrl *Controller) SendSMSOTPCode(ctx context.Context, user *entity.User, phone, purpose string) (time.Duration, error) {
+	subject := user.UserID.String()
+	// The cooldown stays per user, switching numbers must not allow faster resends
+	wait, err := ctrl.Repository.AcquireOTPCooldown(ctx, purpose, subject)
+	if err != nil {
+		return 0, err
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	limits := []struct {
+		max    int
+		window time.Duration
+	}{
+		{ctrl.Config.EnvConfig.SMS.MaxPerNumberHour, time.Hour},
+		{ctrl.Config.EnvConfig.SMS.MaxPerNumberDay, 24 * time.Hour},
+	}
+	for _, limit := range limits {
+		if limit.max <= 0 {
+			continue
+		}
+		count, reset, err := ctrl.Repository.CountOTPSend(ctx, "sms:"+phone, limit.window)
+		if err != nil {
+			return 0, err
+		}
+		if count > int64(limit.max) {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[SMS OTP] Send limit reached for user: %s (%d in %s)", subject, count, limit.window)
+			return reset, nil
+		}
+	}
+
+	code, err := ctrl.Repository.GenerateOTPCode(ctx, purpose, smsOTPSubject(user, phone))
+	if err != nil {
+		return 0, err
+	}
+
+	content := fmt.Sprintf("Ma xac thuc Gauas cua ban la %s. Ma co hieu luc trong %d phut. Khong chia se ma nay voi bat ky ai.",
+		code, int(math.Ceil(ctrl.Repository.OTPCodeTTL().Minutes())))
+	if err := ctrl.Provider.SMSProvider.SendSMS(ctx, phone, content); err != nil {
+		return 0, fmt.Errorf("failed to send code: %w", err)
+	}
+
+	return 0, nil
+}
+
+// SendPhoneVerification texts a verification code to the phone number on the user's profile
+func (ctrl *Controller) SendPhoneVerification(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Phone Verification] Send code request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Phone Verification] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Phone Verification] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	if user.Phone == nil || *user.Phone == "" {
+		utils.JSON400(c, "No phone number on this account")
+		return
+	}
+	if _, ok := ctrl.GetVerifiedPhone(user); ok {
+		utils.JSON400(c, "Phone number is already verified")
+		return
+	}
+
+	wait, err := ctrl.SendSMSOTPCode(ctx, user, *user.Phone, otpPurposePhoneVerification)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Phone Verification] Failed to send code for user: %s", userID.String())
+		utils.JSON500(c, "Failed to send verification code")
+		return
+	}
+	if wait > 0 {
+		utils.JSON429(c, "Please wait before requesting another code", secondsCeil(wait))
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Phone Verification] Code sent for user: %s", userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message":    "A verification code has been sent to your phone",
+		"expires_in": int(ctrl.Repository.OTPCodeTTL().Seconds()),
+	})
+}
+
+// VerifyPhone checks the SMS code and marks the phone number as verified
+func (ctrl *Controller) VerifyPhone(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Phone Verification] Verify request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Phone Verification] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req TOTPEnableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Phone Verification] Failed to bind JSON request for user: %s", userID.String())
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Phone Verification] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	if user.Phone == nil || *user.Phone == "" {
+		utils.JSON400(c, "No phone number on this account")
+		return
+	}
+
+	phone := *user.Phone
+	valid, err := ctrl.Repository.VerifyOTPCode(ctx, otpPurposePhoneVerification, smsOTPSubject(user, phone), strings.TrimSpace(req.OTPCode))
+	if err != nil || !valid {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Phone Verification] Invalid code for user: %s", userID.String())
+		utils.JSON400(c, "Invalid or expired OTP code")
+		return
+	}
+
+	// The profile may have changed while the code was being checked, only the number the code went to is verified
+	current, err := ctrl.Repository.GetUserById(userID)
+	if err != nil || current.Phone == nil || *current.Phone != phone {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Phone Verification] Phone changed during verification for user: %s", userID.String())
+		utils.JSON409(c, "Phone number has changed, please request a new code")
+		return
+	}
+
+	verification, err := ctrl.Repository.GetUserVerificationByMethodAndValue(userID, "phone", phone)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Phone Verification] Error getting phone verification for user: %s", userID.String())
+		utils.JSON500(c, "Error checking phone verification")
+		return
+	}
+
+	now := time.Now()
+	if verification == nil {
+		verification = &entity.UserVerification{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Method:     "phone",
+			Value:      phone,
+			IsVerified: true,
+			VerifiedAt: &now,
+		}
+		err = ctrl.Repository.CreateUserVerification(verification)
+	} else {
+		verification.IsVerified = true
+		verification.VerifiedAt = &now
+		err = ctrl.Repository.UpdateUserVerification(verification)
+	}
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Phone Verification] Failed to save phone verification for user: %s", userID.String())
+		utils.JSON500(c, "Failed to verify phone number")
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Phone Verification] Phone verified for user: %s", userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message":           "Phone number verified successfully",
+		"is_phone_verified": true,
+	})
+}
+
+// SetupSMSOTP texts an enrolment code to the user's verified phone number
+func (ctrl *Controller) SetupSMSOTP(c *gin.Context) {
+	ctrl.setupOTPFactor(c, ctrl.smsOTPFactor())
+}
+
+// EnableSMSOTP confirms the enrolment code and turns SMS OTP on
+func (ctrl *Controller) EnableSMSOTP(c *gin.Context) {
+	ctrl.enableOTPFactor(c, ctrl.smsOTPFactor())
+}
+
+// DisableSMSOTP turns SMS OTP off after re-authentication
+func (ctrl *Controller) DisableSMSOTP(c *gin.Context) {
+	ctrl.disableOTPFactor(c, ctrl.smsOTPFactor())
+}
+
+// SendSMSOTP texts a code to a signed-in user, used to re-authenticate sensitive changes
+func (ctrl *Controller) SendSMSOTP(c *gin.Context) {
+	ctrl.sendOTPFactorCode(c, ctrl.smsOTPFactor())
+}
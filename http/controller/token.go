@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// RefreshToken exchanges the refresh token of a device for a new access token
+func (ctrl *Controller) RefreshToken(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Token Refresh] Received refresh request")
+
+	refreshToken := c.GetHeader("X-Refresh-Token")
+	if refreshToken == "" {
+		refreshToken, _ = c.Cookie("refresh_token")
+	}
+	if refreshToken == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Token Refresh] No refresh token provided")
+		utils.JSON401Code(c, "No refresh token provided", "refresh_token_missing")
+		return
+	}
+
+	deviceID := c.GetHeader("X-Device-ID")
+	if deviceID == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Token Refresh] Missing device ID")
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	// The access token is usually expired by now, it is only forwarded so the old one can be retired
+	oldAccessToken := utils.ExtractToken(c)
+
+	accessToken, expiresAt, err := ctrl.Provider.AuthorizationServiceProvider.RenewAccessToken(refreshToken, deviceID, oldAccessToken)
+	if err != nil {
+		var message, code string
+		switch {
+		case errors.Is(err, provider.ErrRefreshTokenExpired):
+			message, code = "Refresh token has expired, please log in again", "refresh_token_expired"
+		case errors.Is(err, provider.ErrRefreshTokenRevoked):
+			message, code = "Refresh token has been revoked, please log in again", "refresh_token_revoked"
+		case errors.Is(err, provider.ErrTokenDeviceMismatch):
+			message, code = "Refresh token does not belong to this device", "device_mismatch"
+		default:
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Token Refresh] Failed to renew access token for device: %s", deviceID)
+			utils.JSON500(c, "Failed to refresh token")
+			return
+		}
+
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Token Refresh] Refresh rejected for device %s: %v", deviceID, err)
+		// The stored tokens can never be renewed, drop them so the client goes back to login
+		ctrl.SetAccessCookie(c, "", -1)
+		ctrl.SetRefreshCookie(c, "", -1)
+		utils.JSON401Code(c, message, code)
+		return
+	}
+
+	userID, err := renewedTokenOwner(accessToken)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Token Refresh] Renewed token has no owner for device: %s", deviceID)
+		utils.JSON500(c, "Failed to refresh token")
+		return
+	}
+
+	// A refresh token outlives a suspension or ban that started after it was issued
+	var statusErr *AccountStatusError
+	if err := ctrl.checkTokenOwnerStatus(userID); errors.As(err, &statusErr) {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Token Refresh] Refresh refused for %s user: %s, device: %s", statusErr.Status, statusErr.User.UserID, deviceID)
+		if err := ctrl.Provider.AuthorizationServiceProvider.RevokeToken(refreshToken, deviceID); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Token Refresh] Failed to revoke token for user: %s, device: %s", statusErr.User.UserID, deviceID)
+		}
+		ctrl.SetAccessCookie(c, "", -1)
+		ctrl.SetRefreshCookie(c, "", -1)
+		writeAccountStatusError(c, statusErr)
+		return
+	} else if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Token Refresh] Failed to check account status for device: %s", deviceID)
+		utils.JSON500(c, "Failed to refresh token")
+		return
+	}
+
+	// Like SetSessionCookies, the access cookie only persists when the session was started with keep-login.
+	// The refresh cookie is left as the login set it.
+	session, err := ctrl.Repository.GetUserSession(ctx, userID.String(), deviceID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Token Refresh] Failed to load session for user: %s, device: %s", userID, deviceID)
+	}
+	keepLogin := session != nil && session.KeepLogin
+
+	expiresIn := int(time.Until(expiresAt).Seconds())
+	if keepLogin {
+		ctrl.SetAccessCookie(c, accessToken, expiresIn)
+	} else {
+		ctrl.SetAccessCookie(c, accessToken, 0)
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Token Refresh] Access token renewed for device: %s, ExpiresIn: %d", deviceID, expiresIn)
+
+	utils.JSON200(c, gin.H{
+		"access_token": accessToken,
+		"expires_in":   expiresIn,
+	})
+}
+
+// renewedTokenOwner reads the user ID of an access token just issued by the authorization service
+func renewedTokenOwner(accessToken string) (uuid.UUID, error) {
+	claims := jwt.MapClaims{}
+	// The token comes straight from the authorization service, only its claims are needed
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, claims); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to parse renewed access token: %w", err)
+	}
+	userIDStr, _ := claims["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("renewed access token has no valid user_id")
+	}
+	return userID, nil
+}
+
+// checkTokenOwnerStatus loads the owner of a renewed token and returns an *AccountStatusError when
+// the account is not active
+func (ctrl *Controller) checkTokenOwnerStatus(userID uuid.UUID) error {
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		return err
+	}
+	return CheckAccountStatus(user)
+}
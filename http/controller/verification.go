@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
 	"github.com/tnqbao/gau-account-service/shared/utils"
 )
 
@@ -112,6 +113,17 @@ func (ctrl *Controller) VerifyEmail(c *gin.Context) {
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[VerifyEmail] Email verified successfully for user: %s, email: %s", userID, email)
 
+	// Accounts registered while email verification is required wait for this to become active
+	if user, err := ctrl.Repository.GetUserById(parsedUserID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[VerifyEmail] Failed to load user: %s", userID)
+	} else if user.Status == entity.UserStatusPending && user.Email != nil && *user.Email == email {
+		if err := ctrl.ChangeUserStatus(ctx, user, entity.UserStatusActive, nil, nil, uuid.Nil); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[VerifyEmail] Failed to activate user: %s", userID)
+			utils.JSON500(c, "Email verified but the account could not be activated, please request a new verification email")
+			return
+		}
+	}
+
 	utils.JSON200(c, gin.H{
 		"message": "Email verified successfully",
 	})
@@ -0,0 +1,616 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// WebAuthnRelyingParty returns the relying party configured for passkeys
+func (ctrl *Controller) WebAuthnRelyingParty() *utils.WebAuthnRelyingParty {
+	return utils.NewWebAuthnRelyingParty(ctrl.Config.EnvConfig)
+}
+
+// webAuthnAllowCredentials lists the user's credentials in the format expected by the browser
+func (ctrl *Controller) webAuthnAllowCredentials(userID uuid.UUID) ([]gin.H, error) {
+	credentials, err := ctrl.Repository.GetWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	allow := make([]gin.H, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := gin.H{
+			"type": "public-key",
+			"id":   credential.CredentialID,
+		}
+		if credential.Transports != "" {
+			descriptor["transports"] = strings.Split(credential.Transports, ",")
+		}
+		allow = append(allow, descriptor)
+	}
+	return allow, nil
+}
+
+// webAuthnRequestOptions builds PublicKeyCredentialRequestOptions for navigator.credentials.get()
+func (ctrl *Controller) webAuthnRequestOptions(challenge string, allowCredentials []gin.H, userVerification string) gin.H {
+	rp := ctrl.WebAuthnRelyingParty()
+	return gin.H{
+		"challenge":        challenge,
+		"rpId":             rp.ID,
+		"timeout":          ctrl.Repository.WebAuthnSessionTTL().Milliseconds(),
+		"userVerification": userVerification,
+		"allowCredentials": allowCredentials,
+	}
+}
+
+// VerifyWebAuthnAssertion checks a passkey assertion for a ceremony and returns the credential owner.
+// When the session is bound to a user, the credential must belong to that user.
+func (ctrl *Controller) VerifyWebAuthnAssertion(ctx context.Context, session *repository.WebAuthnSession, credential *WebAuthnAssertionCredential, requireUserVerification bool) (*entity.User, error) {
+	clientDataJSON, err := utils.DecodeWebAuthnBase64(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clientDataJSON encoding")
+	}
+	authData, err := utils.DecodeWebAuthnBase64(credential.Response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authenticatorData encoding")
+	}
+	signature, err := utils.DecodeWebAuthnBase64(credential.Response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding")
+	}
+	rawID, err := utils.DecodeWebAuthnBase64(credential.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential ID encoding")
+	}
+
+	stored, err := ctrl.Repository.GetWebAuthnCredentialByCredentialID(base64.RawURLEncoding.EncodeToString(rawID))
+	if err != nil {
+		return nil, fmt.Errorf("unknown credential")
+	}
+	if session.UserID != "" && stored.UserID.String() != session.UserID {
+		return nil, fmt.Errorf("credential does not belong to this user")
+	}
+	if credential.Response.UserHandle != "" {
+		userHandle, err := utils.DecodeWebAuthnBase64(credential.Response.UserHandle)
+		if err != nil || string(userHandle) != string(stored.UserID[:]) {
+			return nil, fmt.Errorf("user handle mismatch")
+		}
+	}
+
+	signCount, err := ctrl.WebAuthnRelyingParty().VerifyAssertion(session.Challenge, clientDataJSON, authData, signature, stored.PublicKey, uint32(stored.SignCount), requireUserVerification)
+	if err != nil {
+		if errors.Is(err, utils.ErrWebAuthnSignCount) {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] Signature counter regression on credential %s of user %s", stored.ID, stored.UserID)
+		}
+		return nil, err
+	}
+
+	updated, err := ctrl.Repository.RecordWebAuthnCredentialUse(stored.ID, stored.SignCount, int64(signCount))
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("credential was used concurrently")
+	}
+
+	return ctrl.Repository.GetUserById(stored.UserID)
+}
+
+// BeginWebAuthnRegistration returns PublicKeyCredentialCreationOptions for a new passkey after re-authentication
+func (ctrl *Controller) BeginWebAuthnRegistration(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[WebAuthn] Begin registration request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req MFAReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to bind JSON request for user: %s", userID.String())
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.Repository.GetUserById(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] User not found: %s", userID.String())
+		utils.JSON404(c, "User not found")
+		return
+	}
+
+	// A passkey signs in without password or second factor, so a stolen session must not be able to add one.
+	// The registration session below is only issued after this check and FinishWebAuthnRegistration requires it.
+	if !ctrl.RequireReauthentication(c, user, req.Password, req.OTPCode, "WebAuthn") {
+		return
+	}
+
+	excludeCredentials, err := ctrl.webAuthnAllowCredentials(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to list credentials for user: %s", userID.String())
+		utils.JSON500(c, "Failed to start passkey registration")
+		return
+	}
+
+	challenge, err := utils.GenerateWebAuthnChallenge()
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to generate challenge for user: %s", userID.String())
+		utils.JSON500(c, "Failed to start passkey registration")
+		return
+	}
+
+	if err := ctrl.Repository.SaveWebAuthnSession(ctx, &repository.WebAuthnSession{
+		Challenge: challenge,
+		Ceremony:  "registration",
+		UserID:    userID.String(),
+	}); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to store session for user: %s", userID.String())
+		utils.JSON500(c, "Failed to start passkey registration")
+		return
+	}
+
+	accountName := ctrl.CheckNullString(user.Email)
+	if accountName == "" {
+		accountName = ctrl.CheckNullString(user.Username)
+	}
+	if accountName == "" {
+		accountName = user.UserID.String()
+	}
+	displayName := ctrl.CheckNullString(user.FullName)
+	if displayName == "" {
+		displayName = accountName
+	}
+
+	rp := ctrl.WebAuthnRelyingParty()
+	utils.JSON200(c, gin.H{
+		"session_id": challenge,
+		"public_key": gin.H{
+			"challenge": challenge,
+			"rp": gin.H{
+				"id":   rp.ID,
+				"name": rp.Name,
+			},
+			"user": gin.H{
+				"id":          base64.RawURLEncoding.EncodeToString(user.UserID[:]),
+				"name":        accountName,
+				"displayName": displayName,
+			},
+			"pubKeyCredParams": []gin.H{
+				{"type": "public-key", "alg": utils.COSEAlgES256},
+				{"type": "public-key", "alg": utils.COSEAlgEdDSA},
+				{"type": "public-key", "alg": utils.COSEAlgRS256},
+			},
+			"timeout":     ctrl.Repository.WebAuthnSessionTTL().Milliseconds(),
+			"attestation": "none",
+			"authenticatorSelection": gin.H{
+				"residentKey":      "preferred",
+				"userVerification": "preferred",
+			},
+			"excludeCredentials": excludeCredentials,
+		},
+	})
+}
+
+// FinishWebAuthnRegistration verifies the attestation and stores the new passkey
+func (ctrl *Controller) FinishWebAuthnRegistration(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[WebAuthn] Finish registration request received")
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var req WebAuthnRegisterFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to bind JSON request for user: %s", userID.String())
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	session, err := ctrl.Repository.ConsumeWebAuthnSession(ctx, req.SessionID)
+	if err != nil || session.Ceremony != "registration" || session.UserID != userID.String() {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] Invalid registration session for user: %s", userID.String())
+		utils.JSON400(c, "Invalid or expired passkey registration")
+		return
+	}
+
+	clientDataJSON, err := utils.DecodeWebAuthnBase64(req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		utils.JSON400(c, "Invalid clientDataJSON encoding")
+		return
+	}
+	attestationObject, err := utils.DecodeWebAuthnBase64(req.Credential.Response.AttestationObject)
+	if err != nil {
+		utils.JSON400(c, "Invalid attestationObject encoding")
+		return
+	}
+
+	credential, err := ctrl.WebAuthnRelyingParty().VerifyRegistration(session.Challenge, clientDataJSON, attestationObject, false)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] Registration verification failed for user: %s: %v", userID.String(), err)
+		utils.JSON400(c, "Passkey verification failed")
+		return
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	if _, err := ctrl.Repository.GetWebAuthnCredentialByCredentialID(credentialID); err == nil {
+		utils.JSON409(c, "This passkey is already registered")
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > 100 {
+		name = name[:100]
+	}
+
+	aaguid := ""
+	if parsed, err := uuid.FromBytes(credential.AAGUID); err == nil {
+		aaguid = parsed.String()
+	}
+
+	record := &entity.UserWebAuthnCredential{
+		ID:           uuid.New(),
+		UserID:       userID,
+		CredentialID: credentialID,
+		PublicKey:    credential.PublicKey,
+		Algorithm:    credential.Algorithm,
+		SignCount:    int64(credential.SignCount),
+		AAGUID:       aaguid,
+		Transports:   strings.Join(req.Credential.Response.Transports, ","),
+		Name:         name,
+	}
+	if err := ctrl.Repository.CreateWebAuthnCredential(record); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to store credential for user: %s", userID.String())
+		utils.JSON500(c, "Failed to register passkey")
+		return
+	}
+
+	if user, err := ctrl.Repository.GetUserById(userID); err == nil {
+		ctrl.SendSecurityWarning(ctx, user, fmt.Sprintf("Một passkey mới (%s) vừa được thêm vào tài khoản Gauas của bạn.\n\nNếu bạn không thực hiện thay đổi này, hãy xóa passkey đó và đặt lại mật khẩu ngay lập tức.", name))
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[WebAuthn] Passkey registered for user: %s, format: %s", userID.String(), credential.Format)
+
+	utils.JSON200(c, gin.H{
+		"message": "Passkey registered successfully",
+		"credential": WebAuthnCredentialInfo{
+			ID:         record.ID,
+			Name:       record.Name,
+			Transports: req.Credential.Response.Transports,
+			CreatedAt:  record.CreatedAt,
+		},
+	})
+}
+
+// ListWebAuthnCredentials returns the passkeys registered on the account
+func (ctrl *Controller) ListWebAuthnCredentials(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	credentials, err := ctrl.Repository.GetWebAuthnCredentials(userID)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to list credentials for user: %s", userID.String())
+		utils.JSON500(c, "Failed to get passkeys")
+		return
+	}
+
+	infos := make([]WebAuthnCredentialInfo, 0, len(credentials))
+	for _, credential := range credentials {
+		info := WebAuthnCredentialInfo{
+			ID:         credential.ID,
+			Name:       credential.Name,
+			CreatedAt:  credential.CreatedAt,
+			LastUsedAt: credential.LastUsedAt,
+		}
+		if credential.Transports != "" {
+			info.Transports = strings.Split(credential.Transports, ",")
+		}
+		infos = append(infos, info)
+	}
+
+	utils.JSON200(c, gin.H{
+		"credentials": infos,
+	})
+}
+
+// RenameWebAuthnCredential changes the display name of a passkey
+func (ctrl *Controller) RenameWebAuthnCredential(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	credentialID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid passkey ID")
+		return
+	}
+
+	var req WebAuthnCredentialRenameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		utils.JSON400(c, "Name is required")
+		return
+	}
+
+	updated, err := ctrl.Repository.RenameWebAuthnCredential(userID, credentialID, name)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to rename credential %s for user: %s", credentialID, userID.String())
+		utils.JSON500(c, "Failed to rename passkey")
+		return
+	}
+	if !updated {
+		utils.JSON404(c, "Passkey not found")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"message": "Passkey renamed successfully",
+		"name":    name,
+	})
+}
+
+// DeleteWebAuthnCredential removes a passkey from the account
+func (ctrl *Controller) DeleteWebAuthnCredential(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := ctrl.GetUserIDFromContext(c)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn] %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	credentialID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid passkey ID")
+		return
+	}
+
+	credential, err := ctrl.Repository.GetUserWebAuthnCredential(userID, credentialID)
+	if err != nil {
+		utils.JSON404(c, "Passkey not found")
+		return
+	}
+
+	if _, err := ctrl.Repository.DeleteWebAuthnCredential(userID, credentialID); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn] Failed to delete credential %s for user: %s", credentialID, userID.String())
+		utils.JSON500(c, "Failed to delete passkey")
+		return
+	}
+
+	if user, err := ctrl.Repository.GetUserById(userID); err == nil {
+		ctrl.SendSecurityWarning(ctx, user, fmt.Sprintf("Passkey \"%s\" vừa bị xóa khỏi tài khoản Gauas của bạn.\n\nNếu bạn không thực hiện thay đổi này, hãy đặt lại mật khẩu ngay lập tức.", credential.Name))
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[WebAuthn] Passkey %s deleted for user: %s", credentialID, userID.String())
+
+	utils.JSON200(c, gin.H{
+		"message": "Passkey deleted successfully",
+	})
+}
+
+// BeginWebAuthnLogin starts a passwordless passkey login
+func (ctrl *Controller) BeginWebAuthnLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[WebAuthn Login] Begin login request received")
+
+	var req WebAuthnLoginBeginRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.JSON400(c, "Invalid request format: "+err.Error())
+			return
+		}
+	}
+
+	challenge, err := utils.GenerateWebAuthnChallenge()
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn Login] Failed to generate challenge")
+		utils.JSON500(c, "Failed to start passkey login")
+		return
+	}
+
+	session := &repository.WebAuthnSession{
+		Challenge: challenge,
+		Ceremony:  "login",
+	}
+
+	// An unknown identifier gets the same answer as a discoverable login so accounts cannot be enumerated
+	allowCredentials := []gin.H{}
+	var user *entity.User
+	switch {
+	case req.Username != nil:
+		user, err = ctrl.Repository.GetUserByIdentifier("username", *req.Username)
+	case req.Email != nil:
+		user, err = ctrl.Repository.GetUserByIdentifier("email", *req.Email)
+	case req.Phone != nil:
+		user, err = ctrl.Repository.GetUserByIdentifier("phone", *req.Phone)
+	}
+	if err == nil && user != nil {
+		if credentials, err := ctrl.webAuthnAllowCredentials(user.UserID); err == nil && len(credentials) > 0 {
+			allowCredentials = credentials
+			session.UserID = user.UserID.String()
+		}
+	}
+
+	if err := ctrl.Repository.SaveWebAuthnSession(ctx, session); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn Login] Failed to store session")
+		utils.JSON500(c, "Failed to start passkey login")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"session_id": challenge,
+		"public_key": ctrl.webAuthnRequestOptions(challenge, allowCredentials, "required"),
+	})
+}
+
+// FinishWebAuthnLogin verifies the passkey assertion and issues tokens. A user-verifying passkey
+// already combines possession and a biometric/PIN, so no further MFA challenge is raised.
+func (ctrl *Controller) FinishWebAuthnLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[WebAuthn Login] Finish login request received")
+
+	var req WebAuthnLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn Login] Failed to bind JSON request")
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	deviceID := c.GetHeader("X-Device-ID")
+	if deviceID == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn Login] Missing device ID")
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	session, err := ctrl.Repository.ConsumeWebAuthnSession(ctx, req.SessionID)
+	if err != nil || session.Ceremony != "login" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn Login] Invalid or expired session")
+		utils.JSON401(c, "Invalid or expired passkey login")
+		return
+	}
+
+	user, err := ctrl.VerifyWebAuthnAssertion(ctx, session, &req.Credential, true)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[WebAuthn Login] Assertion verification failed: %v", err)
+		ctrl.RecordFailedLogin(c, nil, "", deviceID, "passkey", loginFailureInvalidCredentials)
+		utils.JSON401(c, "Passkey verification failed")
+		return
+	}
+
+	if ctrl.RejectInactiveAccount(c, user, "", deviceID, "passkey") {
+		return
+	}
+
+	keepLogin := ParseKeepLogin(req.KeepLogin)
+	accessToken, refreshToken, expiresIn, err := ctrl.CreateUserSession(c, user, deviceID, "passkey", keepLogin)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[WebAuthn Login] Failed to create tokens for user: %s", user.UserID)
+		utils.JSON500(c, "Failed to create authentication tokens")
+		return
+	}
+
+	ctrl.SetSessionCookies(c, accessToken, expiresIn, refreshToken, keepLogin)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[WebAuthn Login] Login completed for user: %s, device: %s", user.UserID, deviceID)
+
+	utils.JSON200(c, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    expiresIn,
+	})
+}
+
+// BeginWebAuthnMFAChallenge returns request options to answer a pending MFA challenge with a passkey
+func (ctrl *Controller) BeginWebAuthnMFAChallenge(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[MFA Challenge] Begin passkey challenge request received")
+
+	var req WebAuthnMFABeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request format: "+err.Error())
+		return
+	}
+
+	deviceID := c.GetHeader("X-Device-ID")
+	if deviceID == "" {
+		utils.JSON400(c, "X-Device-ID header is required")
+		return
+	}
+
+	challenge, err := ctrl.Repository.GetMFAChallenge(ctx, req.ChallengeID)
+	if err != nil || challenge.DeviceID != deviceID {
+		utils.JSON401(c, "Invalid or expired MFA challenge")
+		return
+	}
+
+	userID, err := uuid.Parse(challenge.UserID)
+	if err != nil {
+		utils.JSON500(c, "Invalid MFA challenge")
+		return
+	}
+
+	allowCredentials, err := ctrl.webAuthnAllowCredentials(userID)
+	if err != nil || len(allowCredentials) == 0 {
+		utils.JSON400(c, "MFA factor is not available for this account")
+		return
+	}
+
+	webAuthnChallenge, err := utils.GenerateWebAuthnChallenge()
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to generate passkey challenge")
+		utils.JSON500(c, "Failed to start passkey verification")
+		return
+	}
+
+	if err := ctrl.Repository.SaveWebAuthnSession(ctx, &repository.WebAuthnSession{
+		Challenge:      webAuthnChallenge,
+		Ceremony:       "mfa",
+		UserID:         challenge.UserID,
+		MFAChallengeID: req.ChallengeID,
+	}); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[MFA Challenge] Failed to store passkey session")
+		utils.JSON500(c, "Failed to start passkey verification")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"session_id": webAuthnChallenge,
+		"public_key": ctrl.webAuthnRequestOptions(webAuthnChallenge, allowCredentials, "preferred"),
+	})
+}
+
+// VerifyWebAuthnSecondFactor checks a passkey assertion given for a pending MFA challenge
+func (ctrl *Controller) VerifyWebAuthnSecondFactor(ctx context.Context, user *entity.User, challengeID, sessionID string, credential *WebAuthnAssertionCredential) (bool, error) {
+	if sessionID == "" || credential == nil {
+		return false, fmt.Errorf("passkey assertion is required")
+	}
+
+	session, err := ctrl.Repository.ConsumeWebAuthnSession(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if session.Ceremony != "mfa" || session.MFAChallengeID != challengeID || session.UserID != user.UserID.String() {
+		return false, fmt.Errorf("passkey session does not match the MFA challenge")
+	}
+
+	if _, err := ctrl.VerifyWebAuthnAssertion(ctx, session, credential, false); err != nil {
+		return false, err
+	}
+	return true, nil
+}
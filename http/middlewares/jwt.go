@@ -1,16 +1,24 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/tnqbao/gau-account-service/shared/config"
+	"github.com/tnqbao/gau-account-service/shared/entity"
 	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/repository"
 	"github.com/tnqbao/gau-account-service/shared/utils"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware(authProvider *provider.AuthorizationServiceProvider, config *config.EnvConfig) gin.HandlerFunc {
+func AuthMiddleware(authProvider *provider.AuthorizationServiceProvider, repo *repository.Repository, logger *provider.LoggerProvider, config *config.EnvConfig) gin.HandlerFunc {
+	verifier := utils.NewTokenVerifier(config)
+
 	return func(c *gin.Context) {
 		var tokenStr string
 
@@ -30,27 +38,43 @@ func AuthMiddleware(authProvider *provider.AuthorizationServiceProvider, config
 			return
 		}
 
-		if err := authProvider.CheckAccessToken(tokenStr); err != nil {
+		// Signature and expiry are checked locally, the authorization service is only asked about revocation
+		parsedToken, err := verifier.ParseToken(tokenStr)
+		if err != nil || !parsedToken.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		parsedToken, err := utils.ParseToken(tokenStr, config)
-		if err != nil || !parsedToken.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		claims, ok := parsedToken.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
 			c.Abort()
 			return
 		}
 
-		if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok {
-			if err := utils.InjectClaimsToContext(c, claims); err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid claims"})
-				c.Abort()
-				return
+		if status := checkRevocation(c, authProvider, repo, logger, config, tokenStr, claims); status != http.StatusOK {
+			if status == http.StatusServiceUnavailable {
+				c.JSON(status, gin.H{"error": "Authorization service is unavailable, please try again later"})
+			} else {
+				c.JSON(status, gin.H{"error": "Token has been revoked"})
+			}
+			c.Abort()
+			return
+		}
+
+		if err := utils.InjectClaimsToContext(c, claims); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid claims"})
+			c.Abort()
+			return
+		}
+
+		if status, code := checkUserStatus(c, repo, logger, config); status != http.StatusOK {
+			if status == http.StatusServiceUnavailable {
+				c.JSON(status, gin.H{"error": "Account status is unavailable, please try again later"})
+			} else {
+				c.JSON(status, gin.H{"error": "Account is not active", "error_code": code})
 			}
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
 			c.Abort()
 			return
 		}
@@ -58,3 +82,91 @@ func AuthMiddleware(authProvider *provider.AuthorizationServiceProvider, config
 		c.Next()
 	}
 }
+
+// checkRevocation asks the authorization service whether a locally valid token was revoked and caches
+// the answer for TOKEN_REVOCATION_CACHE_TTL (never past the token expiry). When the service cannot
+// answer, the configured fail policy decides.
+func checkRevocation(c *gin.Context, authProvider *provider.AuthorizationServiceProvider, repo *repository.Repository, logger *provider.LoggerProvider, config *config.EnvConfig, tokenStr string, claims jwt.MapClaims) int {
+	ctx := c.Request.Context()
+
+	status, err := repo.GetAccessTokenStatus(ctx, tokenStr)
+	if err != nil {
+		logger.ErrorWithContextf(ctx, err, "[Auth] Token status cache unavailable")
+	}
+	switch status {
+	case repository.AccessTokenActive:
+		return http.StatusOK
+	case repository.AccessTokenRevoked:
+		return http.StatusUnauthorized
+	}
+
+	ttl := time.Duration(config.TokenValidation.RevocationCacheTTL) * time.Second
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil && time.Until(exp.Time) < ttl {
+		ttl = time.Until(exp.Time)
+	}
+
+	err = authProvider.CheckAccessToken(tokenStr)
+	switch {
+	case err == nil:
+		status = repository.AccessTokenActive
+	case errors.Is(err, provider.ErrAuthorizationServiceUnavailable):
+		logger.WarningWithContextf(ctx, "[Auth] Revocation check failed, fail open: %v, error: %v", config.TokenValidation.FailOpen, err)
+		if config.TokenValidation.FailOpen {
+			return http.StatusOK
+		}
+		return http.StatusServiceUnavailable
+	default:
+		status = repository.AccessTokenRevoked
+	}
+
+	if err := repo.SetAccessTokenStatus(ctx, tokenStr, status, ttl); err != nil {
+		logger.ErrorWithContextf(ctx, err, "[Auth] Failed to cache token status")
+	}
+	if status == repository.AccessTokenRevoked {
+		return http.StatusUnauthorized
+	}
+	return http.StatusOK
+}
+
+// checkUserStatus rejects tokens of accounts that are not active, e.g. suspended or banned after the token
+// was issued. The effective status is cached for USER_STATUS_CACHE_TTL and dropped when it changes; a
+// suspension ending earlier shortens the cache entry. Database errors follow the token validation fail policy.
+func checkUserStatus(c *gin.Context, repo *repository.Repository, logger *provider.LoggerProvider, config *config.EnvConfig) (int, string) {
+	ctx := c.Request.Context()
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	status, err := repo.GetCachedUserStatus(ctx, userID)
+	if err != nil {
+		logger.ErrorWithContextf(ctx, err, "[Auth] User status cache unavailable")
+	}
+
+	if status == "" {
+		user, err := repo.GetUserStatus(userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Purged accounts are reported like deleted ones
+			user, err = &entity.User{UserID: userID, Status: entity.UserStatusDeleted}, nil
+		}
+		if err != nil {
+			logger.WarningWithContextf(ctx, "[Auth] Status check failed for user: %s, fail open: %v, error: %v", userID, config.TokenValidation.FailOpen, err)
+			if config.TokenValidation.FailOpen {
+				return http.StatusOK, ""
+			}
+			return http.StatusServiceUnavailable, ""
+		}
+
+		now := time.Now()
+		status = user.EffectiveStatus(now)
+		ttl := time.Duration(config.TokenValidation.UserStatusCacheTTL) * time.Second
+		if status == entity.UserStatusSuspended && user.SuspendedUntil != nil && user.SuspendedUntil.Sub(now) < ttl {
+			ttl = user.SuspendedUntil.Sub(now)
+		}
+		if err := repo.SetCachedUserStatus(ctx, userID, status, ttl); err != nil {
+			logger.ErrorWithContextf(ctx, err, "[Auth] Failed to cache status for user: %s", userID)
+		}
+	}
+
+	if status != entity.UserStatusActive {
+		return http.StatusForbidden, "account_" + status
+	}
+	return http.StatusOK, ""
+}
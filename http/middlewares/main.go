@@ -6,16 +6,20 @@ import (
 )
 
 type Middlewares struct {
-	CORSMiddleware gin.HandlerFunc
-	AuthMiddleware gin.HandlerFunc
+	CORSMiddleware    gin.HandlerFunc
+	AuthMiddleware    gin.HandlerFunc
+	RequirePermission func(permissions ...string) gin.HandlerFunc
 }
 
 func NewMiddlewares(ctrl *controller.Controller) (*Middlewares, error) {
 	cors := CORSMiddleware(ctrl.Config.EnvConfig)
-	auth := AuthMiddleware(ctrl.Provider.AuthorizationServiceProvider, ctrl.Config.EnvConfig)
+	auth := AuthMiddleware(ctrl.Provider.AuthorizationServiceProvider, ctrl.Repository, ctrl.Provider.LoggerProvider, ctrl.Config.EnvConfig)
+
+	requirePermission := RequirePermission(ctrl.Repository, ctrl.Provider.LoggerProvider, ctrl.Config.EnvConfig)
 
 	return &Middlewares{
-		CORSMiddleware: cors,
-		AuthMiddleware: auth,
+		CORSMiddleware:    cors,
+		AuthMiddleware:    auth,
+		RequirePermission: requirePermission,
 	}, nil
 }
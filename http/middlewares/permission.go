@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/config"
+	"github.com/tnqbao/gau-account-service/shared/provider"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+)
+
+// RequirePermission builds middlewares that only let through callers holding every listed permission.
+// It must run after AuthMiddleware. The scopes claim is not trusted since it outlives role changes until
+// the token expires; permissions come from the database, cached for PERMISSION_CACHE_TTL and dropped
+// whenever the user's roles change.
+func RequirePermission(repo *repository.Repository, logger *provider.LoggerProvider, config *config.EnvConfig) func(permissions ...string) gin.HandlerFunc {
+	return func(permissions ...string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			ctx := c.Request.Context()
+
+			userID, ok := c.Get("user_id")
+			id, isUUID := userID.(uuid.UUID)
+			if !ok || !isUUID {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+				c.Abort()
+				return
+			}
+
+			granted, found, err := repo.GetCachedUserPermissions(ctx, id)
+			if err != nil {
+				logger.ErrorWithContextf(ctx, err, "[Auth] Permission cache unavailable")
+			}
+			if !found {
+				granted, err = repo.GetUserPermissions(id)
+				if err != nil {
+					logger.ErrorWithContextf(ctx, err, "[Auth] Failed to load permissions for user: %s", id)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+					c.Abort()
+					return
+				}
+				ttl := time.Duration(config.TokenValidation.PermissionCacheTTL) * time.Second
+				if err := repo.SetCachedUserPermissions(ctx, id, granted, ttl); err != nil {
+					logger.ErrorWithContextf(ctx, err, "[Auth] Failed to cache permissions for user: %s", id)
+				}
+			}
+
+			held := make(map[string]bool, len(granted))
+			for _, permission := range granted {
+				held[permission] = true
+			}
+			for _, permission := range permissions {
+				if !held[permission] {
+					c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied", "required": permission})
+					c.Abort()
+					return
+				}
+			}
+
+			c.Next()
+		}
+	}
+}
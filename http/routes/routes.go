@@ -25,6 +25,28 @@ func SetupRouter(config *config.Config) *gin.Engine {
 		{
 			identifierRoutes.POST("/register", ctrl.RegisterWithIdentifierAndPassword)
 			identifierRoutes.POST("/login", ctrl.LoginWithIdentifierAndPassword)
+			identifierRoutes.POST("/password/forgot", ctrl.RequestPasswordReset)
+			identifierRoutes.POST("/password/reset", ctrl.ResetPassword)
+		}
+
+		// Email change links, opened from the mailbox without a session
+		emailChangeRoutes := apiRoutes.Group("/email-change")
+		{
+			emailChangeRoutes.POST("/confirm", ctrl.ConfirmEmailChange)
+			emailChangeRoutes.POST("/revert", ctrl.RevertEmailChange)
+		}
+
+		// Cancel link of a scheduled account deletion, opened from the mailbox without a session
+		apiRoutes.POST("/account-deletion/cancel", ctrl.CancelAccountDeletion)
+
+		// Single-use download link of a personal data export
+		apiRoutes.POST("/data-export/download", ctrl.DownloadDataExport)
+
+		// Passwordless login by email link or code
+		magicRoutes := apiRoutes.Group("/magic")
+		{
+			magicRoutes.POST("/send", ctrl.SendMagicLogin)
+			magicRoutes.POST("/verify", ctrl.VerifyMagicLogin)
 		}
 
 		// Email verification routes
@@ -52,6 +74,26 @@ func SetupRouter(config *config.Config) *gin.Engine {
 
 			// Avatar upload endpoint
 			profileRoutes.PATCH("/avatar", ctrl.UpdateAvatarImage)
+
+			// Password change (or first password for SSO accounts)
+			profileRoutes.PUT("/password", ctrl.ChangePassword)
+			profileRoutes.POST("/password/code", ctrl.SendPasswordSetupCode)
+
+			// Phone number verification over SMS
+			profileRoutes.POST("/phone/send-verification", ctrl.SendPhoneVerification)
+			profileRoutes.POST("/phone/verify", ctrl.VerifyPhone)
+			profileRoutes.POST("/email/change", ctrl.ChangeEmail)
+
+			// Signed-in devices
+			profileRoutes.GET("/sessions", ctrl.ListSessions)
+			profileRoutes.DELETE("/sessions/:device_id", ctrl.RevokeSession)
+			profileRoutes.POST("/sessions/revoke-others", ctrl.RevokeOtherSessions)
+			profileRoutes.GET("/login-history", ctrl.GetLoginHistory)
+			profileRoutes.POST("/delete", ctrl.RequestAccountDeletion)
+
+			// Personal data export, built by the consumer and sent by email
+			profileRoutes.POST("/data-export", ctrl.RequestDataExport)
+			profileRoutes.GET("/data-export", ctrl.GetDataExportStatus)
 		}
 
 		mfaRoutes := apiRoutes.Group("/mfa")
@@ -60,11 +102,70 @@ func SetupRouter(config *config.Config) *gin.Engine {
 			// TOTP endpoints
 			mfaRoutes.GET("/totp/qr", ctrl.GenerateTOTPQR)
 			mfaRoutes.POST("/totp/enable", ctrl.EnableTOTP)
-			mfaRoutes.POST("/totp/verify", ctrl.VerifyTOTP)
+			mfaRoutes.POST("/totp/disable", ctrl.DisableTOTP)
+			mfaRoutes.POST("/totp/reset", ctrl.ResetTOTP)
+			// Email OTP endpoints
+			mfaRoutes.POST("/email/setup", ctrl.SetupEmailOTP)
+			mfaRoutes.POST("/email/enable", ctrl.EnableEmailOTP)
+			mfaRoutes.POST("/email/disable", ctrl.DisableEmailOTP)
+			mfaRoutes.POST("/email/send", ctrl.SendEmailOTP)
+			// SMS OTP endpoints
+			mfaRoutes.POST("/sms/setup", ctrl.SetupSMSOTP)
+			mfaRoutes.POST("/sms/enable", ctrl.EnableSMSOTP)
+			mfaRoutes.POST("/sms/disable", ctrl.DisableSMSOTP)
+			mfaRoutes.POST("/sms/send", ctrl.SendSMSOTP)
+			// Passkeys (WebAuthn)
+			mfaRoutes.POST("/webauthn/register/begin", ctrl.BeginWebAuthnRegistration)
+			mfaRoutes.POST("/webauthn/register/finish", ctrl.FinishWebAuthnRegistration)
+			mfaRoutes.GET("/webauthn/credentials", ctrl.ListWebAuthnCredentials)
+			mfaRoutes.PATCH("/webauthn/credentials/:id", ctrl.RenameWebAuthnCredential)
+			mfaRoutes.DELETE("/webauthn/credentials/:id", ctrl.DeleteWebAuthnCredential)
+			// Recovery codes
+			mfaRoutes.GET("/recovery-codes", ctrl.GetRecoveryCodesStatus)
+			mfaRoutes.POST("/recovery-codes/regenerate", ctrl.RegenerateRecoveryCodes)
+		}
+
+		// Second login step for accounts with MFA enabled (no access token yet)
+		mfaChallengeRoutes := apiRoutes.Group("/mfa/challenge")
+		{
+			mfaChallengeRoutes.POST("/send", ctrl.SendMFAChallengeCode)
+			mfaChallengeRoutes.POST("/webauthn", ctrl.BeginWebAuthnMFAChallenge)
+			mfaChallengeRoutes.POST("/complete", ctrl.CompleteMFAChallenge)
+		}
+
+		// Passwordless passkey login
+		webAuthnRoutes := apiRoutes.Group("/webauthn")
+		{
+			webAuthnRoutes.POST("/login/begin", ctrl.BeginWebAuthnLogin)
+			webAuthnRoutes.POST("/login/finish", ctrl.FinishWebAuthnLogin)
 		}
 
 		apiRoutes.POST("/logout", useMiddlewares.AuthMiddleware, ctrl.Logout)
 
+		// Works with an expired access token, only the refresh token is checked
+		apiRoutes.POST("/token/refresh", ctrl.RefreshToken)
+
+		// Administration, every route also requires a permission from the caller's roles
+		adminRoutes := apiRoutes.Group("/admin")
+		{
+			adminRoutes.Use(useMiddlewares.AuthMiddleware)
+			adminRoutes.GET("/roles", useMiddlewares.RequirePermission("roles:manage"), ctrl.ListRoles)
+			adminRoutes.GET("/users/:user_id/roles", useMiddlewares.RequirePermission("roles:manage"), ctrl.GetUserRoles)
+			adminRoutes.POST("/users/:user_id/roles", useMiddlewares.RequirePermission("roles:manage"), ctrl.GrantUserRole)
+			adminRoutes.DELETE("/users/:user_id/roles/:role", useMiddlewares.RequirePermission("roles:manage"), ctrl.RevokeUserRole)
+
+			adminRoutes.GET("/users", useMiddlewares.RequirePermission("users:read"), ctrl.AdminListUsers)
+			adminRoutes.GET("/users/:user_id", useMiddlewares.RequirePermission("users:read"), ctrl.AdminGetUser)
+			adminRoutes.PUT("/users/:user_id", useMiddlewares.RequirePermission("users:write"), ctrl.AdminUpdateUser)
+			adminRoutes.PUT("/users/:user_id/permission", useMiddlewares.RequirePermission("roles:manage"), ctrl.AdminUpdateUserPermission)
+			adminRoutes.POST("/users/:user_id/email/verify", useMiddlewares.RequirePermission("users:write"), ctrl.AdminVerifyUserEmail)
+			adminRoutes.POST("/users/:user_id/mfa/reset", useMiddlewares.RequirePermission("users:write"), ctrl.AdminResetUserMFA)
+			adminRoutes.POST("/users/:user_id/sessions/revoke", useMiddlewares.RequirePermission("users:write"), ctrl.AdminRevokeUserSessions)
+			adminRoutes.PUT("/users/:user_id/status", useMiddlewares.RequirePermission("users:write"), ctrl.AdminUpdateUserStatus)
+
+			adminRoutes.GET("/audit-logs", useMiddlewares.RequirePermission("audit:read"), ctrl.AdminListAuditLogs)
+		}
+
 		ssoRoutes := apiRoutes.Group("/sso")
 		{
 			ssoRoutes.POST("/google", ctrl.LoginWithGoogle)
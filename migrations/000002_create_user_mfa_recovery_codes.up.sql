@@ -0,0 +1,13 @@
+-- One-time MFA recovery codes, only SHA-256 hashes are stored
+CREATE TABLE user_mfa_recovery_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user_mfa_recovery_codes_user_id FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_mfa_recovery_codes_user_id ON user_mfa_recovery_codes(user_id);
+CREATE UNIQUE INDEX idx_user_mfa_recovery_codes_user_code ON user_mfa_recovery_codes(user_id, code_hash);
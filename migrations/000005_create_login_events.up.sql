@@ -0,0 +1,19 @@
+-- Successful and failed login attempts, user_id is NULL when the account could not be resolved
+CREATE TABLE login_events (
+    id UUID PRIMARY KEY,
+    user_id UUID,
+    identifier VARCHAR(255),
+    method VARCHAR(30),
+    success BOOLEAN NOT NULL DEFAULT FALSE,
+    failure_reason VARCHAR(50),
+    ip_address VARCHAR(64),
+    network VARCHAR(64),
+    user_agent VARCHAR(512),
+    device_id VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_login_events_user_id FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_login_events_user_id_created_at ON login_events(user_id, created_at DESC);
+CREATE INDEX idx_login_events_network ON login_events(network);
@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE name = 'audit:read';
+DROP TABLE IF EXISTS admin_audit_logs;
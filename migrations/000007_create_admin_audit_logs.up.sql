@@ -0,0 +1,20 @@
+-- Actions taken through the admin API, no foreign keys so entries survive account deletion
+CREATE TABLE admin_audit_logs (
+    id UUID PRIMARY KEY,
+    admin_id UUID NOT NULL,
+    target_user_id UUID,
+    action VARCHAR(50) NOT NULL,
+    details TEXT,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_admin_audit_logs_admin_id ON admin_audit_logs(admin_id);
+CREATE INDEX idx_admin_audit_logs_target_user_id ON admin_audit_logs(target_user_id);
+CREATE INDEX idx_admin_audit_logs_created_at ON admin_audit_logs(created_at);
+
+INSERT INTO permissions (id, name, description) VALUES
+    (gen_random_uuid(), 'audit:read', 'View the admin audit log');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'audit:read' WHERE r.name = 'admin';
@@ -0,0 +1,8 @@
+-- Account lifecycle: pending, active, suspended, banned, deleted
+ALTER TABLE users
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason VARCHAR(500),
+    ADD COLUMN suspended_until TIMESTAMP,
+    ADD COLUMN status_changed_at TIMESTAMP;
+
+CREATE INDEX idx_users_status ON users(status);
@@ -0,0 +1,7 @@
+-- Digits and algorithm a TOTP secret was enrolled with, so changing TOTP_DIGITS or TOTP_ALGORITHM
+-- only affects new enrolments. Existing secrets were generated with the defaults.
+ALTER TABLE user_mfas
+    ADD COLUMN totp_digits SMALLINT,
+    ADD COLUMN totp_algorithm VARCHAR(10);
+
+UPDATE user_mfas SET totp_digits = 6, totp_algorithm = 'SHA1' WHERE type = 'totp';
@@ -0,0 +1,10 @@
+-- Work left after a purge outside the database, recorded in the purge transaction and retried by the consumer
+ALTER TABLE users
+    ADD COLUMN purge_avatar_url VARCHAR(255),
+    ADD COLUMN deletion_event_published_at TIMESTAMP;
+
+-- Accounts purged before were handled on the spot
+UPDATE users SET deletion_event_published_at = purged_at WHERE purged_at IS NOT NULL;
+
+CREATE INDEX idx_users_purge_pending ON users(purged_at)
+    WHERE purged_at IS NOT NULL AND (deletion_event_published_at IS NULL OR purge_avatar_url IS NOT NULL);
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/tnqbao/gau-account-service/shared/config"
+	"github.com/tnqbao/gau-account-service/shared/infra"
+	"github.com/tnqbao/gau-account-service/shared/repository"
+	"github.com/tnqbao/gau-account-service/shared/utils"
+)
+
+// Re-encrypts every UserMFA secret and every refresh token in the session index with the active
+// SECRET_ENCRYPTION key. Run it after adding a new key and making it active; retired keys can be removed
+// from config once it reports no failures.
+func main() {
+	batchSize := flag.Int("batch", 500, "rows fetched per query")
+	dryRun := flag.Bool("dry-run", false, "only count rows that need re-encryption")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	cfg := config.NewConfig()
+
+	secretCipher, err := utils.NewSecretCipher(cfg.EnvConfig)
+	if err != nil {
+		log.Fatalf("Invalid secret encryption config: %v", err)
+	}
+	if secretCipher.ActiveKeyID == "" {
+		log.Fatal("SECRET_ENCRYPTION_KEYS is empty, nothing to encrypt with")
+	}
+
+	repo := repository.InitRepository(&infra.Infra{
+		Postgres: infra.InitPostgresClient(cfg.EnvConfig),
+		Redis:    infra.InitRedisClient(cfg.EnvConfig),
+	})
+
+	log.Printf("Re-encrypting with key %q (dry run: %v)", secretCipher.ActiveKeyID, *dryRun)
+
+	mfaFailed := reencryptMFASecrets(repo, secretCipher, *batchSize, *dryRun)
+	sessionFailed := reencryptSessionTokens(repo, secretCipher, *batchSize, *dryRun)
+	if mfaFailed > 0 || sessionFailed > 0 {
+		os.Exit(1)
+	}
+}
+
+// reencryptMFASecrets moves every UserMFA secret to the active key and returns the number of failures
+func reencryptMFASecrets(repo *repository.Repository, secretCipher *utils.SecretCipher, batchSize int, dryRun bool) int {
+	var scanned, updated, skipped, failed int
+	afterID := uuid.Nil
+	for {
+		mfas, err := repo.GetUserMFAsWithSecretAfter(afterID, batchSize)
+		if err != nil {
+			log.Fatalf("Failed to load MFA records after %s: %v", afterID, err)
+		}
+		if len(mfas) == 0 {
+			break
+		}
+
+		for _, mfa := range mfas {
+			afterID = mfa.ID
+			scanned++
+
+			stored := *mfa.Secret
+			if !secretCipher.NeedsReencryption(stored) {
+				continue
+			}
+			if dryRun {
+				updated++
+				continue
+			}
+
+			associatedData := utils.MFASecretAssociatedData(mfa.ID)
+			plaintext, err := secretCipher.Decrypt(stored, associatedData)
+			if err != nil {
+				log.Printf("Failed to decrypt secret of MFA %s: %v", mfa.ID, err)
+				failed++
+				continue
+			}
+			sealed, err := secretCipher.Encrypt(plaintext, associatedData)
+			if err != nil {
+				log.Printf("Failed to encrypt secret of MFA %s: %v", mfa.ID, err)
+				failed++
+				continue
+			}
+
+			replaced, err := repo.ReplaceUserMFASecret(mfa.ID, stored, sealed)
+			if err != nil {
+				log.Printf("Failed to store secret of MFA %s: %v", mfa.ID, err)
+				failed++
+				continue
+			}
+			if !replaced {
+				// Changed since it was read, the new value was written with the active key
+				skipped++
+				continue
+			}
+			updated++
+		}
+	}
+
+	log.Printf("MFA secrets: scanned %d, re-encrypted %d, changed concurrently %d, failed %d", scanned, updated, skipped, failed)
+	return failed
+}
+
+// reencryptSessionTokens re-seals the refresh tokens of the session index with the active key and returns the
+// number of failures. Entries from before the index was hashed hold a raw token, no key to retire there.
+func reencryptSessionTokens(repo *repository.Repository, secretCipher *utils.SecretCipher, batchSize int, dryRun bool) int {
+	ctx := context.Background()
+
+	var scanned, updated, skipped, failed int
+	var cursor uint64
+	for {
+		userIDs, next, err := repo.ScanUserSessionOwners(ctx, cursor, int64(batchSize))
+		if err != nil {
+			log.Fatalf("Failed to scan the session index: %v", err)
+		}
+
+		for _, userID := range userIDs {
+			sessions, err := repo.GetUserSessions(ctx, userID)
+			if err != nil {
+				log.Printf("Failed to load sessions of user %s: %v", userID, err)
+				failed++
+				continue
+			}
+
+			for deviceID, session := range sessions {
+				scanned++
+
+				stored := session.SealedToken
+				if !utils.IsEncryptedSecret(stored) || !secretCipher.NeedsReencryption(stored) {
+					continue
+				}
+				if dryRun {
+					updated++
+					continue
+				}
+
+				associatedData := utils.SessionTokenAssociatedData(userID, deviceID)
+				refreshToken, err := secretCipher.Decrypt(stored, associatedData)
+				if err != nil {
+					log.Printf("Failed to open session token of user %s, device %s: %v", userID, deviceID, err)
+					failed++
+					continue
+				}
+				session.SealedToken, err = secretCipher.Encrypt(refreshToken, associatedData)
+				if err != nil {
+					log.Printf("Failed to seal session token of user %s, device %s: %v", userID, deviceID, err)
+					failed++
+					continue
+				}
+
+				replaced, err := repo.ReplaceUserSessionToken(ctx, userID, deviceID, stored, session)
+				if err != nil {
+					log.Printf("Failed to store session token of user %s, device %s: %v", userID, deviceID, err)
+					failed++
+					continue
+				}
+				if !replaced {
+					// Signed in again or out since it was read, a new entry is sealed with the active key
+					skipped++
+					continue
+				}
+				updated++
+			}
+		}
+
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	log.Printf("Session tokens: scanned %d, re-encrypted %d, changed concurrently %d, failed %d", scanned, updated, skipped, failed)
+	return failed
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -15,9 +18,75 @@ type EnvConfig struct {
 		Port     string
 	}
 	JWT struct {
-		SecretKey string
-		Algorithm string
-		Expire    int
+		SecretKey           string
+		Algorithm           string
+		Algorithms          []string // accepted signing algorithms, parsed from Algorithm
+		Expire              int
+		JWKSURL             string // verification keys for RS256 / ES256 / EdDSA
+		JWKSRefreshInterval int    // seconds between JWKS refreshes
+	}
+	TokenValidation struct {
+		RevocationCacheTTL int  // seconds an authorization service answer is reused for the same token
+		FailOpen           bool // accept locally valid tokens when the authorization service is unreachable
+		UserStatusCacheTTL int  // seconds the account status of a token owner is cached
+		PermissionCacheTTL int  // seconds the permissions of a token owner are cached
+	}
+	Registration struct {
+		RequireEmailVerification bool // accounts registered with an email stay pending until it is verified
+	}
+	AccountDeletion struct {
+		GracePeriodDays int // days between a deletion request and the purge, the user can cancel meanwhile
+		PurgeInterval   int // seconds between runs of the purge job in the consumer
+	}
+	DataExport struct {
+		LinkTTL         int // seconds the download link of a finished export stays valid
+		RequestInterval int // seconds a user has to wait between two export requests
+	}
+	Password struct {
+		Algorithm         string
+		Argon2Memory      uint32
+		Argon2Iterations  uint32
+		Argon2Parallelism uint8
+		BcryptCost        int
+		MinLength         int
+		MaxLength         int
+	}
+	LoginProtection struct {
+		FailureWindow    int // seconds
+		DelayThreshold   int
+		MaxDelay         int // seconds
+		LockThreshold    int
+		LockDuration     int // seconds
+		IPDelayThreshold int
+	}
+	TOTP struct {
+		Skew      uint // accepted 30-second steps before and after the current one
+		Digits    int
+		Algorithm string // "SHA1" | "SHA256" | "SHA512"
+	}
+	SecretEncryption struct {
+		Keys        map[string]string // key ID -> base64 encoded 256-bit AES key
+		ActiveKeyID string            // key used for new ciphertexts
+	}
+	SMS struct {
+		Provider         string // "rabbitmq" | "log"
+		MaxPerNumberHour int
+		MaxPerNumberDay  int
+	}
+	WebAuthn struct {
+		RPID    string
+		RPName  string
+		Origins []string
+	}
+	Session struct {
+		RememberTTL int // seconds, refresh token lifetime with "keep me logged in"
+		DefaultTTL  int // seconds, refresh token lifetime otherwise
+	}
+	Cookie struct {
+		Domain   string
+		Path     string
+		Secure   bool
+		SameSite string // "lax" | "strict" | "none"
 	}
 	CORS struct {
 		AllowDomains string
@@ -50,6 +119,8 @@ type EnvConfig struct {
 		Mode  string
 		Group string
 	}
+
+	invalid []error // values that could not be parsed, reported by Validate
 }
 
 func LoadEnvConfig() *EnvConfig {
@@ -65,12 +136,140 @@ func LoadEnvConfig() *EnvConfig {
 	// JWT
 	config.JWT.SecretKey = os.Getenv("JWT_SECRET_KEY")
 	config.JWT.Algorithm = os.Getenv("JWT_ALGORITHM")
+	if config.JWT.Algorithm == "" {
+		config.JWT.Algorithm = "HS256"
+	}
+	// A comma-separated list accepts several algorithms, e.g. "HS256,RS256" while moving off the shared secret
+	for _, alg := range strings.Split(config.JWT.Algorithm, ",") {
+		if alg = strings.TrimSpace(alg); alg != "" {
+			config.JWT.Algorithms = append(config.JWT.Algorithms, alg)
+		}
+	}
 
 	if val := os.Getenv("JWT_EXPIRE"); val != "" {
 		fmt.Sscanf(val, "%d", &config.JWT.Expire)
 	} else {
 		config.JWT.Expire = 3600 * 24 * 7
 	}
+	if val := os.Getenv("JWT_JWKS_REFRESH_INTERVAL"); val != "" {
+		fmt.Sscanf(val, "%d", &config.JWT.JWKSRefreshInterval)
+	} else {
+		config.JWT.JWKSRefreshInterval = 3600
+	}
+
+	// Access tokens are verified locally, the revocation check against the authorization service is cached
+	if val := os.Getenv("TOKEN_REVOCATION_CACHE_TTL"); val != "" {
+		fmt.Sscanf(val, "%d", &config.TokenValidation.RevocationCacheTTL)
+	} else {
+		config.TokenValidation.RevocationCacheTTL = 30
+	}
+	config.TokenValidation.FailOpen = strings.ToLower(os.Getenv("TOKEN_VALIDATION_FAIL_POLICY")) == "open"
+	if val := os.Getenv("USER_STATUS_CACHE_TTL"); val != "" {
+		fmt.Sscanf(val, "%d", &config.TokenValidation.UserStatusCacheTTL)
+	} else {
+		config.TokenValidation.UserStatusCacheTTL = 30
+	}
+	if !config.envNumber("PERMISSION_CACHE_TTL", &config.TokenValidation.PermissionCacheTTL) {
+		config.TokenValidation.PermissionCacheTTL = 30
+	}
+
+	config.Registration.RequireEmailVerification = strings.ToLower(os.Getenv("REGISTRATION_REQUIRE_EMAIL_VERIFICATION")) == "true"
+
+	// Account deletion
+	if val := os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"); val != "" {
+		fmt.Sscanf(val, "%d", &config.AccountDeletion.GracePeriodDays)
+	} else {
+		config.AccountDeletion.GracePeriodDays = 30
+	}
+	if val := os.Getenv("ACCOUNT_PURGE_INTERVAL"); val != "" {
+		fmt.Sscanf(val, "%d", &config.AccountDeletion.PurgeInterval)
+	} else {
+		config.AccountDeletion.PurgeInterval = 3600
+	}
+
+	// Personal data export
+	if val := os.Getenv("DATA_EXPORT_LINK_TTL"); val != "" {
+		fmt.Sscanf(val, "%d", &config.DataExport.LinkTTL)
+	} else {
+		config.DataExport.LinkTTL = 172800
+	}
+	if val := os.Getenv("DATA_EXPORT_REQUEST_INTERVAL"); val != "" {
+		fmt.Sscanf(val, "%d", &config.DataExport.RequestInterval)
+	} else {
+		config.DataExport.RequestInterval = 86400
+	}
+
+	// Password hashing
+	config.Password.Algorithm = strings.ToLower(os.Getenv("PASSWORD_HASH_ALGORITHM"))
+	if config.Password.Algorithm == "" {
+		config.Password.Algorithm = "argon2id"
+	}
+	if !config.envNumber("PASSWORD_ARGON2_MEMORY", &config.Password.Argon2Memory) {
+		config.Password.Argon2Memory = 64 * 1024
+	}
+	if !config.envNumber("PASSWORD_ARGON2_ITERATIONS", &config.Password.Argon2Iterations) {
+		config.Password.Argon2Iterations = 3
+	}
+	if !config.envNumber("PASSWORD_ARGON2_PARALLELISM", &config.Password.Argon2Parallelism) {
+		config.Password.Argon2Parallelism = 2
+	}
+	if !config.envNumber("PASSWORD_BCRYPT_COST", &config.Password.BcryptCost) {
+		config.Password.BcryptCost = 12
+	}
+	if !config.envNumber("PASSWORD_MIN_LENGTH", &config.Password.MinLength) {
+		config.Password.MinLength = 8
+	}
+	if !config.envNumber("PASSWORD_MAX_LENGTH", &config.Password.MaxLength) {
+		config.Password.MaxLength = 128
+	}
+
+	// Brute-force protection for basic login
+	if !config.envNumber("LOGIN_FAILURE_WINDOW", &config.LoginProtection.FailureWindow) {
+		config.LoginProtection.FailureWindow = 15 * 60
+	}
+	if !config.envNumber("LOGIN_DELAY_THRESHOLD", &config.LoginProtection.DelayThreshold) {
+		config.LoginProtection.DelayThreshold = 3
+	}
+	if !config.envNumber("LOGIN_MAX_DELAY", &config.LoginProtection.MaxDelay) {
+		config.LoginProtection.MaxDelay = 60
+	}
+	if !config.envNumber("LOGIN_LOCK_THRESHOLD", &config.LoginProtection.LockThreshold) {
+		config.LoginProtection.LockThreshold = 10
+	}
+	if !config.envNumber("LOGIN_LOCK_DURATION", &config.LoginProtection.LockDuration) {
+		config.LoginProtection.LockDuration = 30 * 60
+	}
+	if !config.envNumber("LOGIN_IP_DELAY_THRESHOLD", &config.LoginProtection.IPDelayThreshold) {
+		config.LoginProtection.IPDelayThreshold = 20
+	}
+
+	// TOTP, digits and algorithm are stored per secret at enrolment so changing them only affects new secrets
+	if !config.envNumber("TOTP_SKEW", &config.TOTP.Skew) {
+		config.TOTP.Skew = 1
+	}
+	if !config.envNumber("TOTP_DIGITS", &config.TOTP.Digits) {
+		config.TOTP.Digits = 6
+	}
+	config.TOTP.Algorithm = strings.ToUpper(os.Getenv("TOTP_ALGORITHM"))
+	if config.TOTP.Algorithm == "" {
+		config.TOTP.Algorithm = "SHA1"
+	}
+
+	// Envelope encryption for secret columns, the first listed key is active unless set explicitly
+	config.SecretEncryption.Keys = map[string]string{}
+	for _, entry := range strings.Split(os.Getenv("SECRET_ENCRYPTION_KEYS"), ",") {
+		id, key, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" || key == "" {
+			continue
+		}
+		config.SecretEncryption.Keys[id] = key
+		if config.SecretEncryption.ActiveKeyID == "" {
+			config.SecretEncryption.ActiveKeyID = id
+		}
+	}
+	if val := os.Getenv("SECRET_ENCRYPTION_ACTIVE_KEY"); val != "" {
+		config.SecretEncryption.ActiveKeyID = val
+	}
 
 	config.CORS.AllowDomains = os.Getenv("ALLOWED_DOMAINS")
 	config.CORS.GlobalDomain = os.Getenv("GLOBAL_DOMAIN")
@@ -79,6 +278,45 @@ func LoadEnvConfig() *EnvConfig {
 		config.CORS.DomainName = "gauas.online"
 	}
 
+	// Session lifetimes and auth cookies, the cookie domain follows GLOBAL_DOMAIN unless overridden
+	if !config.envNumber("SESSION_REMEMBER_TTL", &config.Session.RememberTTL) {
+		config.Session.RememberTTL = 30 * 24 * 3600
+	}
+	if !config.envNumber("SESSION_DEFAULT_TTL", &config.Session.DefaultTTL) {
+		config.Session.DefaultTTL = 24 * 3600
+	}
+	config.Cookie.Domain = os.Getenv("COOKIE_DOMAIN")
+	if config.Cookie.Domain == "" {
+		config.Cookie.Domain = config.CORS.GlobalDomain
+	}
+	config.Cookie.Path = os.Getenv("COOKIE_PATH")
+	if config.Cookie.Path == "" {
+		config.Cookie.Path = "/"
+	}
+	config.Cookie.SameSite = strings.ToLower(os.Getenv("COOKIE_SAMESITE"))
+	if config.Cookie.SameSite == "" {
+		config.Cookie.SameSite = "lax"
+	}
+
+	// WebAuthn relying party, defaults to the public domain
+	config.WebAuthn.RPID = os.Getenv("WEBAUTHN_RP_ID")
+	if config.WebAuthn.RPID == "" {
+		config.WebAuthn.RPID = config.CORS.DomainName
+	}
+	config.WebAuthn.RPName = os.Getenv("WEBAUTHN_RP_NAME")
+	if config.WebAuthn.RPName == "" {
+		config.WebAuthn.RPName = "Gauas"
+	}
+	if val := os.Getenv("WEBAUTHN_ORIGINS"); val != "" {
+		for _, origin := range strings.Split(val, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				config.WebAuthn.Origins = append(config.WebAuthn.Origins, origin)
+			}
+		}
+	} else {
+		config.WebAuthn.Origins = []string{"https://" + config.CORS.DomainName}
+	}
+
 	// Redis
 	redisHost := os.Getenv("REDIS_HOST")
 	if redisHost == "" {
@@ -120,6 +358,10 @@ func LoadEnvConfig() *EnvConfig {
 	if config.ExternalService.AuthorizationServiceURL == "" {
 		config.ExternalService.AuthorizationServiceURL = "http://localhost:8080"
 	}
+	config.JWT.JWKSURL = os.Getenv("JWT_JWKS_URL")
+	if config.JWT.JWKSURL == "" {
+		config.JWT.JWKSURL = config.ExternalService.AuthorizationServiceURL + "/api/v2/authorization/.well-known/jwks.json"
+	}
 	config.ExternalService.UploadServiceURL = os.Getenv("UPLOAD_SERVICE_URL")
 	if config.ExternalService.UploadServiceURL == "" {
 		config.ExternalService.UploadServiceURL = "http://localhost:8081"
@@ -157,5 +399,168 @@ func LoadEnvConfig() *EnvConfig {
 		config.Environment.Group = "local"
 	}
 
+	// Secure cookies by default in production, SameSite=None always needs them
+	if val := os.Getenv("COOKIE_SECURE"); val != "" {
+		config.Cookie.Secure = strings.ToLower(val) == "true"
+	} else {
+		config.Cookie.Secure = config.Environment.Mode == "production"
+	}
+	if config.Cookie.SameSite == "none" {
+		config.Cookie.Secure = true
+	}
+
+	// SMS delivery, development environments only log the messages unless told otherwise
+	config.SMS.Provider = strings.ToLower(os.Getenv("SMS_PROVIDER"))
+	if config.SMS.Provider == "" {
+		if config.Environment.Mode == "production" {
+			config.SMS.Provider = "rabbitmq"
+		} else {
+			config.SMS.Provider = "log"
+		}
+	}
+	if val := os.Getenv("SMS_MAX_PER_NUMBER_HOUR"); val != "" {
+		fmt.Sscanf(val, "%d", &config.SMS.MaxPerNumberHour)
+	} else {
+		config.SMS.MaxPerNumberHour = 5
+	}
+	if val := os.Getenv("SMS_MAX_PER_NUMBER_DAY"); val != "" {
+		fmt.Sscanf(val, "%d", &config.SMS.MaxPerNumberDay)
+	} else {
+		config.SMS.MaxPerNumberDay = 10
+	}
+
 	return &config
 }
+
+// envNumber reads an integer variable into target. It returns false when the variable is unset so the caller
+// can apply its default; a value that does not parse or fit is recorded and reported by Validate.
+func (config *EnvConfig) envNumber(name string, target any) bool {
+	val := strings.TrimSpace(os.Getenv(name))
+	if val == "" {
+		return false
+	}
+
+	var err error
+	switch t := target.(type) {
+	case *int:
+		var n int64
+		if n, err = strconv.ParseInt(val, 10, 0); err == nil {
+			*t = int(n)
+		}
+	case *uint:
+		var n uint64
+		if n, err = strconv.ParseUint(val, 10, 0); err == nil {
+			*t = uint(n)
+		}
+	case *uint32:
+		var n uint64
+		if n, err = strconv.ParseUint(val, 10, 32); err == nil {
+			*t = uint32(n)
+		}
+	case *uint8:
+		var n uint64
+		if n, err = strconv.ParseUint(val, 10, 8); err == nil {
+			*t = uint8(n)
+		}
+	default:
+		err = fmt.Errorf("unsupported target %T", target)
+	}
+	if err != nil {
+		config.invalid = append(config.invalid, fmt.Errorf("%s=%q is not a valid number", name, val))
+	}
+	return true
+}
+
+// Validate reports settings the service cannot run with, so a bad deploy fails at startup
+// instead of on the first request that uses them
+func (config *EnvConfig) Validate() error {
+	errs := append([]error{}, config.invalid...)
+
+	// Password hashing, argon2.IDKey panics on zero iterations or parallelism
+	switch config.Password.Algorithm {
+	case "argon2id", "bcrypt":
+	default:
+		errs = append(errs, fmt.Errorf("PASSWORD_HASH_ALGORITHM must be argon2id or bcrypt, got %q", config.Password.Algorithm))
+	}
+	if config.Password.Argon2Iterations < 1 {
+		errs = append(errs, errors.New("PASSWORD_ARGON2_ITERATIONS must be at least 1"))
+	}
+	if config.Password.Argon2Parallelism < 1 {
+		errs = append(errs, errors.New("PASSWORD_ARGON2_PARALLELISM must be at least 1"))
+	}
+	if config.Password.Argon2Memory < 8*uint32(config.Password.Argon2Parallelism) {
+		errs = append(errs, errors.New("PASSWORD_ARGON2_MEMORY must be at least 8 KiB per degree of parallelism"))
+	}
+	if config.Password.BcryptCost < 4 || config.Password.BcryptCost > 31 {
+		errs = append(errs, errors.New("PASSWORD_BCRYPT_COST must be between 4 and 31"))
+	}
+	if config.Password.MinLength < 1 || config.Password.MaxLength < config.Password.MinLength {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be at least 1 and not above PASSWORD_MAX_LENGTH"))
+	}
+	// bcrypt only looks at the first 72 bytes and refuses longer input
+	if config.Password.Algorithm == "bcrypt" && config.Password.MaxLength > 72 {
+		errs = append(errs, errors.New("PASSWORD_MAX_LENGTH cannot exceed 72 with bcrypt"))
+	}
+
+	// Brute-force protection, a zero threshold would delay or lock every login and a zero window none
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"LOGIN_FAILURE_WINDOW", config.LoginProtection.FailureWindow},
+		{"LOGIN_DELAY_THRESHOLD", config.LoginProtection.DelayThreshold},
+		{"LOGIN_MAX_DELAY", config.LoginProtection.MaxDelay},
+		{"LOGIN_LOCK_THRESHOLD", config.LoginProtection.LockThreshold},
+		{"LOGIN_LOCK_DURATION", config.LoginProtection.LockDuration},
+		{"LOGIN_IP_DELAY_THRESHOLD", config.LoginProtection.IPDelayThreshold},
+	} {
+		if setting.value < 1 {
+			errs = append(errs, fmt.Errorf("%s must be at least 1, got %d", setting.name, setting.value))
+		}
+	}
+
+	// A session that expires as it is created would sign every login straight out
+	if config.Session.RememberTTL < 1 {
+		errs = append(errs, fmt.Errorf("SESSION_REMEMBER_TTL must be at least 1, got %d", config.Session.RememberTTL))
+	}
+	if config.Session.DefaultTTL < 1 {
+		errs = append(errs, fmt.Errorf("SESSION_DEFAULT_TTL must be at least 1, got %d", config.Session.DefaultTTL))
+	}
+
+	// Outside development an unknown provider would silently log codes instead of texting them
+	switch config.SMS.Provider {
+	case "rabbitmq", "log":
+	default:
+		if config.Environment.Mode != "development" {
+			errs = append(errs, fmt.Errorf("SMS_PROVIDER must be rabbitmq or log, got %q", config.SMS.Provider))
+		}
+	}
+
+	// Without a step of tolerance a code typed near the end of its 30 seconds is already rejected
+	if config.TOTP.Skew < 1 {
+		errs = append(errs, fmt.Errorf("TOTP_SKEW must be at least 1, got %d", config.TOTP.Skew))
+	}
+	// Authenticator apps only display 6 or 8 digits, anything else would silently enrol as 6
+	if config.TOTP.Digits != 6 && config.TOTP.Digits != 8 {
+		errs = append(errs, fmt.Errorf("TOTP_DIGITS must be 6 or 8, got %d", config.TOTP.Digits))
+	}
+	switch config.TOTP.Algorithm {
+	case "SHA1", "SHA256", "SHA512":
+	default:
+		errs = append(errs, fmt.Errorf("TOTP_ALGORITHM must be SHA1, SHA256 or SHA512, got %q", config.TOTP.Algorithm))
+	}
+
+	// MFA secrets are always stored encrypted, without a key TOTP enrolment cannot work
+	if len(config.SecretEncryption.Keys) == 0 {
+		errs = append(errs, errors.New("SECRET_ENCRYPTION_KEYS must list at least one key"))
+	} else if _, ok := config.SecretEncryption.Keys[config.SecretEncryption.ActiveKeyID]; !ok {
+		errs = append(errs, fmt.Errorf("SECRET_ENCRYPTION_ACTIVE_KEY %q is not listed in SECRET_ENCRYPTION_KEYS", config.SecretEncryption.ActiveKeyID))
+	}
+	for id, encoded := range config.SecretEncryption.Keys {
+		if key, err := base64.StdEncoding.DecodeString(encoded); err != nil || len(key) != 32 {
+			errs = append(errs, fmt.Errorf("secret encryption key %q must be 32 bytes encoded as base64", id))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// Settings without a default, every test starts from them
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("SECRET_ENCRYPTION_KEYS", "k1:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	t.Setenv("SECRET_ENCRYPTION_ACTIVE_KEY", "")
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"zero parallelism", map[string]string{"PASSWORD_ARGON2_PARALLELISM": "0"}, "PASSWORD_ARGON2_PARALLELISM"},
+		{"zero iterations", map[string]string{"PASSWORD_ARGON2_ITERATIONS": "0"}, "PASSWORD_ARGON2_ITERATIONS"},
+		{"parallelism overflows uint8", map[string]string{"PASSWORD_ARGON2_PARALLELISM": "300"}, "PASSWORD_ARGON2_PARALLELISM"},
+		{"not a number", map[string]string{"PASSWORD_ARGON2_MEMORY": "64MB"}, "PASSWORD_ARGON2_MEMORY"},
+		{"negative memory", map[string]string{"PASSWORD_ARGON2_MEMORY": "-1"}, "PASSWORD_ARGON2_MEMORY"},
+		{"memory too small", map[string]string{"PASSWORD_ARGON2_MEMORY": "8", "PASSWORD_ARGON2_PARALLELISM": "4"}, "PASSWORD_ARGON2_MEMORY"},
+		{"bcrypt cost too high", map[string]string{"PASSWORD_BCRYPT_COST": "32"}, "PASSWORD_BCRYPT_COST"},
+		{"unknown algorithm", map[string]string{"PASSWORD_HASH_ALGORITHM": "md5"}, "PASSWORD_HASH_ALGORITHM"},
+		{"bcrypt with long passwords", map[string]string{"PASSWORD_HASH_ALGORITHM": "bcrypt"}, "PASSWORD_MAX_LENGTH"},
+		{"bcrypt within 72 bytes", map[string]string{"PASSWORD_HASH_ALGORITHM": "bcrypt", "PASSWORD_MAX_LENGTH": "72"}, ""},
+		{"min above max", map[string]string{"PASSWORD_MIN_LENGTH": "20", "PASSWORD_MAX_LENGTH": "10"}, "PASSWORD_MIN_LENGTH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			err := LoadEnvConfig().Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSecretEncryption(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	tests := []struct {
+		name    string
+		keys    string
+		active  string
+		wantErr string
+	}{
+		{"one key", "k1:" + key, "", ""},
+		{"rotation", "k1:" + key + ",k2:" + key, "k2", ""},
+		{"missing", "", "", "SECRET_ENCRYPTION_KEYS"},
+		{"unknown active key", "k1:" + key, "k2", "SECRET_ENCRYPTION_ACTIVE_KEY"},
+		{"short key", "k1:" + base64.StdEncoding.EncodeToString(make([]byte, 16)), "", "\"k1\""},
+		{"not base64", "k1:%%%", "", "\"k1\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SECRET_ENCRYPTION_KEYS", tt.keys)
+			t.Setenv("SECRET_ENCRYPTION_ACTIVE_KEY", tt.active)
+			err := LoadEnvConfig().Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSMSProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		mode     string
+		wantErr  bool
+	}{
+		{"rabbitmq in production", "rabbitmq", "production", false},
+		{"log in staging", "log", "staging", false},
+		{"default in production", "", "production", false},
+		{"typo in production", "rabbit", "production", true},
+		{"typo in staging", "twilio", "staging", true},
+		{"typo in development", "rabbit", "development", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("SMS_PROVIDER", tt.provider)
+			t.Setenv("DEPLOY_ENV", tt.mode)
+			err := LoadEnvConfig().Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "SMS_PROVIDER") {
+				t.Fatalf("Validate() = %v, want an error about SMS_PROVIDER", err)
+			}
+		})
+	}
+}
+
+func TestValidateLoginProtection(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"custom values", map[string]string{"LOGIN_FAILURE_WINDOW": "600", "LOGIN_LOCK_THRESHOLD": "5"}, ""},
+		{"zero window", map[string]string{"LOGIN_FAILURE_WINDOW": "0"}, "LOGIN_FAILURE_WINDOW"},
+		{"negative delay threshold", map[string]string{"LOGIN_DELAY_THRESHOLD": "-1"}, "LOGIN_DELAY_THRESHOLD"},
+		{"max delay with a unit", map[string]string{"LOGIN_MAX_DELAY": "60s"}, "LOGIN_MAX_DELAY"},
+		{"zero lock threshold", map[string]string{"LOGIN_LOCK_THRESHOLD": "0"}, "LOGIN_LOCK_THRESHOLD"},
+		{"lock duration not a number", map[string]string{"LOGIN_LOCK_DURATION": "half an hour"}, "LOGIN_LOCK_DURATION"},
+		{"zero ip threshold", map[string]string{"LOGIN_IP_DELAY_THRESHOLD": "0"}, "LOGIN_IP_DELAY_THRESHOLD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			err := LoadEnvConfig().Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSessionTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"custom values", map[string]string{"SESSION_REMEMBER_TTL": "604800", "SESSION_DEFAULT_TTL": "3600"}, ""},
+		{"zero remember ttl", map[string]string{"SESSION_REMEMBER_TTL": "0"}, "SESSION_REMEMBER_TTL"},
+		{"negative default ttl", map[string]string{"SESSION_DEFAULT_TTL": "-60"}, "SESSION_DEFAULT_TTL"},
+		{"default ttl with a unit", map[string]string{"SESSION_DEFAULT_TTL": "24h"}, "SESSION_DEFAULT_TTL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			err := LoadEnvConfig().Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"eight digits", map[string]string{"TOTP_DIGITS": "8", "TOTP_ALGORITHM": "sha256"}, ""},
+		{"seven digits", map[string]string{"TOTP_DIGITS": "7"}, "TOTP_DIGITS"},
+		{"zero digits", map[string]string{"TOTP_DIGITS": "0"}, "TOTP_DIGITS"},
+		{"digits not a number", map[string]string{"TOTP_DIGITS": "six"}, "TOTP_DIGITS"},
+		{"unknown algorithm", map[string]string{"TOTP_ALGORITHM": "MD5"}, "TOTP_ALGORITHM"},
+		{"two steps of skew", map[string]string{"TOTP_SKEW": "2"}, ""},
+		{"zero skew", map[string]string{"TOTP_SKEW": "0"}, "TOTP_SKEW"},
+		{"negative skew", map[string]string{"TOTP_SKEW": "-1"}, "TOTP_SKEW"},
+		{"skew not a number", map[string]string{"TOTP_SKEW": "one"}, "TOTP_SKEW"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			err := LoadEnvConfig().Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
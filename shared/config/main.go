@@ -1,11 +1,16 @@
 package config
 
+import "log"
+
 type Config struct {
 	EnvConfig *EnvConfig `json:"env_config"`
 }
 
 func NewConfig() *Config {
 	EnvConfig := LoadEnvConfig()
+	if err := EnvConfig.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	return &Config{
 		EnvConfig: EnvConfig,
 	}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdminAuditLog is kept without foreign keys so that entries outlive the accounts they mention
+type AdminAuditLog struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	AdminID      uuid.UUID  `gorm:"type:uuid;index" json:"admin_id,omitempty"`
+	TargetUserID *uuid.UUID `gorm:"type:uuid;index" json:"target_user_id,omitempty"`
+	Action       string     `gorm:"size:50" json:"action,omitempty"`    // "user.update" | "user.mfa_reset" | "role.grant" | ...
+	Details      string     `gorm:"type:text" json:"details,omitempty"` // JSON
+	IPAddress    string     `gorm:"size:64" json:"ip_address,omitempty"`
+	CreatedAt    time.Time  `gorm:"index" json:"created_at,omitempty"`
+}
+
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
+}
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LoginEvent struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	UserID        *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"` // nil khi không xác định được tài khoản
+	Identifier    string     `gorm:"size:255" json:"identifier,omitempty"`     // "email:..." | "username:..." | "phone:..."
+	Method        string     `gorm:"size:30" json:"method,omitempty"`          // "password" | "google" | "totp" | "passkey" | ...
+	Success       bool       `gorm:"default:false" json:"success"`
+	FailureReason string     `gorm:"size:50" json:"failure_reason,omitempty"` // "invalid_credentials" | "invalid_code" | ...
+	IPAddress     string     `gorm:"size:64" json:"ip_address,omitempty"`
+	Network       string     `gorm:"size:64;index" json:"network,omitempty"` // /24 (IPv4) hoặc /64 (IPv6) của IPAddress
+	UserAgent     string     `gorm:"size:512" json:"user_agent,omitempty"`
+	DeviceID      string     `gorm:"size:255" json:"device_id,omitempty"` // header X-Device-ID
+	CreatedAt     time.Time  `gorm:"index" json:"created_at,omitempty"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (LoginEvent) TableName() string {
+	return "login_events"
+}
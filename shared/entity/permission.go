@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Permission struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	Name        string    `gorm:"size:100;unique" json:"name,omitempty"` // "<resource>:<action>", vd. "users:read"
+	Description string    `gorm:"size:255" json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	Name        string    `gorm:"size:50;unique" json:"name,omitempty"` // "member" | "admin" | ...
+	Description string    `gorm:"size:255" json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+
+	Permissions []Permission `gorm:"many2many:role_permissions;joinForeignKey:RoleID;joinReferences:PermissionID" json:"permissions,omitempty"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
@@ -20,6 +20,19 @@ type User struct {
 	GithubURL   *string    `gorm:"unique" json:"github_url,omitempty"`
 	AvatarURL   *string    `json:"avatar_url,omitempty"`
 
+	Status          string     `gorm:"size:20;default:active;index" json:"status,omitempty"` // xem user_status.go
+	StatusReason    *string    `gorm:"size:500" json:"status_reason,omitempty"`
+	SuspendedUntil  *time.Time `json:"suspended_until,omitempty"` // nil khi tạm khoá không thời hạn
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at,omitempty"` // xoá vĩnh viễn sau thời điểm này, nil khi không có yêu cầu xoá
+	PurgedAt            *time.Time `json:"purged_at,omitempty"`                          // thời điểm dữ liệu đã bị xoá
+
+	// Việc còn lại sau khi xoá dữ liệu, được thử lại cho đến khi thành công
+	PurgeAvatarURL           *string    `json:"-"` // ảnh đại diện chưa xoá được trên upload service
+	DeletionEventPublishedAt *time.Time `json:"-"` // nil khi user.deleted chưa được gửi
+
 	Verifications []UserVerification `gorm:"foreignKey:UserID"`
 	MFAs          []UserMFA          `gorm:"foreignKey:UserID"`
 }
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DataExportPending    = "pending"
+	DataExportProcessing = "processing"
+	DataExportReady      = "ready"
+	DataExportFailed     = "failed"
+)
+
+type UserDataExport struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	UserID       uuid.UUID  `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Status       string     `gorm:"size:20" json:"status,omitempty"` // "pending" | "processing" | "ready" | "failed"
+	TokenHash    *string    `gorm:"size:64;uniqueIndex" json:"-"`    // sha256 của token trong link tải, chỉ có khi "ready"
+	Archive      []byte     `gorm:"type:bytea" json:"-"`             // file zip, bị xoá sau khi tải hoặc hết hạn
+	Size         int64      `json:"size,omitempty"`                  // kích thước file zip (byte)
+	Error        *string    `gorm:"size:500" json:"error,omitempty"` // lý do thất bại
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`            // link tải hết hạn
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty"` // link chỉ dùng được một lần
+	CreatedAt    time.Time  `json:"created_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserDataExport) TableName() string {
+	return "user_data_exports"
+}
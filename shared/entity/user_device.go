@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserDevice struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	UserID      uuid.UUID  `gorm:"type:uuid;uniqueIndex:idx_user_devices_user_device" json:"user_id,omitempty"`
+	DeviceID    string     `gorm:"size:255;uniqueIndex:idx_user_devices_user_device" json:"device_id,omitempty"` // header X-Device-ID
+	UserAgent   string     `gorm:"size:512" json:"user_agent,omitempty"`
+	IPAddress   string     `gorm:"size:64" json:"ip_address,omitempty"`
+	LoginMethod string     `gorm:"size:30" json:"login_method,omitempty"` // "password" | "google" | "passkey" | "magic_link" | ...
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"` // đăng xuất / thu hồi phiên
+	CreatedAt   time.Time  `json:"created_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at,omitempty"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserDevice) TableName() string {
+	return "user_devices"
+}
@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserMFARecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	CodeHash  string     `gorm:"size:64" json:"-"` // SHA-256 của mã khôi phục, không lưu mã gốc
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserRole struct {
+	UserID    uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id,omitempty"`
+	RoleID    uuid.UUID  `gorm:"type:uuid;primaryKey;index" json:"role_id,omitempty"`
+	GrantedBy *uuid.UUID `gorm:"type:uuid" json:"granted_by,omitempty"` // nil khi được gán tự động
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Role Role `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserRole) TableName() string {
+	return "user_roles"
+}
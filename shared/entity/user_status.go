@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+// Account statuses. Only active accounts can sign in or use their tokens.
+const (
+	UserStatusPending   = "pending"   // chờ kích hoạt
+	UserStatusActive    = "active"    // hoạt động bình thường
+	UserStatusSuspended = "suspended" // tạm khoá, có thể có thời hạn
+	UserStatusBanned    = "banned"    // cấm vĩnh viễn cho đến khi admin mở lại
+	UserStatusDeleted   = "deleted"   // đã xoá, không thể khôi phục
+)
+
+// Allowed status changes. Re-suspending a suspended account updates the reason and end of the suspension.
+var userStatusTransitions = map[string][]string{
+	UserStatusPending:   {UserStatusActive, UserStatusBanned, UserStatusDeleted},
+	UserStatusActive:    {UserStatusSuspended, UserStatusBanned, UserStatusDeleted},
+	UserStatusSuspended: {UserStatusActive, UserStatusSuspended, UserStatusBanned, UserStatusDeleted},
+	UserStatusBanned:    {UserStatusActive, UserStatusDeleted},
+	UserStatusDeleted:   {},
+}
+
+// IsValidUserStatus reports whether status is one of the known account statuses
+func IsValidUserStatus(status string) bool {
+	_, ok := userStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionUserStatus reports whether an account may move from one status to another
+func CanTransitionUserStatus(from, to string) bool {
+	for _, allowed := range userStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveStatus is the status at now: a suspension whose end has passed counts as active.
+// An empty status, as on a User that was not loaded from the database, counts as active too.
+func (u *User) EffectiveStatus(now time.Time) string {
+	switch {
+	case u.Status == "":
+		return UserStatusActive
+	case u.Status == UserStatusSuspended && u.SuspendedUntil != nil && !now.Before(*u.SuspendedUntil):
+		return UserStatusActive
+	}
+	return u.Status
+}
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserWebAuthnCredential struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id,omitempty"`
+	UserID       uuid.UUID  `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	CredentialID string     `gorm:"size:1024;uniqueIndex" json:"credential_id,omitempty"` // base64url credential ID
+	PublicKey    []byte     `json:"-"`                                                    // COSE_Key của authenticator
+	Algorithm    int64      `json:"algorithm,omitempty"`                                  // -7 ES256 | -8 EdDSA | -257 RS256
+	SignCount    int64      `json:"sign_count"`
+	AAGUID       string     `gorm:"size:36" json:"aaguid,omitempty"`
+	Transports   string     `gorm:"size:255" json:"transports,omitempty"` // "internal,hybrid,usb,..."
+	Name         string     `gorm:"size:100" json:"name,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (UserWebAuthnCredential) TableName() string {
+	return "user_webauthn_credentials"
+}
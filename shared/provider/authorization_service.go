@@ -3,6 +3,7 @@ package provider
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,16 @@ import (
 	"github.com/tnqbao/gau-account-service/shared/provider/dto"
 )
 
+// Reasons a refresh token is refused by the authorization service, wrapped by RenewAccessToken
+var (
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+	ErrTokenDeviceMismatch = errors.New("refresh token was issued to another device")
+)
+
+// ErrAuthorizationServiceUnavailable is wrapped when the authorization service cannot give an answer
+var ErrAuthorizationServiceUnavailable = errors.New("authorization service unavailable")
+
 type AuthorizationServiceProvider struct {
 	AuthorizationServiceURL string `json:"authorization_service_url"`
 	PrivateKey              string `json:"private_key,omitempty"`
@@ -35,7 +46,8 @@ func NewAuthorizationServiceProvider(config *config.EnvConfig) *AuthorizationSer
 	}
 }
 
-func (p *AuthorizationServiceProvider) CreateNewToken(userID uuid.UUID, permission string, deviceID string) (string, string, time.Time, error) {
+// CreateNewToken issues an access/refresh token pair for the device. refreshTTL sets the refresh token lifetime.
+func (p *AuthorizationServiceProvider) CreateNewToken(userID uuid.UUID, access dto.TokenAccess, deviceID string, refreshTTL time.Duration) (string, string, time.Time, error) {
 	if deviceID == "" {
 		return "", "", time.Time{}, fmt.Errorf("device ID is required")
 	}
@@ -43,8 +55,9 @@ func (p *AuthorizationServiceProvider) CreateNewToken(userID uuid.UUID, permissi
 	url := fmt.Sprintf("%s/api/v2/authorization/token", p.AuthorizationServiceURL)
 
 	body, err := json.Marshal(dto.CreateTokenRequest{
-		UserID:     userID,
-		Permission: permission,
+		UserID:          userID,
+		TokenAccess:     access,
+		RefreshTokenTTL: int(refreshTTL.Seconds()),
 	})
 
 	if err != nil {
@@ -103,7 +116,7 @@ func (p *AuthorizationServiceProvider) RenewAccessToken(refreshToken, deviceID,
 
 	if resp.StatusCode != http.StatusOK {
 		raw, _ := io.ReadAll(resp.Body)
-		return "", time.Time{}, fmt.Errorf("authorization service returned %d: %s", resp.StatusCode, string(raw))
+		return "", time.Time{}, renewTokenError(resp.StatusCode, raw)
 	}
 
 	var response dto.RenewTokenResponse
@@ -115,6 +128,34 @@ func (p *AuthorizationServiceProvider) RenewAccessToken(refreshToken, deviceID,
 	return response.AccessToken, expiry, nil
 }
 
+// renewTokenErrors maps the error_code of a rejected renewal to the refresh token errors
+var renewTokenErrors = map[string]error{
+	"refresh_token_expired": ErrRefreshTokenExpired,
+	"refresh_token_revoked": ErrRefreshTokenRevoked,
+	"refresh_token_invalid": ErrRefreshTokenRevoked,
+	"device_mismatch":       ErrTokenDeviceMismatch,
+}
+
+// renewTokenError maps a rejected renewal to one of the refresh token errors using the error_code
+// of the response. Other rejections count as revoked, server errors are returned as is.
+func renewTokenError(status int, raw []byte) error {
+	err := fmt.Errorf("authorization service returned %d: %s", status, string(raw))
+	if status >= http.StatusInternalServerError {
+		return err
+	}
+
+	var body dto.ErrorResponse
+	if json.Unmarshal(raw, &body) == nil {
+		if known, ok := renewTokenErrors[body.ErrorCode]; ok {
+			return fmt.Errorf("%w: %v", known, err)
+		}
+	}
+	if status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound {
+		return fmt.Errorf("%w: %v", ErrRefreshTokenRevoked, err)
+	}
+	return err
+}
+
 func (p *AuthorizationServiceProvider) CheckAccessToken(token string) error {
 	url := fmt.Sprintf("%s/api/v2/authorization/token/validate?token=%s", p.AuthorizationServiceURL, token)
 
@@ -128,10 +169,14 @@ func (p *AuthorizationServiceProvider) CheckAccessToken(token string) error {
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return fmt.Errorf("%w: request failed: %v", ErrAuthorizationServiceUnavailable, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: authorization service returned %d: %s", ErrAuthorizationServiceUnavailable, resp.StatusCode, string(raw))
+	}
 	if resp.StatusCode != http.StatusOK {
 		raw, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("invalid token: %s", string(raw))
@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRenewTokenError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"expired", http.StatusUnauthorized, `{"error_code":"refresh_token_expired","message":"Token expired"}`, ErrRefreshTokenExpired},
+		{"device mismatch", http.StatusForbidden, `{"error_code":"device_mismatch"}`, ErrTokenDeviceMismatch},
+		{"revoked", http.StatusUnauthorized, `{"error_code":"refresh_token_revoked"}`, ErrRefreshTokenRevoked},
+		// The message is free text and must not decide the outcome
+		{"message mentions expiry", http.StatusUnauthorized, `{"error_code":"refresh_token_revoked","message":"device session expired"}`, ErrRefreshTokenRevoked},
+		{"no error code", http.StatusUnauthorized, `invalid token`, ErrRefreshTokenRevoked},
+		{"unknown code on bad request", http.StatusBadRequest, `{"error_code":"bad_request"}`, nil},
+		{"server error", http.StatusBadGateway, `{"error_code":"refresh_token_expired"}`, nil},
+	}
+	known := []error{ErrRefreshTokenExpired, ErrRefreshTokenRevoked, ErrTokenDeviceMismatch}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := renewTokenError(tt.status, []byte(tt.body))
+			if err == nil {
+				t.Fatal("renewTokenError returned nil")
+			}
+			for _, candidate := range known {
+				if errors.Is(err, candidate) != (candidate == tt.want) {
+					t.Fatalf("renewTokenError = %v, want %v", err, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -2,9 +2,17 @@ package dto
 
 import "github.com/google/uuid"
 
+// TokenAccess is what the access token grants, copied into its claims by the authorization service
+type TokenAccess struct {
+	Permission string   `json:"permission"`       // legacy single role name
+	Roles      []string `json:"roles,omitempty"`  // role names
+	Scopes     []string `json:"scopes,omitempty"` // permission names resolved from the roles
+}
+
 type CreateTokenRequest struct {
-	UserID     uuid.UUID `json:"user_id"`
-	Permission string    `json:"permission"`
+	UserID uuid.UUID `json:"user_id"`
+	TokenAccess
+	RefreshTokenTTL int `json:"refresh_token_ttl,omitempty"` // seconds
 }
 
 type CreateTokenResponse struct {
@@ -12,6 +20,14 @@ type CreateTokenResponse struct {
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int    `json:"expires_in"`
 }
+
+// ErrorResponse is the error body returned by the authorization service
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	ErrorCode string `json:"error_code"`
+	Message   string `json:"message"`
+}
+
 type RenewTokenResponse struct {
 	AccessToken string `json:"access_token"`
 	ExpiresIn   int    `json:"expires_in"`
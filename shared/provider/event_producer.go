@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-account-service/shared/infra"
+)
+
+// Account lifecycle events are published to a topic exchange so other services can react to them
+const (
+	AccountEventExchange          = "account_exchange"
+	DataExportRequestedRoutingKey = "user.data_export_requested"
+)
+
+type UserStatusChangedEvent struct {
+	Type           string     `json:"type"`
+	UserID         string     `json:"userId"`
+	PreviousStatus string     `json:"previousStatus"`
+	Status         string     `json:"status"`
+	Reason         string     `json:"reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+	ChangedBy      string     `json:"changedBy,omitempty"`
+	ChangedAt      time.Time  `json:"changedAt"`
+}
+
+type UserDeletedEvent struct {
+	Type        string     `json:"type"`
+	UserID      string     `json:"userId"`
+	RequestedAt *time.Time `json:"requestedAt,omitempty"`
+	DeletedAt   time.Time  `json:"deletedAt"`
+}
+
+type DataExportRequestedEvent struct {
+	Type        string    `json:"type"`
+	ExportID    string    `json:"exportId"`
+	UserID      string    `json:"userId"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+type EventProducer struct {
+	rabbitmq *infra.RabbitMQClient
+}
+
+func NewEventProducer(rabbitmq *infra.RabbitMQClient) *EventProducer {
+	// Publishing to a missing exchange closes the channel, which is shared with the email producer
+	if err := rabbitmq.DeclareExchange(AccountEventExchange, "topic", true); err != nil {
+		log.Printf("Failed to declare %s: %v", AccountEventExchange, err)
+	}
+	return &EventProducer{
+		rabbitmq: rabbitmq,
+	}
+}
+
+func (p *EventProducer) PublishUserStatusChanged(ctx context.Context, event UserStatusChangedEvent) error {
+	event.Type = "user.status_changed"
+	return p.publishEvent(ctx, event.Type, event)
+}
+
+func (p *EventProducer) PublishUserDeleted(ctx context.Context, event UserDeletedEvent) error {
+	event.Type = "user.deleted"
+	return p.publishEvent(ctx, event.Type, event)
+}
+
+// PublishDataExportRequested hands an export over to the consumer, which builds the archive
+func (p *EventProducer) PublishDataExportRequested(ctx context.Context, event DataExportRequestedEvent) error {
+	event.Type = DataExportRequestedRoutingKey
+	return p.publishEvent(ctx, event.Type, event)
+}
+
+func (p *EventProducer) publishEvent(ctx context.Context, routingKey string, event any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", routingKey, err)
+	}
+
+	err = p.rabbitmq.Channel.PublishWithContext(
+		ctx,
+		AccountEventExchange, // exchange
+		routingKey,           // routing key
+		false,                // mandatory
+		false,                // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    time.Now(),
+			Body:         body,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", routingKey, err)
+	}
+
+	return nil
+}
@@ -10,6 +10,8 @@ type Provider struct {
 	UploadServiceProvider        *UploadServiceProvider
 	LoggerProvider               *LoggerProvider
 	EmailProducer                *EmailProducer
+	EventProducer                *EventProducer
+	SMSProvider                  SMSProvider
 }
 
 var provider *Provider
@@ -19,11 +21,15 @@ func InitProvider(cfg *config.EnvConfig, inf *infra.Infra) *Provider {
 	uploadServiceProvider := NewUploadServiceProvider(cfg)
 	loggerProvider := NewLoggerProvider()
 	emailProducer := NewEmailProducer(inf.RabbitMQ)
+	eventProducer := NewEventProducer(inf.RabbitMQ)
+	smsProvider := NewSMSProvider(cfg, inf, loggerProvider)
 	provider = &Provider{
 		AuthorizationServiceProvider: authorizationServiceProvider,
 		UploadServiceProvider:        uploadServiceProvider,
 		LoggerProvider:               loggerProvider,
 		EmailProducer:                emailProducer,
+		EventProducer:                eventProducer,
+		SMSProvider:                  smsProvider,
 	}
 
 	return provider
@@ -63,6 +63,41 @@ func (p *EmailProducer) SendEmailWarning(ctx context.Context, email, recipientNa
 	return p.publishEmail(ctx, "email.warning", message)
 }
 
+func (p *EmailProducer) SendEmailOTP(ctx context.Context, email, recipientName, content string) error {
+	message := EmailMessage{
+		Type:          "otp",
+		Recipient:     email,
+		RecipientName: recipientName,
+		Content:       content,
+	}
+
+	return p.publishEmail(ctx, "email.otp", message)
+}
+
+func (p *EmailProducer) SendPasswordReset(ctx context.Context, email, recipientName, content, actionUrl string) error {
+	message := EmailMessage{
+		Type:          "password_reset",
+		Recipient:     email,
+		RecipientName: recipientName,
+		Content:       content,
+		ActionUrl:     actionUrl,
+	}
+
+	return p.publishEmail(ctx, "email.password_reset", message)
+}
+
+func (p *EmailProducer) SendMagicLink(ctx context.Context, email, recipientName, content, actionUrl string) error {
+	message := EmailMessage{
+		Type:          "magic_link",
+		Recipient:     email,
+		RecipientName: recipientName,
+		Content:       content,
+		ActionUrl:     actionUrl,
+	}
+
+	return p.publishEmail(ctx, "email.magic_link", message)
+}
+
 func (p *EmailProducer) publishEmail(ctx context.Context, routingKey string, message EmailMessage) error {
 	body, err := json.Marshal(message)
 	if err != nil {
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-account-service/shared/config"
+	"github.com/tnqbao/gau-account-service/shared/infra"
+)
+
+// SMSExchange receives the messages the SMS gateway service delivers
+const SMSExchange = "sms_exchange"
+
+// SMSProvider delivers text messages to phone numbers
+type SMSProvider interface {
+	SendSMS(ctx context.Context, phone, content string) error
+}
+
+type SMSMessage struct {
+	Recipient string `json:"recipient"`
+	Content   string `json:"content"`
+}
+
+func NewSMSProvider(cfg *config.EnvConfig, inf *infra.Infra, logger *LoggerProvider) SMSProvider {
+	switch cfg.SMS.Provider {
+	case "rabbitmq":
+		return NewRabbitMQSMSProvider(inf.RabbitMQ)
+	case "log":
+		return NewLogSMSProvider(logger)
+	default:
+		// Only reachable in development, config validation rejects unknown providers elsewhere
+		log.Printf("Unknown SMS_PROVIDER %q, messages will only be logged", cfg.SMS.Provider)
+		return NewLogSMSProvider(logger)
+	}
+}
+
+// RabbitMQSMSProvider publishes messages for the SMS gateway service to deliver
+type RabbitMQSMSProvider struct {
+	rabbitmq *infra.RabbitMQClient
+}
+
+func NewRabbitMQSMSProvider(rabbitmq *infra.RabbitMQClient) *RabbitMQSMSProvider {
+	// Publishing to a missing exchange closes the channel, which is shared with the other producers
+	if err := rabbitmq.DeclareExchange(SMSExchange, "topic", true); err != nil {
+		log.Printf("Failed to declare %s: %v", SMSExchange, err)
+	}
+	return &RabbitMQSMSProvider{
+		rabbitmq: rabbitmq,
+	}
+}
+
+func (p *RabbitMQSMSProvider) SendSMS(ctx context.Context, phone, content string) error {
+	body, err := json.Marshal(SMSMessage{
+		Recipient: phone,
+		Content:   content,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal sms message: %w", err)
+	}
+
+	err = p.rabbitmq.Channel.PublishWithContext(
+		ctx,
+		SMSExchange, // exchange
+		"sms.send",  // routing key
+		false,       // mandatory
+		false,       // immediate
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish sms message: %w", err)
+	}
+
+	return nil
+}
+
+// LogSMSProvider writes messages to the log instead of sending them, for local development
+type LogSMSProvider struct {
+	logger *LoggerProvider
+}
+
+func NewLogSMSProvider(logger *LoggerProvider) *LogSMSProvider {
+	return &LogSMSProvider{
+		logger: logger,
+	}
+}
+
+func (p *LogSMSProvider) SendSMS(ctx context.Context, phone, content string) error {
+	p.logger.InfoWithContextf(ctx, "[SMS] (not sent) to %s: %s", phone, content)
+	return nil
+}
@@ -7,6 +7,9 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/tnqbao/gau-account-service/shared/config"
 )
@@ -109,3 +112,42 @@ func (p *UploadServiceProvider) UploadAvatarImage(imageData []byte, filename str
 
 	return cdnURL, nil
 }
+
+// DeleteFile removes a file previously returned by UploadAvatarImage. URLs outside the CDN are ignored.
+func (p *UploadServiceProvider) DeleteFile(fileURL string) error {
+	prefix := p.CDNServiceURL + "/"
+	if !strings.HasPrefix(fileURL, prefix) {
+		return nil
+	}
+	bucket, filePath, found := strings.Cut(strings.TrimPrefix(fileURL, prefix), "/")
+	if !found || filePath == "" {
+		return fmt.Errorf("cannot find bucket and path in %s", fileURL)
+	}
+
+	query := url.Values{}
+	query.Set("bucket", bucket)
+	query.Set("path", filePath)
+	endpoint := fmt.Sprintf("%s/api/v2/upload/file?%s", p.UploadServiceURL, query.Encode())
+
+	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Private-Key", p.PrivateKey)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// Already gone counts as deleted
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("upload service returned %d: %s", resp.StatusCode, string(raw))
+	}
+	return nil
+}
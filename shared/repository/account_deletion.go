@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm"
+)
+
+// Links that cancel a scheduled account deletion, valid until the deletion is carried out.
+// Layout: account_deletion_cancel:<token> -> user_id
+
+func accountDeletionCancelKey(token string) string {
+	return fmt.Sprintf("account_deletion_cancel:%s", token)
+}
+
+// ScheduleUserDeletion records a deletion request to be carried out at scheduledAt.
+// It returns false when a deletion is already scheduled.
+func (r *Repository) ScheduleUserDeletion(userID uuid.UUID, scheduledAt time.Time) (bool, error) {
+	result := r.Db.Model(&entity2.User{}).
+		Where("user_id = ? AND deletion_scheduled_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"deletion_requested_at": time.Now(),
+			"deletion_scheduled_at": scheduledAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("error scheduling user deletion: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CancelUserDeletion drops a scheduled deletion that has not been carried out yet.
+// It returns false when there was nothing to cancel.
+func (r *Repository) CancelUserDeletion(userID uuid.UUID) (bool, error) {
+	result := r.Db.Model(&entity2.User{}).
+		Where("user_id = ? AND deletion_scheduled_at IS NOT NULL AND status <> ?", userID, entity2.UserStatusDeleted).
+		Updates(map[string]interface{}{
+			"deletion_requested_at": nil,
+			"deletion_scheduled_at": nil,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("error cancelling user deletion: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateDeletionCancelToken returns a token that cancels the deletion of a user until ttl has passed
+func (r *Repository) CreateDeletionCancelToken(ctx context.Context, userID uuid.UUID, ttl time.Duration) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	if err := r.cacheDb.Set(ctx, accountDeletionCancelKey(token), userID.String(), ttl).Err(); err != nil {
+		return "", fmt.Errorf("failed to store deletion cancel token: %w", err)
+	}
+	return token, nil
+}
+
+// ConsumeDeletionCancelToken validates a cancel token, deletes it and returns the user it belongs to
+func (r *Repository) ConsumeDeletionCancelToken(ctx context.Context, token string) (uuid.UUID, error) {
+	userID, err := r.cacheDb.GetDel(ctx, accountDeletionCancelKey(token)).Result()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid or expired token: %w", err)
+	}
+	return uuid.Parse(userID)
+}
+
+// GetUsersDueForPurge returns up to limit users whose grace period ended before now
+func (r *Repository) GetUsersDueForPurge(now time.Time, limit int) ([]entity2.User, error) {
+	var users []entity2.User
+	if err := r.Db.Where("deletion_scheduled_at <= ? AND purged_at IS NULL", now).
+		Order("deletion_scheduled_at").Limit(limit).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("error getting users due for purge: %v", err)
+	}
+	return users, nil
+}
+
+// PurgeUser anonymises a user whose deletion is due and removes every record tied to the account.
+// The row itself is kept with status deleted so IDs in audit logs and other services stay resolvable;
+// the reason of an admin deletion is kept. It returns false when the deletion was cancelled or already
+// carried out in the meantime.
+func (r *Repository) PurgeUser(userID uuid.UUID) (bool, error) {
+	purged := false
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&entity2.User{}).
+			Where("user_id = ? AND deletion_scheduled_at <= ? AND purged_at IS NULL", userID, now).
+			Updates(map[string]interface{}{
+				"username":          nil,
+				"password":          nil,
+				"email":             nil,
+				"phone":             nil,
+				"full_name":         nil,
+				"gender":            nil,
+				"date_of_birth":     nil,
+				"facebook_url":      nil,
+				"github_url":        nil,
+				"avatar_url":        nil,
+				"status":            entity2.UserStatusDeleted,
+				"status_reason":     gorm.Expr("CASE WHEN status = ? THEN status_reason ELSE ? END", entity2.UserStatusDeleted, "deleted at the user's request"),
+				"suspended_until":   nil,
+				"status_changed_at": gorm.Expr("CASE WHEN status = ? THEN status_changed_at ELSE ? END", entity2.UserStatusDeleted, now),
+				"purged_at":         now,
+				// Side effects outside the database, carried out by CompletePurge and retried until done
+				"purge_avatar_url":            gorm.Expr("CASE WHEN avatar_url IS NOT NULL AND avatar_url <> '' AND avatar_url <> ? THEN avatar_url END", DefaultAvatarURL),
+				"deletion_event_published_at": nil,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("error anonymising user: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		for _, model := range []interface{}{
+			&entity2.UserVerification{},
+			&entity2.UserMFA{},
+			&entity2.UserMFARecoveryCode{},
+			&entity2.UserWebAuthnCredential{},
+			&entity2.UserDevice{},
+			&entity2.UserRole{},
+			&entity2.LoginEvent{},
+			&entity2.UserDataExport{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return fmt.Errorf("error purging %T: %v", model, err)
+			}
+		}
+		purged = true
+		return nil
+	})
+	return purged, err
+}
+
+// GetUsersWithPendingPurgeEffects returns up to limit purged users whose avatar is not deleted yet or
+// whose user.deleted event has not been published
+func (r *Repository) GetUsersWithPendingPurgeEffects(limit int) ([]entity2.User, error) {
+	var users []entity2.User
+	if err := r.Db.Where("purged_at IS NOT NULL AND (deletion_event_published_at IS NULL OR purge_avatar_url IS NOT NULL)").
+		Order("purged_at").Limit(limit).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("error getting users with pending purge effects: %v", err)
+	}
+	return users, nil
+}
+
+// MarkPurgeAvatarDeleted records that the avatar of a purged user was removed from the upload service
+func (r *Repository) MarkPurgeAvatarDeleted(userID uuid.UUID) error {
+	if err := r.Db.Model(&entity2.User{}).Where("user_id = ?", userID).Update("purge_avatar_url", nil).Error; err != nil {
+		return fmt.Errorf("error marking avatar deleted for user %s: %v", userID, err)
+	}
+	return nil
+}
+
+// MarkDeletionEventPublished records that user.deleted was published for a purged user
+func (r *Repository) MarkDeletionEventPublished(userID uuid.UUID, publishedAt time.Time) error {
+	if err := r.Db.Model(&entity2.User{}).Where("user_id = ?", userID).Update("deletion_event_published_at", publishedAt).Error; err != nil {
+		return fmt.Errorf("error marking deletion event published for user %s: %v", userID, err)
+	}
+	return nil
+}
+
+// DeleteUserCache removes the session index and cached status of a purged user
+func (r *Repository) DeleteUserCache(ctx context.Context, userID uuid.UUID) error {
+	if err := r.cacheDb.Del(ctx, userSessionsKey(userID.String()), userStatusKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete user cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+)
+
+// CreateAdminAuditLog appends an entry to the admin audit log
+func (r *Repository) CreateAdminAuditLog(log *entity2.AdminAuditLog) error {
+	if log.ID == uuid.Nil {
+		log.ID = uuid.New()
+	}
+	if err := r.Db.Create(log).Error; err != nil {
+		return fmt.Errorf("error creating admin audit log: %v", err)
+	}
+	return nil
+}
+
+// GetAdminAuditLogs returns one page of the audit log, newest first, optionally for one target user
+func (r *Repository) GetAdminAuditLogs(targetUserID *uuid.UUID, offset, limit int) ([]entity2.AdminAuditLog, int64, error) {
+	query := r.Db.Model(&entity2.AdminAuditLog{})
+	if targetUserID != nil {
+		query = query.Where("target_user_id = ?", *targetUserID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting admin audit logs: %v", err)
+	}
+
+	var logs []entity2.AdminAuditLog
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("error getting admin audit logs: %v", err)
+	}
+	return logs, total, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mozillazg/go-unidecode"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserSearchFilter narrows the admin user listing. Query matches any of the other fields; every
+// match is a case-insensitive substring match and names also ignore Vietnamese diacritics.
+type UserSearchFilter struct {
+	Query      string
+	Email      string
+	Username   string
+	Phone      string
+	Name       string
+	Permission string
+	Status     string
+}
+
+// Vietnamese letters and their unidecode form, used with translate() so the database applies the
+// same normalisation as NormalizeSearchText
+var vietnameseLetters = func() string {
+	lower := "àáảãạăằắẳẵặâầấẩẫậèéẻẽẹêềếểễệìíỉĩịòóỏõọôồốổỗộơờớởỡợùúủũụưừứửữựỳýỷỹỵđ"
+	return lower + strings.ToUpper(lower)
+}()
+
+var vietnameseLettersASCII = func() string {
+	var b strings.Builder
+	for _, letter := range vietnameseLetters {
+		b.WriteString(unidecode.Unidecode(string(letter)))
+	}
+	return b.String()
+}()
+
+// NormalizeSearchText lowercases text and strips diacritics
+func NormalizeSearchText(text string) string {
+	return strings.ToLower(unidecode.Unidecode(strings.TrimSpace(text)))
+}
+
+func likePattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+	return "%" + escaped + "%"
+}
+
+// SearchUsers returns one page of users matching filter and the total number of matches
+func (r *Repository) SearchUsers(filter UserSearchFilter, offset, limit int) ([]entity2.User, int64, error) {
+	normalizedName := "lower(translate(full_name, ?, ?))"
+
+	query := r.Db.Model(&entity2.User{})
+	if filter.Query != "" {
+		pattern := likePattern(NormalizeSearchText(filter.Query))
+		query = query.Where("lower(email) LIKE ? OR lower(username) LIKE ? OR phone LIKE ? OR "+normalizedName+" LIKE ?",
+			pattern, pattern, likePattern(strings.TrimSpace(filter.Query)), vietnameseLetters, vietnameseLettersASCII, pattern)
+	}
+	if filter.Email != "" {
+		query = query.Where("lower(email) LIKE ?", likePattern(strings.ToLower(strings.TrimSpace(filter.Email))))
+	}
+	if filter.Username != "" {
+		query = query.Where("lower(username) LIKE ?", likePattern(strings.ToLower(strings.TrimSpace(filter.Username))))
+	}
+	if filter.Phone != "" {
+		query = query.Where("phone LIKE ?", likePattern(strings.TrimSpace(filter.Phone)))
+	}
+	if filter.Name != "" {
+		query = query.Where(normalizedName+" LIKE ?", vietnameseLetters, vietnameseLettersASCII, likePattern(NormalizeSearchText(filter.Name)))
+	}
+	if filter.Permission != "" {
+		query = query.Where("permission = ?", filter.Permission)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting users: %v", err)
+	}
+
+	var users []entity2.User
+	if err := query.Order("full_name").Order("user_id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("error searching users: %v", err)
+	}
+	return users, total, nil
+}
+
+// UpdateUserPermission replaces the legacy permission column and swaps the role named by the old value
+// for the role named by the new one. Roles granted through the roles API are left alone.
+func (r *Repository) UpdateUserPermission(userID uuid.UUID, permission string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		var user entity2.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("user_id", "permission").
+			Where("user_id = ?", userID).First(&user).Error; err != nil {
+			return fmt.Errorf("error getting permission for user %s: %v", userID, err)
+		}
+		if user.Permission == permission {
+			return r.grantInitialRole(tx, &user)
+		}
+
+		if err := r.revokeInitialRole(tx, &user); err != nil {
+			return err
+		}
+		if err := tx.Model(&entity2.User{}).Where("user_id = ?", userID).Update("permission", permission).Error; err != nil {
+			return fmt.Errorf("error updating permission for user %s: %v", userID, err)
+		}
+		return r.grantInitialRole(tx, &entity2.User{UserID: userID, Permission: permission})
+	})
+}
+
+// MarkEmailVerified records the address as verified, creating the verification record when missing
+func (r *Repository) MarkEmailVerified(userID uuid.UUID, email string) error {
+	now := time.Now()
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity2.UserVerification{}).
+			Where("user_id = ? AND method = ? AND value = ?", userID, "email", email).
+			Updates(map[string]interface{}{"is_verified": true, "verified_at": now})
+		if result.Error != nil {
+			return fmt.Errorf("error updating email verification status: %v", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		verification := &entity2.UserVerification{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Method:     "email",
+			Value:      email,
+			IsVerified: true,
+			VerifiedAt: &now,
+		}
+		if err := tx.Create(verification).Error; err != nil {
+			return fmt.Errorf("error creating user verification: %v", err)
+		}
+		return nil
+	})
+}
+
+// ResetUserMFA removes every second factor of a user: OTP factors, recovery codes and passkeys
+func (r *Repository) ResetUserMFA(userID uuid.UUID) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity2.UserMFA{}).Error; err != nil {
+			return fmt.Errorf("error deleting user MFAs: %v", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&entity2.UserMFARecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("error deleting recovery codes: %v", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&entity2.UserWebAuthnCredential{}).Error; err != nil {
+			return fmt.Errorf("error deleting passkeys: %v", err)
+		}
+		return nil
+	})
+}
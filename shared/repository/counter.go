@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fixed-window counters. INCR and the expiry run as one script so a crash between them cannot leave a
+// counter without a TTL, and a counter found without one (written by an older version) gets it back.
+var incrWithExpiryScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 or redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// incrWithExpiry increments key and starts its window of ttl on the first increment
+func (r *Repository) incrWithExpiry(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return incrWithExpiryScript.Run(ctx, r.cacheDb, []string{key}, ttl.Milliseconds()).Int64()
+}
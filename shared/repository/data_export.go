@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateDataExport records a new export request
+func (r *Repository) CreateDataExport(export *entity2.UserDataExport) error {
+	if export.ID == uuid.Nil {
+		export.ID = uuid.New()
+	}
+	if err := r.Db.Create(export).Error; err != nil {
+		return fmt.Errorf("error creating data export: %v", err)
+	}
+	return nil
+}
+
+// GetLatestDataExport returns the newest export of a user without its archive, or nil when there is none
+func (r *Repository) GetLatestDataExport(userID uuid.UUID) (*entity2.UserDataExport, error) {
+	var export entity2.UserDataExport
+	err := r.Db.Omit("archive").Where("user_id = ?", userID).Order("created_at DESC").First(&export).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting data export: %v", err)
+	}
+	return &export, nil
+}
+
+// ClaimDataExport moves a pending export to processing. It returns false when another worker has it already.
+func (r *Repository) ClaimDataExport(id uuid.UUID) (bool, error) {
+	result := r.Db.Model(&entity2.UserDataExport{}).
+		Where("id = ? AND status = ?", id, entity2.DataExportPending).
+		Update("status", entity2.DataExportProcessing)
+	if result.Error != nil {
+		return false, fmt.Errorf("error claiming data export: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CompleteDataExport stores the finished archive and the hash of its download token
+func (r *Repository) CompleteDataExport(id uuid.UUID, archive []byte, tokenHash string, expiresAt time.Time) error {
+	if err := r.Db.Model(&entity2.UserDataExport{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       entity2.DataExportReady,
+			"archive":      archive,
+			"size":         len(archive),
+			"token_hash":   tokenHash,
+			"expires_at":   expiresAt,
+			"completed_at": time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("error completing data export: %v", err)
+	}
+	return nil
+}
+
+// FailDataExport marks an export as failed with a short reason
+func (r *Repository) FailDataExport(id uuid.UUID, reason string) error {
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+	if err := r.Db.Model(&entity2.UserDataExport{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status": entity2.DataExportFailed,
+			"error":  reason,
+		}).Error; err != nil {
+		return fmt.Errorf("error failing data export: %v", err)
+	}
+	return nil
+}
+
+// ConsumeDataExportDownload returns the archive behind a download token and drops it, so each link works once.
+// It returns nil when the token is unknown, expired or already used.
+func (r *Repository) ConsumeDataExportDownload(tokenHash string) (*entity2.UserDataExport, error) {
+	var export entity2.UserDataExport
+	found := false
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND status = ? AND downloaded_at IS NULL AND expires_at > ?", tokenHash, entity2.DataExportReady, time.Now()).
+			First(&export).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entity2.UserDataExport{}).Where("id = ?", export.ID).
+			Updates(map[string]interface{}{
+				"downloaded_at": time.Now(),
+				"archive":       nil,
+			}).Error; err != nil {
+			return err
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error consuming data export download: %v", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &export, nil
+}
+
+// DropExpiredDataExportArchives deletes archives whose download link expired unused
+func (r *Repository) DropExpiredDataExportArchives(now time.Time) (int64, error) {
+	result := r.Db.Model(&entity2.UserDataExport{}).
+		Where("archive IS NOT NULL AND expires_at <= ?", now).
+		Update("archive", nil)
+	if result.Error != nil {
+		return 0, fmt.Errorf("error dropping expired data export archives: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm/clause"
+)
+
+// RecordUserDeviceLogin creates the device row on first login and refreshes it afterwards.
+// A login on a previously revoked device makes it active again.
+func (r *Repository) RecordUserDeviceLogin(device *entity2.UserDevice) error {
+	now := time.Now()
+	if device.ID == uuid.Nil {
+		device.ID = uuid.New()
+	}
+	device.FirstSeenAt = now
+	device.LastSeenAt = now
+	device.RevokedAt = nil
+
+	err := r.Db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "device_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"user_agent":   device.UserAgent,
+			"ip_address":   device.IPAddress,
+			"login_method": device.LoginMethod,
+			"last_seen_at": now,
+			"revoked_at":   nil,
+			"updated_at":   now,
+		}),
+	}).Create(device).Error
+	if err != nil {
+		return fmt.Errorf("error recording user device: %v", err)
+	}
+	return nil
+}
+
+// GetActiveUserDevices returns the devices that are still signed in, most recently used first
+func (r *Repository) GetActiveUserDevices(userID uuid.UUID) ([]entity2.UserDevice, error) {
+	var devices []entity2.UserDevice
+	if err := r.Db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_seen_at DESC").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("error getting user devices: %v", err)
+	}
+	return devices, nil
+}
+
+// GetUserDevices returns every device that ever signed in to an account, including signed-out ones
+func (r *Repository) GetUserDevices(userID uuid.UUID) ([]entity2.UserDevice, error) {
+	var devices []entity2.UserDevice
+	if err := r.Db.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("error getting user devices: %v", err)
+	}
+	return devices, nil
+}
+
+// GetUserDevice returns one device of the user by its X-Device-ID value
+func (r *Repository) GetUserDevice(userID uuid.UUID, deviceID string) (*entity2.UserDevice, error) {
+	var device entity2.UserDevice
+	if err := r.Db.Where("user_id = ? AND device_id = ?", userID, deviceID).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// MarkUserDeviceRevoked flags a device as signed out
+func (r *Repository) MarkUserDeviceRevoked(userID uuid.UUID, deviceID string) error {
+	if err := r.Db.Model(&entity2.UserDevice{}).
+		Where("user_id = ? AND device_id = ? AND revoked_at IS NULL", userID, deviceID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("error revoking user device: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm"
+)
+
+// Pending email changes. The new address confirms through email_change:<token>, the old address
+// can cancel or undo the change through email_change_revert:<token> for longer.
+// Layout: email_change:<token> -> EmailChange JSON
+//         email_change_user:<user_id> -> pending confirmation token, a new request replaces it
+//         email_change_revert:<token> -> EmailChange JSON
+
+const (
+	emailChangeConfirmTTL = 24 * time.Hour
+	emailChangeRevertTTL  = 7 * 24 * time.Hour
+)
+
+type EmailChange struct {
+	UserID   string `json:"user_id"`
+	OldEmail string `json:"old_email"` // empty when the account had no email
+	NewEmail string `json:"new_email"`
+}
+
+func generateEmailChangeToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// CreateEmailChange stores a pending change and returns the confirmation and revert tokens.
+// Any earlier pending change of the user stops being confirmable.
+func (r *Repository) CreateEmailChange(ctx context.Context, change *EmailChange) (string, string, error) {
+	confirmToken, err := generateEmailChangeToken()
+	if err != nil {
+		return "", "", err
+	}
+	revertToken, err := generateEmailChangeToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode email change: %w", err)
+	}
+
+	userKey := fmt.Sprintf("email_change_user:%s", change.UserID)
+	if previous, err := r.cacheDb.Get(ctx, userKey).Result(); err == nil && previous != "" {
+		r.cacheDb.Del(ctx, fmt.Sprintf("email_change:%s", previous))
+	}
+
+	pipe := r.cacheDb.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("email_change:%s", confirmToken), payload, emailChangeConfirmTTL)
+	pipe.Set(ctx, userKey, confirmToken, emailChangeConfirmTTL)
+	pipe.Set(ctx, fmt.Sprintf("email_change_revert:%s", revertToken), payload, emailChangeRevertTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", "", fmt.Errorf("failed to store email change: %w", err)
+	}
+
+	return confirmToken, revertToken, nil
+}
+
+// ConsumeEmailChangeConfirmation validates a confirmation token and deletes it
+func (r *Repository) ConsumeEmailChangeConfirmation(ctx context.Context, token string) (*EmailChange, error) {
+	payload, err := r.cacheDb.GetDel(ctx, fmt.Sprintf("email_change:%s", token)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired token: %w", err)
+	}
+
+	var change EmailChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		return nil, fmt.Errorf("invalid email change payload: %w", err)
+	}
+
+	r.cacheDb.Del(ctx, fmt.Sprintf("email_change_user:%s", change.UserID))
+
+	return &change, nil
+}
+
+// ConsumeEmailChangeRevert validates a revert token, deletes it and cancels the pending confirmation if any
+func (r *Repository) ConsumeEmailChangeRevert(ctx context.Context, token string) (*EmailChange, error) {
+	payload, err := r.cacheDb.GetDel(ctx, fmt.Sprintf("email_change_revert:%s", token)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired token: %w", err)
+	}
+
+	var change EmailChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		return nil, fmt.Errorf("invalid email change payload: %w", err)
+	}
+
+	r.CancelPendingEmailChange(ctx, change.UserID)
+
+	return &change, nil
+}
+
+// CancelPendingEmailChange drops the user's unconfirmed email change, if any
+func (r *Repository) CancelPendingEmailChange(ctx context.Context, userID string) {
+	userKey := fmt.Sprintf("email_change_user:%s", userID)
+	if pending, err := r.cacheDb.GetDel(ctx, userKey).Result(); err == nil && pending != "" {
+		r.cacheDb.Del(ctx, fmt.Sprintf("email_change:%s", pending))
+	}
+}
+
+// GetPendingEmailChange returns the user's unconfirmed email change, or nil when there is none
+func (r *Repository) GetPendingEmailChange(ctx context.Context, userID string) (*EmailChange, error) {
+	token, err := r.cacheDb.Get(ctx, fmt.Sprintf("email_change_user:%s", userID)).Result()
+	if err != nil {
+		return nil, nil
+	}
+	payload, err := r.cacheDb.Get(ctx, fmt.Sprintf("email_change:%s", token)).Result()
+	if err != nil {
+		return nil, nil
+	}
+
+	var change EmailChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		return nil, fmt.Errorf("invalid email change payload: %w", err)
+	}
+	return &change, nil
+}
+
+// EmailChangeConfirmTTL returns how long the confirmation link sent to the new address stays valid
+func (r *Repository) EmailChangeConfirmTTL() time.Duration {
+	return emailChangeConfirmTTL
+}
+
+// EmailChangeRevertTTL returns how long the old address can undo the change
+func (r *Repository) EmailChangeRevertTTL() time.Duration {
+	return emailChangeRevertTTL
+}
+
+// SwapUserEmail replaces the user's email and its verification row in one transaction. The update
+// only applies while the current email still equals fromEmail ("" for none); the new address is
+// stored as verified because the caller has just proven ownership of it.
+func (r *Repository) SwapUserEmail(userID uuid.UUID, fromEmail, toEmail string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&entity2.User{}).Where("user_id = ?", userID)
+		if fromEmail == "" {
+			query = query.Where("email IS NULL OR email = ''")
+		} else {
+			query = query.Where("email = ?", fromEmail)
+		}
+		result := query.Update("email", toEmail)
+		if result.Error != nil {
+			return fmt.Errorf("error updating email for user %s: %v", userID, result.Error)
+		}
+		if result.RowsAffected != 1 {
+			return fmt.Errorf("email of user %s changed concurrently", userID)
+		}
+
+		if err := tx.Where("user_id = ? AND method = ?", userID, "email").Delete(&entity2.UserVerification{}).Error; err != nil {
+			return fmt.Errorf("error removing email verifications for user %s: %v", userID, err)
+		}
+
+		now := time.Now()
+		verification := entity2.UserVerification{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Method:     "email",
+			Value:      toEmail,
+			IsVerified: true,
+			VerifiedAt: &now,
+		}
+		if err := tx.Create(&verification).Error; err != nil {
+			return fmt.Errorf("error creating email verification for user %s: %v", userID, err)
+		}
+
+		return nil
+	})
+}
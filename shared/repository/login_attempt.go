@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Login failure counters live in Redis under three scopes so that both credential stuffing
+// (many identifiers from one IP) and targeted guessing (one identifier from many IPs) are slowed down:
+//   login_failures:id:<identifier>, login_failures:ip:<ip>, login_failures:idip:<identifier>:<ip>
+// A login_delay:<scope> key holds the exponential back-off and login_lock:<identifier> the temporary lock.
+// login_failure_ips:<identifier> is the set of IPs with an idip counter, so they can be cleared by exact key.
+
+const loginLockCountTTL = 24 * time.Hour
+
+// LoginThrottlePolicy controls when delays and locks are applied
+type LoginThrottlePolicy struct {
+	FailureWindow    time.Duration
+	DelayThreshold   int64
+	MaxDelay         time.Duration
+	LockThreshold    int64
+	LockDuration     time.Duration
+	IPDelayThreshold int64
+}
+
+// LoginFailureResult describes the state after a failed attempt has been recorded
+type LoginFailureResult struct {
+	IdentifierFailures int64
+	Locked             bool
+	FirstLockout       bool
+	LockDuration       time.Duration
+}
+
+// GetLoginLock returns the remaining lock time of an identifier, or 0 when it is not locked
+func (r *Repository) GetLoginLock(ctx context.Context, identifier string) (time.Duration, error) {
+	ttl, err := r.cacheDb.PTTL(ctx, fmt.Sprintf("login_lock:%s", identifier)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get login lock: %w", err)
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// GetLoginDelay returns how long the caller has to wait before the next attempt, or 0 when no delay applies
+func (r *Repository) GetLoginDelay(ctx context.Context, identifier, ip string) (time.Duration, error) {
+	var longest time.Duration
+	for _, scope := range loginScopes(identifier, ip) {
+		ttl, err := r.cacheDb.PTTL(ctx, fmt.Sprintf("login_delay:%s", scope)).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get login delay: %w", err)
+		}
+		if ttl > longest {
+			longest = ttl
+		}
+	}
+	return longest, nil
+}
+
+// RecordLoginFailure increments all failure counters, applies exponential delays and locks the identifier when needed
+func (r *Repository) RecordLoginFailure(ctx context.Context, identifier, ip string, policy LoginThrottlePolicy) (*LoginFailureResult, error) {
+	scopes := loginScopes(identifier, ip)
+	thresholds := []int64{policy.DelayThreshold, policy.IPDelayThreshold, policy.DelayThreshold}
+
+	result := &LoginFailureResult{}
+	for i, scope := range scopes {
+		count, err := r.incrWithExpiry(ctx, fmt.Sprintf("login_failures:%s", scope), policy.FailureWindow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record login failure: %w", err)
+		}
+		if i == 0 {
+			result.IdentifierFailures = count
+		}
+
+		if count >= thresholds[i] {
+			delay := loginBackoff(count-thresholds[i], policy.MaxDelay)
+			if err := r.cacheDb.Set(ctx, fmt.Sprintf("login_delay:%s", scope), count, delay).Err(); err != nil {
+				return nil, fmt.Errorf("failed to set login delay: %w", err)
+			}
+		}
+	}
+
+	// The set lives as long as the longest idip counter or delay it points to
+	ipsKey := loginFailureIPsKey(identifier)
+	pipe := r.cacheDb.TxPipeline()
+	pipe.SAdd(ctx, ipsKey, ip)
+	pipe.Expire(ctx, ipsKey, max(policy.FailureWindow, policy.MaxDelay))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to record login failure ip: %w", err)
+	}
+
+	if result.IdentifierFailures >= policy.LockThreshold {
+		locked, err := r.cacheDb.SetNX(ctx, fmt.Sprintf("login_lock:%s", identifier), time.Now().Unix(), policy.LockDuration).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock identifier: %w", err)
+		}
+		if locked {
+			result.Locked = true
+			result.LockDuration = policy.LockDuration
+
+			lockCount, err := r.incrWithExpiry(ctx, fmt.Sprintf("login_lock_count:%s", identifier), loginLockCountTTL)
+			if err == nil && lockCount == 1 {
+				result.FirstLockout = true
+			}
+
+			// Start counting from zero once the lock expires
+			r.cacheDb.Del(ctx, fmt.Sprintf("login_failures:%s", scopes[0]), fmt.Sprintf("login_failures:%s", scopes[2]))
+		}
+	}
+
+	return result, nil
+}
+
+// ClearLoginFailures resets the identifier counters after a successful login. The per-IP counter is kept
+// so that one valid account cannot be used to reset the throttle of an attacking IP.
+func (r *Repository) ClearLoginFailures(ctx context.Context, identifier, ip string) error {
+	scopes := loginScopes(identifier, ip)
+	if err := r.cacheDb.Del(ctx,
+		fmt.Sprintf("login_failures:%s", scopes[0]),
+		fmt.Sprintf("login_failures:%s", scopes[2]),
+		fmt.Sprintf("login_delay:%s", scopes[0]),
+		fmt.Sprintf("login_delay:%s", scopes[2]),
+		fmt.Sprintf("login_lock:%s", identifier),
+	).Err(); err != nil {
+		return fmt.Errorf("failed to clear login failures: %w", err)
+	}
+	return nil
+}
+
+// ClearLoginLocks removes locks, delays and counters of the given identifiers regardless of IP (used after a password reset)
+func (r *Repository) ClearLoginLocks(ctx context.Context, identifiers ...string) error {
+	for _, identifier := range identifiers {
+		ipsKey := loginFailureIPsKey(identifier)
+		keys := []string{
+			fmt.Sprintf("login_failures:id:%s", identifier),
+			fmt.Sprintf("login_delay:id:%s", identifier),
+			fmt.Sprintf("login_lock:%s", identifier),
+			ipsKey,
+		}
+
+		ips, err := r.cacheDb.SMembers(ctx, ipsKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get login failure ips: %w", err)
+		}
+		for _, ip := range ips {
+			keys = append(keys,
+				fmt.Sprintf("login_failures:idip:%s:%s", identifier, ip),
+				fmt.Sprintf("login_delay:idip:%s:%s", identifier, ip),
+			)
+		}
+
+		if err := r.cacheDb.Del(ctx, keys...).Err(); err != nil {
+			return fmt.Errorf("failed to clear login locks: %w", err)
+		}
+	}
+	return nil
+}
+
+func loginFailureIPsKey(identifier string) string {
+	return fmt.Sprintf("login_failure_ips:%s", identifier)
+}
+
+func loginScopes(identifier, ip string) []string {
+	return []string{
+		fmt.Sprintf("id:%s", identifier),
+		fmt.Sprintf("ip:%s", ip),
+		fmt.Sprintf("idip:%s:%s", identifier, ip),
+	}
+}
+
+// loginBackoff doubles the delay for every failure past the threshold: 1s, 2s, 4s, ... capped at maxDelay
+func loginBackoff(overThreshold int64, maxDelay time.Duration) time.Duration {
+	if overThreshold > 30 {
+		return maxDelay
+	}
+	delay := time.Second << overThreshold
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+)
+
+// LoginFamiliarity tells whether a user already signed in successfully from a device or network
+type LoginFamiliarity struct {
+	HasHistory   bool
+	KnownDevice  bool
+	KnownNetwork bool
+}
+
+// CreateLoginEvent appends an entry to the login history
+func (r *Repository) CreateLoginEvent(event *entity2.LoginEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if err := r.Db.Create(event).Error; err != nil {
+		return fmt.Errorf("error creating login event: %v", err)
+	}
+	return nil
+}
+
+// GetLoginEvents returns one page of a user's login history, newest first, and the total number of entries
+func (r *Repository) GetLoginEvents(userID uuid.UUID, offset, limit int) ([]entity2.LoginEvent, int64, error) {
+	var total int64
+	if err := r.Db.Model(&entity2.LoginEvent{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting login events: %v", err)
+	}
+
+	var events []entity2.LoginEvent
+	if err := r.Db.Where("user_id = ?", userID).
+		Order("created_at DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("error getting login events: %v", err)
+	}
+	return events, total, nil
+}
+
+// GetLoginFamiliarity looks at the user's previous successful logins for the given device and network
+func (r *Repository) GetLoginFamiliarity(userID uuid.UUID, deviceID, network string) (*LoginFamiliarity, error) {
+	var familiarity LoginFamiliarity
+	err := r.Db.Raw(`
+		SELECT COUNT(*) > 0 AS has_history,
+		       COALESCE(BOOL_OR(device_id = ?), FALSE) AS known_device,
+		       COALESCE(BOOL_OR(network = ?), FALSE) AS known_network
+		FROM login_events
+		WHERE user_id = ? AND success`, deviceID, network, userID).Scan(&familiarity).Error
+	if err != nil {
+		return nil, fmt.Errorf("error checking login familiarity: %v", err)
+	}
+	return &familiarity, nil
+}
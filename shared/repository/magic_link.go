@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Single-use passwordless login links, bound to the device that asked for them.
+// Layout: magic_link:<token> -> MagicLink JSON
+//         magic_link_user:<user_id> -> latest token, so a new link invalidates the previous one
+
+const magicLinkTTL = 15 * time.Minute
+
+type MagicLink struct {
+	UserID   string `json:"user_id"`
+	DeviceID string `json:"device_id"`
+}
+
+// GenerateMagicLinkToken issues a login token for the user and device, replacing any earlier one
+func (r *Repository) GenerateMagicLinkToken(ctx context.Context, userID, deviceID string) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	payload, err := json.Marshal(MagicLink{UserID: userID, DeviceID: deviceID})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode magic link: %w", err)
+	}
+
+	userKey := fmt.Sprintf("magic_link_user:%s", userID)
+	if previous, err := r.cacheDb.Get(ctx, userKey).Result(); err == nil && previous != "" {
+		r.cacheDb.Del(ctx, fmt.Sprintf("magic_link:%s", previous))
+	}
+
+	if err := r.cacheDb.Set(ctx, fmt.Sprintf("magic_link:%s", token), payload, magicLinkTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	if err := r.cacheDb.Set(ctx, userKey, token, magicLinkTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store token owner: %w", err)
+	}
+
+	return token, nil
+}
+
+// ConsumeMagicLinkToken validates a login token and deletes it so it cannot be used twice
+func (r *Repository) ConsumeMagicLinkToken(ctx context.Context, token string) (*MagicLink, error) {
+	payload, err := r.cacheDb.GetDel(ctx, fmt.Sprintf("magic_link:%s", token)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired token: %w", err)
+	}
+
+	var link MagicLink
+	if err := json.Unmarshal([]byte(payload), &link); err != nil {
+		return nil, fmt.Errorf("invalid magic link payload: %w", err)
+	}
+
+	r.cacheDb.Del(ctx, fmt.Sprintf("magic_link_user:%s", link.UserID))
+
+	return &link, nil
+}
+
+// MagicLinkTTL returns how long a login link stays valid
+func (r *Repository) MagicLinkTTL() time.Duration {
+	return magicLinkTTL
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// An MFA challenge is created after the first factor succeeded and is exchanged for tokens
+// once the user proves a second factor.
+// Layout: mfa_challenge:<id> -> JSON MFAChallenge, mfa_challenge_attempts:<id> -> counter
+
+const mfaChallengeTTL = 5 * time.Minute
+
+type MFAChallenge struct {
+	UserID   string   `json:"user_id"`
+	DeviceID string   `json:"device_id"`
+	Method   string   `json:"method"` // first factor: "password" | "google" | "magic_link" | "email_code"
+	Factors  []string `json:"factors"`
+	// KeepLogin carries the "keep me logged in" choice made at the first step
+	KeepLogin bool `json:"keep_login,omitempty"`
+}
+
+func mfaChallengeKey(challengeID string) string {
+	return fmt.Sprintf("mfa_challenge:%s", challengeID)
+}
+
+func mfaChallengeAttemptsKey(challengeID string) string {
+	return fmt.Sprintf("mfa_challenge_attempts:%s", challengeID)
+}
+
+// CreateMFAChallenge stores a pending second-factor challenge and returns its ID
+func (r *Repository) CreateMFAChallenge(ctx context.Context, challenge *MFAChallenge) (string, error) {
+	idBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate challenge id: %w", err)
+	}
+	challengeID := hex.EncodeToString(idBytes)
+
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode challenge: %w", err)
+	}
+
+	if err := r.cacheDb.Set(ctx, mfaChallengeKey(challengeID), data, mfaChallengeTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store challenge: %w", err)
+	}
+
+	return challengeID, nil
+}
+
+// GetMFAChallenge loads a pending challenge; an expired or unknown ID returns an error
+func (r *Repository) GetMFAChallenge(ctx context.Context, challengeID string) (*MFAChallenge, error) {
+	data, err := r.cacheDb.Get(ctx, mfaChallengeKey(challengeID)).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired challenge: %w", err)
+	}
+
+	var challenge MFAChallenge
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, fmt.Errorf("failed to decode challenge: %w", err)
+	}
+
+	return &challenge, nil
+}
+
+// IncrementMFAChallengeAttempts counts a failed verification against the challenge
+func (r *Repository) IncrementMFAChallengeAttempts(ctx context.Context, challengeID string) (int64, error) {
+	attempts, err := r.incrWithExpiry(ctx, mfaChallengeAttemptsKey(challengeID), mfaChallengeTTL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count challenge attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+// DeleteMFAChallenge removes a challenge so it cannot be completed again. It fails when the
+// challenge was already removed, which stops two concurrent requests from both completing it.
+func (r *Repository) DeleteMFAChallenge(ctx context.Context, challengeID string) error {
+	deleted, err := r.cacheDb.Del(ctx, mfaChallengeKey(challengeID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete challenge: %w", err)
+	}
+	r.cacheDb.Del(ctx, mfaChallengeAttemptsKey(challengeID))
+	if deleted == 0 {
+		return fmt.Errorf("challenge already completed or expired")
+	}
+	return nil
+}
+
+// MFAChallengeTTL returns how long the user has to complete the second factor
+func (r *Repository) MFAChallengeTTL() time.Duration {
+	return mfaChallengeTTL
+}
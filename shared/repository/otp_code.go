@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// One-time numeric codes sent over email or SMS. Only a SHA-256 hash of the code is kept.
+// Layout: otp_code:<purpose>:<subject> -> hash { hash, attempts }
+//         otp_cooldown:<purpose>:<subject> -> "1" while a new code may not be sent yet
+
+const (
+	otpCodeTTL         = 10 * time.Minute
+	otpMaxAttempts     = 5
+	otpResendCooldown  = 60 * time.Second
+	otpCodeDigitLength = 6
+)
+
+func otpCodeKey(purpose, subject string) string {
+	return fmt.Sprintf("otp_code:%s:%s", purpose, subject)
+}
+
+func otpCooldownKey(purpose, subject string) string {
+	return fmt.Sprintf("otp_cooldown:%s:%s", purpose, subject)
+}
+
+func hashOTPCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateOTPCode creates a 6-digit code for the purpose/subject pair, replacing any previous code
+func (r *Repository) GenerateOTPCode(ctx context.Context, purpose, subject string) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < otpCodeDigitLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	code := fmt.Sprintf("%0*d", otpCodeDigitLength, n.Int64())
+
+	key := otpCodeKey(purpose, subject)
+	pipe := r.cacheDb.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "hash", hashOTPCode(code), "attempts", 0)
+	pipe.Expire(ctx, key, otpCodeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to store code: %w", err)
+	}
+
+	return code, nil
+}
+
+// Checks the code and counts the attempt in one step. A separate HINCRBY on a code that expired in between
+// would recreate the key without a TTL and leave a code-less hash behind forever.
+// Only hashes are compared, so the plain string comparison reveals nothing about the code.
+// Returns 1 when the code matched and was consumed, 0 for a wrong code, -1 when there is no code.
+var verifyOTPCodeScript = redis.NewScript(`
+local stored = redis.call('HGET', KEYS[1], 'hash')
+if not stored then
+	return -1
+end
+if stored == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+if redis.call('HINCRBY', KEYS[1], 'attempts', 1) >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// VerifyOTPCode checks a code and burns it on success. Every wrong guess counts against the code
+// and it is discarded once the attempt limit is reached.
+func (r *Repository) VerifyOTPCode(ctx context.Context, purpose, subject, code string) (bool, error) {
+	result, err := verifyOTPCodeScript.Run(ctx, r.cacheDb, []string{otpCodeKey(purpose, subject)}, hashOTPCode(code), otpMaxAttempts).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to verify code: %w", err)
+	}
+	if result < 0 {
+		return false, fmt.Errorf("invalid or expired code")
+	}
+	return result == 1, nil
+}
+
+// AcquireOTPCooldown reserves the right to send a new code. When a code was sent too recently it
+// returns the time left before another one may be sent.
+func (r *Repository) AcquireOTPCooldown(ctx context.Context, purpose, subject string) (time.Duration, error) {
+	key := otpCooldownKey(purpose, subject)
+
+	acquired, err := r.cacheDb.SetNX(ctx, key, "1", otpResendCooldown).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to set cooldown: %w", err)
+	}
+	if acquired {
+		return 0, nil
+	}
+
+	remaining, err := r.cacheDb.TTL(ctx, key).Result()
+	if err != nil || remaining <= 0 {
+		return otpResendCooldown, nil
+	}
+	return remaining, nil
+}
+
+// OTPCodeTTL returns how long a one-time code stays valid
+func (r *Repository) OTPCodeTTL() time.Duration {
+	return otpCodeTTL
+}
+
+// CountOTPSend increments the number of codes sent to a destination (e.g. a phone number) in the
+// given window and returns the new count with the time left until the window resets
+func (r *Repository) CountOTPSend(ctx context.Context, destination string, window time.Duration) (int64, time.Duration, error) {
+	key := fmt.Sprintf("otp_send_count:%d:%s", int64(window.Seconds()), destination)
+
+	count, err := r.incrWithExpiry(ctx, key, window)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count sent code: %w", err)
+	}
+	if count == 1 {
+		return count, window, nil
+	}
+
+	remaining, err := r.cacheDb.PTTL(ctx, key).Result()
+	if err != nil || remaining <= 0 {
+		remaining = window
+	}
+	return count, remaining, nil
+}
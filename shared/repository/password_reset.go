@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const passwordResetTokenTTL = 30 * time.Minute
+
+// GeneratePasswordResetToken issues a single-use reset token and invalidates any token issued before it
+func (r *Repository) GeneratePasswordResetToken(ctx context.Context, userID string) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	userKey := fmt.Sprintf("password_reset_user:%s", userID)
+	if previous, err := r.cacheDb.Get(ctx, userKey).Result(); err == nil && previous != "" {
+		r.cacheDb.Del(ctx, fmt.Sprintf("password_reset:%s", previous))
+	}
+
+	key := fmt.Sprintf("password_reset:%s", token)
+	if err := r.cacheDb.Set(ctx, key, userID, passwordResetTokenTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	if err := r.cacheDb.Set(ctx, userKey, token, passwordResetTokenTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store token owner: %w", err)
+	}
+
+	return token, nil
+}
+
+// ConsumePasswordResetToken validates a reset token and deletes it so it cannot be used twice
+func (r *Repository) ConsumePasswordResetToken(ctx context.Context, token string) (string, error) {
+	key := fmt.Sprintf("password_reset:%s", token)
+
+	userID, err := r.cacheDb.GetDel(ctx, key).Result()
+	if err != nil {
+		return "", fmt.Errorf("invalid or expired token: %w", err)
+	}
+
+	r.cacheDb.Del(ctx, fmt.Sprintf("password_reset_user:%s", userID))
+
+	return userID, nil
+}
+
+// PasswordResetTokenTTL returns how long a reset link stays valid
+func (r *Repository) PasswordResetTokenTTL() time.Duration {
+	return passwordResetTokenTTL
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Permissions RequirePermission resolved for a user, dropped whenever the user's roles change.
+// Layout: user_permissions:<user_id> -> json([]string)
+
+// DefaultRoleName is granted to accounts whose legacy permission column is empty
+const DefaultRoleName = "member"
+
+// ListRoles returns every role with its permissions
+func (r *Repository) ListRoles() ([]entity2.Role, error) {
+	var roles []entity2.Role
+	if err := r.Db.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("error listing roles: %v", err)
+	}
+	return roles, nil
+}
+
+// GetRoleByName finds a role by its unique name
+func (r *Repository) GetRoleByName(name string) (*entity2.Role, error) {
+	var role entity2.Role
+	if err := r.Db.Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// GetUserRoles returns the roles granted to a user with their permissions
+func (r *Repository) GetUserRoles(userID uuid.UUID) ([]entity2.Role, error) {
+	var roles []entity2.Role
+	if err := r.Db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("error getting user roles: %v", err)
+	}
+	return roles, nil
+}
+
+// GetUserPermissions returns the distinct permission names a user holds through its roles
+func (r *Repository) GetUserPermissions(userID uuid.UUID) ([]string, error) {
+	var permissions []string
+	if err := r.Db.Model(&entity2.Permission{}).Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Order("permissions.name").Pluck("permissions.name", &permissions).Error; err != nil {
+		return nil, fmt.Errorf("error getting user permissions: %v", err)
+	}
+	return permissions, nil
+}
+
+func userPermissionsKey(userID uuid.UUID) string {
+	return fmt.Sprintf("user_permissions:%s", userID)
+}
+
+// GetCachedUserPermissions returns the cached permissions of a user, found is false when nothing is cached
+func (r *Repository) GetCachedUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, bool, error) {
+	value, err := r.cacheDb.Get(ctx, userPermissionsKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get cached user permissions: %w", err)
+	}
+	var permissions []string
+	if err := json.Unmarshal([]byte(value), &permissions); err != nil {
+		return nil, false, nil
+	}
+	return permissions, true, nil
+}
+
+// SetCachedUserPermissions caches the permissions of a user for ttl
+func (r *Repository) SetCachedUserPermissions(ctx context.Context, userID uuid.UUID, permissions []string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(permissions)
+	if err != nil {
+		return fmt.Errorf("failed to encode user permissions: %w", err)
+	}
+	if err := r.cacheDb.Set(ctx, userPermissionsKey(userID), value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache user permissions: %w", err)
+	}
+	return nil
+}
+
+// DeleteCachedUserPermissions drops the cached permissions after a role change
+func (r *Repository) DeleteCachedUserPermissions(ctx context.Context, userID uuid.UUID) error {
+	if err := r.cacheDb.Del(ctx, userPermissionsKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete cached user permissions: %w", err)
+	}
+	return nil
+}
+
+// GrantUserRole gives a role to a user, granting an already held role is a no-op
+func (r *Repository) GrantUserRole(userID, roleID uuid.UUID, grantedBy *uuid.UUID) error {
+	return r.grantUserRole(r.Db, userID, roleID, grantedBy)
+}
+
+func (r *Repository) grantUserRole(db *gorm.DB, userID, roleID uuid.UUID, grantedBy *uuid.UUID) error {
+	userRole := &entity2.UserRole{UserID: userID, RoleID: roleID, GrantedBy: grantedBy}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(userRole).Error; err != nil {
+		return fmt.Errorf("error granting role: %v", err)
+	}
+	return nil
+}
+
+// RevokeUserRole takes a role away from a user and reports whether the user held it
+func (r *Repository) RevokeUserRole(userID, roleID uuid.UUID) (bool, error) {
+	result := r.Db.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&entity2.UserRole{})
+	if result.Error != nil {
+		return false, fmt.Errorf("error revoking role: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// initialRoleName is the role matching the legacy permission column
+func initialRoleName(user *entity2.User) string {
+	if user.Permission == "" {
+		return DefaultRoleName
+	}
+	return user.Permission
+}
+
+// revokeInitialRole takes away the role grantInitialRole gave for the current permission column
+func (r *Repository) revokeInitialRole(db *gorm.DB, user *entity2.User) error {
+	roleIDs := db.Model(&entity2.Role{}).Select("id").Where("name = ?", initialRoleName(user))
+	if err := db.Where("user_id = ? AND role_id IN (?)", user.UserID, roleIDs).Delete(&entity2.UserRole{}).Error; err != nil {
+		return fmt.Errorf("error revoking initial role: %v", err)
+	}
+	return nil
+}
+
+// grantInitialRole gives a new account the role named by its permission column, if that role exists
+func (r *Repository) grantInitialRole(db *gorm.DB, user *entity2.User) error {
+	var role entity2.Role
+	if err := db.Where("name = ?", initialRoleName(user)).Limit(1).Find(&role).Error; err != nil {
+		return fmt.Errorf("error finding initial role: %v", err)
+	}
+	if role.ID == uuid.Nil {
+		return nil
+	}
+	return r.grantUserRole(db, user.UserID, role.ID, nil)
+}
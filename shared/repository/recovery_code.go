@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm"
+)
+
+// ReplaceRecoveryCodes drops every existing recovery code of the user and stores the new hashes
+func (r *Repository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.UserMFARecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("error deleting recovery codes: %v", err)
+		}
+
+		codes := make([]entity.UserMFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, entity.UserMFARecoveryCode{
+				ID:       uuid.New(),
+				UserID:   userID,
+				CodeHash: hash,
+			})
+		}
+		if err := tx.Create(&codes).Error; err != nil {
+			return fmt.Errorf("error creating recovery codes: %v", err)
+		}
+		return nil
+	})
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes the user can still use
+func (r *Repository) CountUnusedRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.Db.Model(&entity.UserMFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %v", err)
+	}
+	return count, nil
+}
+
+// UseRecoveryCode burns a recovery code. The conditional update makes sure a code is accepted only once
+// even when two requests race with the same code.
+func (r *Repository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	result := r.Db.Model(&entity.UserMFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("error using recovery code: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteRecoveryCodes removes every recovery code of the user
+func (r *Repository) DeleteRecoveryCodes(userID uuid.UUID) error {
+	if err := r.Db.Where("user_id = ?", userID).Delete(&entity.UserMFARecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("error deleting recovery codes: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Sessions are indexed per user so that every device can be signed out later. The refresh token itself
+// is never stored readable: the entry keeps its SHA-256 to recognise it and a copy sealed with the secret
+// encryption key, opened only to revoke it at the authorization service.
+// Layout: user_sessions:<userID> -> hash { deviceID: json(UserSession) }, expiring with its longest session
+
+// UserSession is one device's entry in the session index
+type UserSession struct {
+	TokenHash   string    `json:"token_hash"`
+	SealedToken string    `json:"sealed_token"`
+	KeepLogin   bool      `json:"keep_login"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Matches reports whether refreshToken is the token this session was created with
+func (s *UserSession) Matches(refreshToken string) bool {
+	return s.TokenHash == HashSessionToken(refreshToken)
+}
+
+// HashSessionToken returns the hex SHA-256 stored for a refresh token
+func HashSessionToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("user_sessions:%s", userID)
+}
+
+// Sets the field and only ever extends the key expiry, so a short session does not cut a longer one short
+var saveUserSessionScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if redis.call('TTL', KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call('EXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// SaveUserSession records the session of a device until session.ExpiresAt
+func (r *Repository) SaveUserSession(ctx context.Context, userID, deviceID string, session UserSession) error {
+	ttl := int64(time.Until(session.ExpiresAt).Seconds())
+	if ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := saveUserSessionScript.Run(ctx, r.cacheDb, []string{userSessionsKey(userID)}, deviceID, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// GetUserSessions returns the unexpired sessions of a user keyed by device ID
+func (r *Repository) GetUserSessions(ctx context.Context, userID string) (map[string]UserSession, error) {
+	entries, err := r.cacheDb.HGetAll(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	sessions := make(map[string]UserSession, len(entries))
+	for deviceID, value := range entries {
+		if session, ok := decodeUserSession(value); ok {
+			sessions[deviceID] = session
+		}
+	}
+	return sessions, nil
+}
+
+// GetUserSession returns the session of one device, or nil when there is none
+func (r *Repository) GetUserSession(ctx context.Context, userID, deviceID string) (*UserSession, error) {
+	value, err := r.cacheDb.HGet(ctx, userSessionsKey(userID), deviceID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	session, ok := decodeUserSession(value)
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// decodeUserSession parses an index entry and reports false once the session has expired
+func decodeUserSession(value string) (UserSession, bool) {
+	var session UserSession
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		// Entry written before the index was hashed, the value is the raw refresh token
+		return UserSession{TokenHash: HashSessionToken(value), SealedToken: value}, true
+	}
+	return session, time.Now().Before(session.ExpiresAt)
+}
+
+// DeleteUserSession forgets the session of a device
+func (r *Repository) DeleteUserSession(ctx context.Context, userID, deviceID string) error {
+	if err := r.cacheDb.HDel(ctx, userSessionsKey(userID), deviceID).Err(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// ScanUserSessionOwners returns the user IDs of one SCAN page over the session index, with the cursor of the next
+// page (0 once the scan is complete). A user may appear on more than one page.
+func (r *Repository) ScanUserSessionOwners(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	keys, next, err := r.cacheDb.Scan(ctx, cursor, userSessionsKey("*"), count).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to scan sessions: %w", err)
+	}
+	userIDs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		userIDs = append(userIDs, strings.TrimPrefix(key, userSessionsKey("")))
+	}
+	return userIDs, next, nil
+}
+
+// Replaces the entry only while it still holds the sealed token that was read, the key expiry is left as is
+var replaceUserSessionScript = redis.NewScript(`
+local value = redis.call('HGET', KEYS[1], ARGV[1])
+if not value then
+	return 0
+end
+local ok, session = pcall(cjson.decode, value)
+if not ok or type(session) ~= 'table' or session.sealed_token ~= ARGV[2] then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// ReplaceUserSessionToken stores session, re-sealed, in place of the entry whose sealed token is stored. It reports
+// false when the device has signed in again (or out) since the entry was read.
+func (r *Repository) ReplaceUserSessionToken(ctx context.Context, userID, deviceID, stored string, session UserSession) (bool, error) {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode session: %w", err)
+	}
+	replaced, err := replaceUserSessionScript.Run(ctx, r.cacheDb, []string{userSessionsKey(userID)}, deviceID, stored, value).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to replace session: %w", err)
+	}
+	return replaced == 1, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDecodeUserSession(t *testing.T) {
+	live, _ := json.Marshal(UserSession{TokenHash: HashSessionToken("refresh"), SealedToken: "enc:k1:x", ExpiresAt: time.Now().Add(time.Hour)})
+	expired, _ := json.Marshal(UserSession{TokenHash: HashSessionToken("refresh"), SealedToken: "enc:k1:x", ExpiresAt: time.Now().Add(-time.Second)})
+
+	tests := []struct {
+		name       string
+		value      string
+		wantOK     bool
+		wantSealed string
+	}{
+		{"live session", string(live), true, "enc:k1:x"},
+		{"expired session", string(expired), false, "enc:k1:x"},
+		{"legacy raw token", "eyJhbGciOiJIUzI1NiJ9.e30.sig", true, "eyJhbGciOiJIUzI1NiJ9.e30.sig"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, ok := decodeUserSession(tt.value)
+			if ok != tt.wantOK || session.SealedToken != tt.wantSealed {
+				t.Fatalf("decodeUserSession = %+v, %v", session, ok)
+			}
+		})
+	}
+
+	session, _ := decodeUserSession(string(live))
+	if !session.Matches("refresh") || session.Matches("other") {
+		t.Fatal("Matches does not compare against the stored hash")
+	}
+	legacy, _ := decodeUserSession("raw-token")
+	if !legacy.Matches("raw-token") {
+		t.Fatal("legacy entry does not match its raw token")
+	}
+}
+
+func TestReplaceUserSessionToken(t *testing.T) {
+	repo, server := newTestRepository(t)
+	ctx := context.Background()
+
+	session := UserSession{TokenHash: HashSessionToken("refresh"), SealedToken: "enc:k1:old", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repo.SaveUserSession(ctx, "user-1", "device-1", session); err != nil {
+		t.Fatalf("SaveUserSession: %v", err)
+	}
+	server.HSet(userSessionsKey("user-2"), "device-1", "legacy-raw-token")
+
+	var owners []string
+	var cursor uint64
+	for {
+		page, next, err := repo.ScanUserSessionOwners(ctx, cursor, 10)
+		if err != nil {
+			t.Fatalf("ScanUserSessionOwners: %v", err)
+		}
+		owners = append(owners, page...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	if len(owners) != 2 {
+		t.Fatalf("ScanUserSessionOwners = %v, want user-1 and user-2", owners)
+	}
+
+	resealed := session
+	resealed.SealedToken = "enc:k2:new"
+	if replaced, err := repo.ReplaceUserSessionToken(ctx, "user-1", "device-1", "enc:k1:stale", resealed); err != nil || replaced {
+		t.Fatalf("ReplaceUserSessionToken with a stale token = %v, %v; want false", replaced, err)
+	}
+	if replaced, err := repo.ReplaceUserSessionToken(ctx, "user-1", "device-1", "enc:k1:old", resealed); err != nil || !replaced {
+		t.Fatalf("ReplaceUserSessionToken = %v, %v; want true", replaced, err)
+	}
+	got, err := repo.GetUserSession(ctx, "user-1", "device-1")
+	if err != nil || got == nil || got.SealedToken != "enc:k2:new" || !got.Matches("refresh") {
+		t.Fatalf("GetUserSession after replace = %+v, %v", got, err)
+	}
+	if server.TTL(userSessionsKey("user-1")) <= 0 {
+		t.Fatal("replacing a session dropped the key expiry")
+	}
+
+	if replaced, err := repo.ReplaceUserSessionToken(ctx, "user-2", "device-1", "legacy-raw-token", resealed); err != nil || replaced {
+		t.Fatalf("ReplaceUserSessionToken on a legacy entry = %v, %v; want false", replaced, err)
+	}
+	if replaced, err := repo.ReplaceUserSessionToken(ctx, "user-1", "device-2", "enc:k1:old", resealed); err != nil || replaced {
+		t.Fatalf("ReplaceUserSessionToken on a missing device = %v, %v; want false", replaced, err)
+	}
+}
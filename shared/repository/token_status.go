@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Revocation status of access tokens as last reported by the authorization service.
+// Layout: access_token_status:<sha256(token)> -> "active" | "revoked"
+
+const (
+	AccessTokenActive  = "active"
+	AccessTokenRevoked = "revoked"
+)
+
+func accessTokenStatusKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("access_token_status:%s", hex.EncodeToString(sum[:]))
+}
+
+// GetAccessTokenStatus returns the cached status of a token, or "" when nothing is cached
+func (r *Repository) GetAccessTokenStatus(ctx context.Context, token string) (string, error) {
+	status, err := r.cacheDb.Get(ctx, accessTokenStatusKey(token)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token status: %w", err)
+	}
+	return status, nil
+}
+
+// SetAccessTokenStatus caches the status of a token for ttl
+func (r *Repository) SetAccessTokenStatus(ctx context.Context, token, status string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.cacheDb.Set(ctx, accessTokenStatusKey(token), status, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set access token status: %w", err)
+	}
+	return nil
+}
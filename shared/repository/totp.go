@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Last accepted TOTP time step per UserMFA record, so a code cannot be used twice.
+// Layout: totp_last_step:<mfa_id> -> step number
+
+// claimTOTPStepScript stores the step only when it is newer than the last accepted one
+var claimTOTPStepScript = redis.NewScript(`
+local last = redis.call('GET', KEYS[1])
+if last and tonumber(last) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+func totpLastStepKey(mfaID uuid.UUID) string {
+	return fmt.Sprintf("totp_last_step:%s", mfaID.String())
+}
+
+// ClaimTOTPStep records step as used for the MFA record. It returns false when the same or a
+// later step was already accepted, i.e. the code is a replay.
+func (r *Repository) ClaimTOTPStep(ctx context.Context, mfaID uuid.UUID, step int64, ttl time.Duration) (bool, error) {
+	claimed, err := claimTOTPStepScript.Run(ctx, r.cacheDb, []string{totpLastStepKey(mfaID)}, step, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+	return claimed == 1, nil
+}
+
+// ClearTOTPStep forgets the last accepted step, used when the secret is replaced
+func (r *Repository) ClearTOTPStep(ctx context.Context, mfaID uuid.UUID) error {
+	if err := r.cacheDb.Del(ctx, totpLastStepKey(mfaID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear TOTP step: %w", err)
+	}
+	return nil
+}
@@ -10,27 +10,32 @@ import (
 	"gorm.io/gorm"
 )
 
+// DefaultAvatarURL is shared by every account without an uploaded avatar
+const DefaultAvatarURL = "https://cdn.gauas.online/images/avatar/default_image.jpg"
+
 func (r *Repository) CreateUser(user *entity2.User) error {
 	if user.AvatarURL == nil || *user.AvatarURL == "" {
-		defaultAvatar := "https://cdn.gauas.online/images/avatar/default_image.jpg"
+		defaultAvatar := DefaultAvatarURL
 		user.AvatarURL = &defaultAvatar
 	}
-	if err := r.Db.Create(user).Error; err != nil {
-		return fmt.Errorf("error creating user: %v", err)
-	}
-	return nil
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("error creating user: %v", err)
+		}
+		return r.grantInitialRole(tx, user)
+	})
 }
 
 // CreateUserWithTransaction creates a user within a transaction
 func (r *Repository) CreateUserWithTransaction(tx *gorm.DB, user *entity2.User) error {
 	if user.AvatarURL == nil || *user.AvatarURL == "" {
-		defaultAvatar := "https://cdn.gauas.online/images/avatar/default_image.jpg"
+		defaultAvatar := DefaultAvatarURL
 		user.AvatarURL = &defaultAvatar
 	}
 	if err := tx.Create(user).Error; err != nil {
 		return fmt.Errorf("error creating user: %v", err)
 	}
-	return nil
+	return r.grantInitialRole(tx, user)
 }
 
 func (r *Repository) UpdateUser(user *entity2.User) (*entity2.User, error) {
@@ -169,7 +174,8 @@ func (r *Repository) GetUsernamesStartingWithTransaction(tx *gorm.DB, baseUserna
 	return usernames, nil
 }
 
-func (r *Repository) GetUserByIdentifierAndPassword(identifierType, identifier, hashedPassword string) (*entity2.User, error) {
+// GetUserByIdentifier finds a user by email, phone or username. Password verification is done by the caller.
+func (r *Repository) GetUserByIdentifier(identifierType, identifier string) (*entity2.User, error) {
 	var user entity2.User
 
 	var queryField string
@@ -184,13 +190,21 @@ func (r *Repository) GetUserByIdentifierAndPassword(identifierType, identifier,
 		return nil, fmt.Errorf("invalid identifier type: %s", identifierType)
 	}
 
-	if err := r.Db.Where(fmt.Sprintf("%s = ? AND password = ?", queryField), identifier, hashedPassword).First(&user).Error; err != nil {
-		return nil, fmt.Errorf("user not found with %s and password: %v", queryField, err)
+	if err := r.Db.Where(fmt.Sprintf("%s = ?", queryField), identifier).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user not found with %s: %w", queryField, err)
 	}
 
 	return &user, nil
 }
 
+// UpdateUserPassword replaces the stored password hash of a user
+func (r *Repository) UpdateUserPassword(userID uuid.UUID, hashedPassword string) error {
+	if err := r.Db.Model(&entity2.User{}).Where("user_id = ?", userID).Update("password", hashedPassword).Error; err != nil {
+		return fmt.Errorf("error updating password for user %s: %v", userID, err)
+	}
+	return nil
+}
+
 // CreateUserVerification creates a new user verification record
 func (r *Repository) CreateUserVerification(verification *entity2.UserVerification) error {
 	if err := r.Db.Create(verification).Error; err != nil {
@@ -228,6 +242,37 @@ func (r *Repository) GetUserVerificationByMethodAndValue(userID uuid.UUID, metho
 	return &verification, nil
 }
 
+// ResetPhoneVerificationWithTransaction marks every phone verification of the user as unverified and makes sure
+// the new number has a row, so a number verified in the past has to be verified again after switching back to it
+func (r *Repository) ResetPhoneVerificationWithTransaction(tx *gorm.DB, userID uuid.UUID, phone string) error {
+	if err := tx.Model(&entity2.UserVerification{}).
+		Where("user_id = ? AND method = ?", userID, "phone").
+		Updates(map[string]interface{}{
+			"is_verified": false,
+			"verified_at": nil,
+		}).Error; err != nil {
+		return fmt.Errorf("error resetting phone verification: %v", err)
+	}
+
+	var count int64
+	if err := tx.Model(&entity2.UserVerification{}).
+		Where("user_id = ? AND method = ? AND value = ?", userID, "phone", phone).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("error getting phone verification: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return r.CreateUserVerificationWithTransaction(tx, &entity2.UserVerification{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Method:     "phone",
+		Value:      phone,
+		IsVerified: false,
+	})
+}
+
 // UpdateUserVerification updates a verification record
 func (r *Repository) UpdateUserVerification(verification *entity2.UserVerification) error {
 	if err := r.Db.Save(verification).Error; err != nil {
@@ -283,3 +328,34 @@ func (r *Repository) UpdateUserMFA(mfa *entity2.UserMFA) error {
 	}
 	return nil
 }
+
+// DeleteUserMFA removes an MFA record
+func (r *Repository) DeleteUserMFA(id uuid.UUID) error {
+	if err := r.Db.Delete(&entity2.UserMFA{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("error deleting user MFA: %v", err)
+	}
+	return nil
+}
+
+// GetUserMFAsWithSecretAfter returns up to limit MFA records holding a secret, ordered by ID and
+// starting after afterID, for batch jobs that walk the whole table
+func (r *Repository) GetUserMFAsWithSecretAfter(afterID uuid.UUID, limit int) ([]entity2.UserMFA, error) {
+	var mfas []entity2.UserMFA
+	if err := r.Db.Where("secret IS NOT NULL AND secret <> '' AND id > ?", afterID).
+		Order("id").Limit(limit).Find(&mfas).Error; err != nil {
+		return nil, fmt.Errorf("error getting user MFAs with secret: %v", err)
+	}
+	return mfas, nil
+}
+
+// ReplaceUserMFASecret swaps the stored secret only if it still equals oldSecret, so a concurrent
+// TOTP reset is never overwritten. It reports whether the row was updated.
+func (r *Repository) ReplaceUserMFASecret(id uuid.UUID, oldSecret, newSecret string) (bool, error) {
+	result := r.Db.Model(&entity2.UserMFA{}).
+		Where("id = ? AND secret = ?", id, oldSecret).
+		Update("secret", newSecret)
+	if result.Error != nil {
+		return false, fmt.Errorf("error replacing user MFA secret: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
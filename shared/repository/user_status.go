@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	entity2 "github.com/tnqbao/gau-account-service/shared/entity"
+	"gorm.io/gorm"
+)
+
+// Effective account status of users with a valid token, so AuthMiddleware does not load the user on every request.
+// Layout: user_status:<user_id> -> "active" | "suspended" | ...
+
+func userStatusKey(userID uuid.UUID) string {
+	return fmt.Sprintf("user_status:%s", userID)
+}
+
+// UpdateUserStatus moves a user out of status from. It returns false when the stored status is no longer
+// from, so two concurrent changes cannot both apply. Moving to deleted schedules the purge right away.
+func (r *Repository) UpdateUserStatus(userID uuid.UUID, from, to string, reason *string, suspendedUntil *time.Time) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            to,
+		"status_reason":     reason,
+		"suspended_until":   suspendedUntil,
+		"status_changed_at": now,
+	}
+	if to == entity2.UserStatusDeleted {
+		updates["deletion_requested_at"] = gorm.Expr("COALESCE(deletion_requested_at, ?)", now)
+		updates["deletion_scheduled_at"] = now
+	}
+	result := r.Db.Model(&entity2.User{}).
+		Where("user_id = ? AND status = ?", userID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("error updating user status: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetUserStatus loads only the status columns of a user
+func (r *Repository) GetUserStatus(userID uuid.UUID) (*entity2.User, error) {
+	var user entity2.User
+	if err := r.Db.Select("user_id", "status", "suspended_until").Where("user_id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("error getting status of user %s: %w", userID, err)
+	}
+	return &user, nil
+}
+
+// GetCachedUserStatus returns the cached status of a user, or "" when nothing is cached
+func (r *Repository) GetCachedUserStatus(ctx context.Context, userID uuid.UUID) (string, error) {
+	status, err := r.cacheDb.Get(ctx, userStatusKey(userID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get cached user status: %w", err)
+	}
+	return status, nil
+}
+
+// SetCachedUserStatus caches the status of a user for ttl
+func (r *Repository) SetCachedUserStatus(ctx context.Context, userID uuid.UUID, status string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.cacheDb.Set(ctx, userStatusKey(userID), status, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache user status: %w", err)
+	}
+	return nil
+}
+
+// DeleteCachedUserStatus drops the cached status after a change
+func (r *Repository) DeleteCachedUserStatus(ctx context.Context, userID uuid.UUID) error {
+	if err := r.cacheDb.Del(ctx, userStatusKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete cached user status: %w", err)
+	}
+	return nil
+}
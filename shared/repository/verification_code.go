@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -27,24 +28,21 @@ func (r *Repository) GenerateVerificationToken(ctx context.Context, userID strin
 	return token, nil
 }
 
-// ValidateVerificationToken validates and retrieves user info from token
+// ValidateVerificationToken validates and retrieves user info from token. The token works once.
 func (r *Repository) ValidateVerificationToken(ctx context.Context, token string) (userID string, email string, err error) {
 	key := fmt.Sprintf("email_verification:%s", token)
 
-	value, err := r.cacheDb.Get(ctx, key).Result()
+	value, err := r.cacheDb.GetDel(ctx, key).Result()
 	if err != nil {
 		return "", "", fmt.Errorf("invalid or expired token: %w", err)
 	}
 
-	// Parse value (format: "userID:email")
-	var parsedUserID, parsedEmail string
-	if _, err := fmt.Sscanf(value, "%s:%s", &parsedUserID, &parsedEmail); err != nil {
-		return "", "", fmt.Errorf("invalid token format: %w", err)
+	// Value format: "userID:email", the user ID never contains a colon
+	parsedUserID, parsedEmail, found := strings.Cut(value, ":")
+	if !found || parsedUserID == "" || parsedEmail == "" {
+		return "", "", fmt.Errorf("invalid token format")
 	}
 
-	// Delete token after successful validation (one-time use)
-	r.cacheDb.Del(ctx, key)
-
 	return parsedUserID, parsedEmail, nil
 }
 
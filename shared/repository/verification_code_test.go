@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRepository returns a repository backed by an in-memory Redis, without a database
+func newTestRepository(t *testing.T) (*Repository, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &Repository{cacheDb: client}, server
+}
+
+func TestVerificationToken(t *testing.T) {
+	repo, server := newTestRepository(t)
+	ctx := context.Background()
+
+	const userID = "0b4e7c1a-3f0e-4f7e-9d2a-5c8b6a1e2f3d"
+	const email = "user+tag@example.com"
+
+	token, err := repo.GenerateVerificationToken(ctx, userID, email)
+	if err != nil {
+		t.Fatalf("GenerateVerificationToken: %v", err)
+	}
+
+	gotUserID, gotEmail, err := repo.ValidateVerificationToken(ctx, token)
+	if err != nil {
+		t.Fatalf("ValidateVerificationToken: %v", err)
+	}
+	if gotUserID != userID || gotEmail != email {
+		t.Fatalf("ValidateVerificationToken = %q, %q; want %q, %q", gotUserID, gotEmail, userID, email)
+	}
+
+	if _, _, err := repo.ValidateVerificationToken(ctx, token); err == nil {
+		t.Fatal("a verification token was accepted twice")
+	}
+	if _, _, err := repo.ValidateVerificationToken(ctx, "unknown"); err == nil {
+		t.Fatal("an unknown verification token was accepted")
+	}
+
+	server.Set("email_verification:broken", "no-separator")
+	if _, _, err := repo.ValidateVerificationToken(ctx, "broken"); err == nil {
+		t.Fatal("a malformed verification token was accepted")
+	}
+}
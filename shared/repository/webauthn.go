@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/entity"
+)
+
+// WebAuthn ceremonies keep their challenge server-side between begin and finish.
+// Layout: webauthn_session:<challenge> -> JSON WebAuthnSession
+
+const webAuthnSessionTTL = 5 * time.Minute
+
+type WebAuthnSession struct {
+	Challenge      string `json:"challenge"`
+	Ceremony       string `json:"ceremony"`                   // "registration" | "login" | "mfa"
+	UserID         string `json:"user_id,omitempty"`          // empty for discoverable passkey login
+	MFAChallengeID string `json:"mfa_challenge_id,omitempty"` // set when used as a second factor
+}
+
+// SaveWebAuthnSession stores a ceremony keyed by its challenge
+func (r *Repository) SaveWebAuthnSession(ctx context.Context, session *WebAuthnSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode webauthn session: %w", err)
+	}
+	key := fmt.Sprintf("webauthn_session:%s", session.Challenge)
+	if err := r.cacheDb.Set(ctx, key, data, webAuthnSessionTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store webauthn session: %w", err)
+	}
+	return nil
+}
+
+// ConsumeWebAuthnSession loads and deletes a ceremony so each challenge is used once
+func (r *Repository) ConsumeWebAuthnSession(ctx context.Context, challenge string) (*WebAuthnSession, error) {
+	key := fmt.Sprintf("webauthn_session:%s", challenge)
+	data, err := r.cacheDb.GetDel(ctx, key).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired webauthn session: %w", err)
+	}
+
+	var session WebAuthnSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode webauthn session: %w", err)
+	}
+	return &session, nil
+}
+
+// WebAuthnSessionTTL returns how long a ceremony may take
+func (r *Repository) WebAuthnSessionTTL() time.Duration {
+	return webAuthnSessionTTL
+}
+
+// CreateWebAuthnCredential stores a newly registered authenticator
+func (r *Repository) CreateWebAuthnCredential(credential *entity.UserWebAuthnCredential) error {
+	if err := r.Db.Create(credential).Error; err != nil {
+		return fmt.Errorf("error creating webauthn credential: %v", err)
+	}
+	return nil
+}
+
+// GetWebAuthnCredentials returns every authenticator registered by the user
+func (r *Repository) GetWebAuthnCredentials(userID uuid.UUID) ([]entity.UserWebAuthnCredential, error) {
+	var credentials []entity.UserWebAuthnCredential
+	if err := r.Db.Where("user_id = ?", userID).Order("created_at ASC").Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("error getting webauthn credentials: %v", err)
+	}
+	return credentials, nil
+}
+
+// GetWebAuthnCredentialByCredentialID looks up an authenticator by the ID the browser reports
+func (r *Repository) GetWebAuthnCredentialByCredentialID(credentialID string) (*entity.UserWebAuthnCredential, error) {
+	var credential entity.UserWebAuthnCredential
+	if err := r.Db.Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// GetUserWebAuthnCredential returns one of the user's authenticators by its row ID
+func (r *Repository) GetUserWebAuthnCredential(userID, id uuid.UUID) (*entity.UserWebAuthnCredential, error) {
+	var credential entity.UserWebAuthnCredential
+	if err := r.Db.Where("id = ? AND user_id = ?", id, userID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// CountWebAuthnCredentials returns how many authenticators the user has registered
+func (r *Repository) CountWebAuthnCredentials(userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.Db.Model(&entity.UserWebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting webauthn credentials: %v", err)
+	}
+	return count, nil
+}
+
+// RecordWebAuthnCredentialUse stores the new signature counter. The counter only moves forward, so a
+// replayed or cloned assertion racing with a legitimate one is rejected.
+func (r *Repository) RecordWebAuthnCredentialUse(id uuid.UUID, previousSignCount, signCount int64) (bool, error) {
+	result := r.Db.Model(&entity.UserWebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, previousSignCount).
+		Updates(map[string]interface{}{
+			"sign_count":   signCount,
+			"last_used_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("error updating webauthn credential: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RenameWebAuthnCredential changes the display name of an authenticator
+func (r *Repository) RenameWebAuthnCredential(userID, id uuid.UUID, name string) (bool, error) {
+	result := r.Db.Model(&entity.UserWebAuthnCredential{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("name", name)
+	if result.Error != nil {
+		return false, fmt.Errorf("error renaming webauthn credential: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteWebAuthnCredential removes one of the user's authenticators
+func (r *Repository) DeleteWebAuthnCredential(userID, id uuid.UUID) (bool, error) {
+	result := r.Db.Where("id = ? AND user_id = ?", id, userID).Delete(&entity.UserWebAuthnCredential{})
+	if result.Error != nil {
+		return false, fmt.Errorf("error deleting webauthn credential: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
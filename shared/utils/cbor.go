@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Minimal CBOR (RFC 8949) decoder covering what WebAuthn attestation objects and COSE keys use:
+// unsigned/negative integers, byte and text strings, arrays, maps, booleans and null.
+// Integers decode to int64, byte strings to []byte, text to string, arrays to []interface{}
+// and maps to map[interface{}]interface{}.
+
+var ErrInvalidCBOR = errors.New("invalid CBOR data")
+
+const cborMaxDepth = 16
+
+// DecodeCBOR decodes the first CBOR item in data and returns it with the number of bytes consumed
+func DecodeCBOR(data []byte) (interface{}, int, error) {
+	d := cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) readArgument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		if d.pos+1 > len(d.data) {
+			return 0, ErrInvalidCBOR
+		}
+		v := uint64(d.data[d.pos])
+		d.pos++
+		return v, nil
+	case info == 25:
+		if d.pos+2 > len(d.data) {
+			return 0, ErrInvalidCBOR
+		}
+		v := uint64(binary.BigEndian.Uint16(d.data[d.pos:]))
+		d.pos += 2
+		return v, nil
+	case info == 26:
+		if d.pos+4 > len(d.data) {
+			return 0, ErrInvalidCBOR
+		}
+		v := uint64(binary.BigEndian.Uint32(d.data[d.pos:]))
+		d.pos += 4
+		return v, nil
+	case info == 27:
+		if d.pos+8 > len(d.data) {
+			return 0, ErrInvalidCBOR
+		}
+		v := binary.BigEndian.Uint64(d.data[d.pos:])
+		d.pos += 8
+		return v, nil
+	default:
+		// Indefinite lengths are not used by WebAuthn authenticators
+		return 0, fmt.Errorf("%w: unsupported additional info %d", ErrInvalidCBOR, info)
+	}
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("%w: nesting too deep", ErrInvalidCBOR)
+	}
+	if d.pos >= len(d.data) {
+		return nil, ErrInvalidCBOR
+	}
+
+	initial := d.data[d.pos]
+	d.pos++
+	major := initial >> 5
+	info := initial & 0x1f
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("%w: unsupported simple value %d", ErrInvalidCBOR, info)
+		}
+	}
+
+	arg, err := d.readArgument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, ErrInvalidCBOR
+		}
+		raw := d.data[d.pos : d.pos+int(arg)]
+		d.pos += int(arg)
+		if major == 3 {
+			return string(raw), nil
+		}
+		out := make([]byte, len(raw))
+		copy(out, raw)
+		return out, nil
+	case 4:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, ErrInvalidCBOR
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, ErrInvalidCBOR
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("%w: unsupported map key type %T", ErrInvalidCBOR, key)
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	case 6:
+		// Tags carry no meaning for WebAuthn structures, decode the tagged item
+		return d.decode(depth + 1)
+	default:
+		return nil, fmt.Errorf("%w: unsupported major type %d", ErrInvalidCBOR, major)
+	}
+}
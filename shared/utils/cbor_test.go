@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// cborEncode is the counterpart of DecodeCBOR for building test fixtures. It supports the same types:
+// int/int64, []byte, string, bool, nil, []interface{} and map[interface{}]interface{}.
+func cborEncode(v interface{}) []byte {
+	var buf bytes.Buffer
+	writeCBOR(&buf, v)
+	return buf.Bytes()
+}
+
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+func writeCBOR(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case int:
+		writeCBOR(buf, int64(v))
+	case int64:
+		if v >= 0 {
+			writeCBORHead(buf, 0, uint64(v))
+		} else {
+			writeCBORHead(buf, 1, uint64(-1-v))
+		}
+	case []byte:
+		writeCBORHead(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		writeCBORHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeCBORHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			writeCBOR(buf, item)
+		}
+	case map[interface{}]interface{}:
+		writeCBORHead(buf, 5, uint64(len(v)))
+		for key, value := range v {
+			writeCBOR(buf, key)
+			writeCBOR(buf, value)
+		}
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case nil:
+		buf.WriteByte(0xf6)
+	default:
+		panic(fmt.Sprintf("cborEncode: unsupported type %T", v))
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// Examples from RFC 8949 appendix A
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3863", int64(-100)},
+		{"3903e7", int64(-1000)},
+		{"40", []byte{}},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f7", nil},
+		{"c11a514b67b0", int64(1363896240)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.hex)
+			got, consumed, err := DecodeCBOR(data)
+			if err != nil {
+				t.Fatalf("DecodeCBOR: %v", err)
+			}
+			if consumed != len(data) {
+				t.Fatalf("consumed %d bytes, want %d", consumed, len(data))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DecodeCBOR = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORStopsAfterFirstItem(t *testing.T) {
+	// A COSE key is followed by extensions in authenticator data, only the first item is consumed
+	data, _ := hex.DecodeString("a2010203044401020304")
+	_, consumed, err := DecodeCBOR(data)
+	if err != nil || consumed != 5 {
+		t.Fatalf("DecodeCBOR consumed %d bytes, err %v; want 5, nil", consumed, err)
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, cborMaxDepth+2)
+	deep = append(deep, 0x00)
+
+	tests := []struct {
+		name string
+		hex  string
+		data []byte
+	}{
+		{name: "empty", hex: ""},
+		{name: "truncated 1-byte argument", hex: "18"},
+		{name: "truncated 2-byte argument", hex: "19ff"},
+		{name: "truncated 4-byte argument", hex: "1a0000"},
+		{name: "truncated 8-byte argument", hex: "1b00000000000000"},
+		{name: "reserved additional info", hex: "1c"},
+		{name: "indefinite byte string", hex: "5f4101ff"},
+		{name: "indefinite array", hex: "9f01ff"},
+		{name: "byte string longer than input", hex: "4401020304"[:8]},
+		{name: "huge byte string length", hex: "5b7fffffffffffffff00"},
+		{name: "text string longer than input", hex: "6449455"[:6]},
+		{name: "array missing items", hex: "830102"},
+		{name: "array length larger than input", hex: "9b7fffffffffffffff"},
+		{name: "map missing value", hex: "a101"},
+		{name: "map length larger than input", hex: "bb7fffffffffffffff"},
+		{name: "byte string map key", hex: "a1410101"},
+		{name: "array map key", hex: "a1800101"},
+		{name: "unsigned overflow", hex: "1bffffffffffffffff"},
+		{name: "negative overflow", hex: "3bffffffffffffffff"},
+		{name: "unassigned simple value", hex: "f0"},
+		{name: "one-byte simple value", hex: "f820"},
+		{name: "float", hex: "f93c00"},
+		{name: "tag without item", hex: "c1"},
+		{name: "nesting too deep", data: deep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if data == nil {
+				var err error
+				if data, err = hex.DecodeString(tt.hex); err != nil {
+					t.Fatalf("bad fixture %q: %v", tt.hex, err)
+				}
+			}
+			if _, _, err := DecodeCBOR(data); !errors.Is(err, ErrInvalidCBOR) {
+				t.Fatalf("DecodeCBOR(%x) error = %v, want ErrInvalidCBOR", data, err)
+			}
+		})
+	}
+}
+
+func TestCBOREncodeRoundTrip(t *testing.T) {
+	value := map[interface{}]interface{}{
+		int64(1):  int64(2),
+		int64(-1): []byte("bytes"),
+		"list":    []interface{}{int64(-300), "text", true, nil},
+		"big":     int64(1 << 40),
+	}
+	decoded, _, err := DecodeCBOR(cborEncode(value))
+	if err != nil || !reflect.DeepEqual(decoded, value) {
+		t.Fatalf("round trip = %#v, %v", decoded, err)
+	}
+}
@@ -2,13 +2,13 @@ package utils
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/tnqbao/gau-account-service/shared/config"
-
-	"strings"
 )
 
 func ExtractToken(c *gin.Context) string {
@@ -23,14 +23,48 @@ func ExtractToken(c *gin.Context) string {
 	return ""
 }
 
-func ParseToken(tokenString string, config *config.EnvConfig) (*jwt.Token, error) {
-	secret := []byte(config.JWT.SecretKey)
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// TokenVerifier checks access token signatures. HS256 uses the shared JWT_SECRET_KEY, RS256, ES256
+// and EdDSA use keys from the authorization service JWKS, selected by the kid header.
+type TokenVerifier struct {
+	algorithms []string
+	secret     []byte
+	jwks       *JWKSCache
+}
+
+func NewTokenVerifier(cfg *config.EnvConfig) *TokenVerifier {
+	verifier := &TokenVerifier{
+		algorithms: cfg.JWT.Algorithms,
+		secret:     []byte(cfg.JWT.SecretKey),
+	}
+	for _, alg := range cfg.JWT.Algorithms {
+		if alg != jwt.SigningMethodHS256.Alg() {
+			verifier.jwks = NewJWKSCache(cfg.JWT.JWKSURL, time.Duration(cfg.JWT.JWKSRefreshInterval)*time.Second)
+			break
 		}
-		return secret, nil
-	})
+	}
+	return verifier
+}
+
+// ParseToken verifies the signature with one of the accepted algorithms and the standard time claims
+func (v *TokenVerifier) ParseToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, v.keyFunc, jwt.WithValidMethods(v.algorithms))
+}
+
+func (v *TokenVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(v.secret) == 0 {
+			return nil, errors.New("HMAC secret is not configured")
+		}
+		return v.secret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		if v.jwks == nil {
+			return nil, errors.New("JWKS is not configured")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return v.jwks.Key(kid)
+	}
+	return nil, errors.New("unexpected signing method")
 }
 
 func InjectClaimsToContext(c *gin.Context, claims jwt.MapClaims) error {
@@ -49,5 +83,27 @@ func InjectClaimsToContext(c *gin.Context, claims jwt.MapClaims) error {
 	} else {
 		c.Set("permission", "")
 	}
+
+	// Tokens issued before roles existed carry neither claim, so they are only set when present
+	if roles, ok := claimStrings(claims, "roles"); ok {
+		c.Set("roles", roles)
+	}
+	if scopes, ok := claimStrings(claims, "scopes"); ok {
+		c.Set("scopes", scopes)
+	}
 	return nil
 }
+
+func claimStrings(claims jwt.MapClaims, name string) ([]string, bool) {
+	raw, ok := claims[name].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, 0, len(raw))
+	for _, item := range raw {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values, true
+}
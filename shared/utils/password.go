@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tnqbao/gau-account-service/shared/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var ErrInvalidPasswordHash = errors.New("invalid password hash format")
+
+// PasswordHasher hashes and verifies passwords using the algorithm configured in EnvConfig.
+// Hashes are stored as self-describing strings so parameters can change without breaking old rows:
+//   - argon2id: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash> (PHC string format)
+//   - bcrypt:   $2a$12$<salt+hash> (modular crypt format)
+//   - legacy:   64 hex characters, unsalted SHA-256 (verify only)
+type PasswordHasher struct {
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+func NewPasswordHasher(config *config.EnvConfig) *PasswordHasher {
+	return &PasswordHasher{
+		Algorithm:         config.Password.Algorithm,
+		Argon2Memory:      config.Password.Argon2Memory,
+		Argon2Iterations:  config.Password.Argon2Iterations,
+		Argon2Parallelism: config.Password.Argon2Parallelism,
+		BcryptCost:        config.Password.BcryptCost,
+	}
+}
+
+// Hash returns an encoded hash of the password using the configured algorithm
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case PasswordAlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password with bcrypt: %w", err)
+		}
+		return string(hash), nil
+	case PasswordAlgorithmArgon2id, "":
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("failed to generate salt: %w", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, h.Argon2Iterations, h.Argon2Memory, h.Argon2Parallelism, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, h.Argon2Memory, h.Argon2Iterations, h.Argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	default:
+		return "", fmt.Errorf("unsupported password algorithm: %s", h.Algorithm)
+	}
+}
+
+// Verify checks the password against an encoded hash. needsRehash is true when the password
+// matched but the stored hash uses a legacy format, another algorithm or outdated parameters.
+func (h *PasswordHasher) Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2idHash(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false, nil
+		}
+		outdated := h.Algorithm == PasswordAlgorithmBcrypt ||
+			params.memory != h.Argon2Memory ||
+			params.iterations != h.Argon2Iterations ||
+			params.parallelism != h.Argon2Parallelism
+		return true, outdated, nil
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, fmt.Errorf("failed to verify bcrypt hash: %w", err)
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return true, true, nil
+		}
+		return true, h.Algorithm != PasswordAlgorithmBcrypt || cost != h.BcryptCost, nil
+
+	case IsLegacyPasswordHash(encoded):
+		sum := sha256.Sum256([]byte(password))
+		candidate := hex.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(strings.ToLower(encoded))) != 1 {
+			return false, false, nil
+		}
+		return true, true, nil
+
+	default:
+		return false, false, ErrInvalidPasswordHash
+	}
+}
+
+// VerifyDummy spends the time of a real verification when there is no hash to check against, so response
+// timing does not reveal unknown identifiers or accounts without a password. Hashing with the configured
+// parameters costs the same as verifying a hash created with them.
+func (h *PasswordHasher) VerifyDummy(password string) {
+	_, _ = h.Hash(password)
+}
+
+// IsLegacyPasswordHash reports whether the hash is a bare SHA-256 hex digest from the old scheme
+func IsLegacyPasswordHash(encoded string) bool {
+	if len(encoded) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func decodeArgon2idHash(encoded string) (*argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	return &params, salt, key, nil
+}
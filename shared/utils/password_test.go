@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Cheap parameters keep the tests fast; the production defaults only differ in cost
+func testHasher(algorithm string) *PasswordHasher {
+	return &PasswordHasher{
+		Algorithm:         algorithm,
+		Argon2Memory:      64,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+		BcryptCost:        4,
+	}
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{PasswordAlgorithmArgon2id, PasswordAlgorithmBcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			h := testHasher(algorithm)
+			encoded, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			match, needsRehash, err := h.Verify("correct horse", encoded)
+			if err != nil || !match || needsRehash {
+				t.Fatalf("Verify(right password) = %v, %v, %v; want true, false, nil", match, needsRehash, err)
+			}
+			match, _, err = h.Verify("wrong horse", encoded)
+			if err != nil || match {
+				t.Fatalf("Verify(wrong password) = %v, %v; want false, nil", match, err)
+			}
+
+			again, _ := h.Hash("correct horse")
+			if again == encoded {
+				t.Fatal("two hashes of the same password are equal, salt is not random")
+			}
+		})
+	}
+}
+
+func TestPasswordHasherArgon2idFormat(t *testing.T) {
+	encoded, err := testHasher(PasswordAlgorithmArgon2id).Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected encoding %q", encoded)
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	old := testHasher(PasswordAlgorithmArgon2id)
+	encoded, _ := old.Hash("secret")
+	bcryptEncoded, _ := testHasher(PasswordAlgorithmBcrypt).Hash("secret")
+
+	stronger := testHasher(PasswordAlgorithmArgon2id)
+	stronger.Argon2Iterations = 2
+	otherCost := testHasher(PasswordAlgorithmBcrypt)
+	otherCost.BcryptCost = 5
+
+	tests := []struct {
+		name    string
+		hasher  *PasswordHasher
+		encoded string
+		want    bool
+	}{
+		{"same argon2id parameters", old, encoded, false},
+		{"argon2id iterations raised", stronger, encoded, true},
+		{"switched to bcrypt", testHasher(PasswordAlgorithmBcrypt), encoded, true},
+		{"bcrypt cost raised", otherCost, bcryptEncoded, true},
+		{"switched to argon2id", old, bcryptEncoded, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, needsRehash, err := tt.hasher.Verify("secret", tt.encoded)
+			if err != nil || !match {
+				t.Fatalf("Verify = %v, %v; want a match", match, err)
+			}
+			if needsRehash != tt.want {
+				t.Fatalf("needsRehash = %v, want %v", needsRehash, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherLegacySHA256(t *testing.T) {
+	sum := sha256.Sum256([]byte("legacy-password"))
+	legacy := hex.EncodeToString(sum[:])
+	h := testHasher(PasswordAlgorithmArgon2id)
+
+	if !IsLegacyPasswordHash(legacy) || !IsLegacyPasswordHash(strings.ToUpper(legacy)) {
+		t.Fatal("legacy hash not recognised")
+	}
+
+	match, needsRehash, err := h.Verify("legacy-password", legacy)
+	if err != nil || !match || !needsRehash {
+		t.Fatalf("Verify(legacy) = %v, %v, %v; want true, true, nil", match, needsRehash, err)
+	}
+	match, _, err = h.Verify("legacy-password", strings.ToUpper(legacy))
+	if err != nil || !match {
+		t.Fatalf("Verify(upper-case legacy) = %v, %v; want a match", match, err)
+	}
+	match, needsRehash, err = h.Verify("other", legacy)
+	if err != nil || match || needsRehash {
+		t.Fatalf("Verify(wrong password, legacy) = %v, %v, %v; want false, false, nil", match, needsRehash, err)
+	}
+
+	// The upgrade stores a hash in the configured format which verifies without another upgrade
+	upgraded, err := h.Hash("legacy-password")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	match, needsRehash, _ = h.Verify("legacy-password", upgraded)
+	if !match || needsRehash {
+		t.Fatalf("Verify(upgraded) = %v, %v; want true, false", match, needsRehash)
+	}
+}
+
+func TestPasswordHasherInvalidHash(t *testing.T) {
+	h := testHasher(PasswordAlgorithmArgon2id)
+	for _, encoded := range []string{
+		"",
+		"plain-text",
+		"$argon2id$v=19$m=64,t=1,p=1$onlysalt",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+		strings.Repeat("z", 64),
+	} {
+		if match, _, err := h.Verify("secret", encoded); match || err == nil {
+			t.Errorf("Verify(%q) = %v, %v; want false and an error", encoded, match, err)
+		}
+	}
+}
+
+func TestPasswordHasherVerifyDummyTiming(t *testing.T) {
+	// Heavier parameters than the other tests so the work dominates scheduling noise
+	h := &PasswordHasher{
+		Algorithm:         PasswordAlgorithmArgon2id,
+		Argon2Memory:      8 * 1024,
+		Argon2Iterations:  2,
+		Argon2Parallelism: 1,
+	}
+	encoded, _ := h.Hash("secret")
+
+	fastest := func(f func()) time.Duration {
+		best := time.Duration(1<<63 - 1)
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			f()
+			if d := time.Since(start); d < best {
+				best = d
+			}
+		}
+		return best
+	}
+	verify := fastest(func() { _, _, _ = h.Verify("wrong", encoded) })
+	dummy := fastest(func() { h.VerifyDummy("wrong") })
+
+	if dummy < verify/3 || dummy > verify*3 {
+		t.Fatalf("dummy verification took %v, real verification %v; timing reveals unknown accounts", dummy, verify)
+	}
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -32,6 +34,15 @@ func JSON401(c *gin.Context, err string) {
 	})
 }
 
+// JSON401Code is JSON401 with a machine-readable error_code
+func JSON401Code(c *gin.Context, err string, code string) {
+	c.JSON(401, gin.H{
+		"error":      err,
+		"error_code": code,
+		"status":     401,
+	})
+}
+
 func JSON409(c *gin.Context, err string) {
 	c.JSON(409, gin.H{
 		"error":  err,
@@ -45,3 +56,39 @@ func JSON404(c *gin.Context, err string) {
 		"status": 404,
 	})
 }
+
+func JSON423(c *gin.Context, err string, unlockIn int) {
+	c.Header("Retry-After", strconv.Itoa(unlockIn))
+	c.JSON(423, gin.H{
+		"error":     err,
+		"unlock_in": unlockIn,
+		"status":    423,
+	})
+}
+
+func JSON429(c *gin.Context, err string, retryAfter int) {
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(429, gin.H{
+		"error":       err,
+		"retry_after": retryAfter,
+		"status":      429,
+	})
+}
+
+// JSON400Code is JSON400 with a machine-readable error_code for clients that need to tell failures apart
+func JSON400Code(c *gin.Context, err string, code string) {
+	c.JSON(400, gin.H{
+		"error":      err,
+		"error_code": code,
+		"status":     400,
+	})
+}
+
+// JSON403Code answers 403 with a machine-readable error_code
+func JSON403Code(c *gin.Context, err string, code string) {
+	c.JSON(403, gin.H{
+		"error":      err,
+		"error_code": code,
+		"status":     403,
+	})
+}
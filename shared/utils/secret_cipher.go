@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/config"
+)
+
+// Encrypted secret columns are stored as enc:<key id>:<base64url(nonce || ciphertext)>.
+// Values without the prefix are legacy plaintext and are returned unchanged by Decrypt.
+const secretCipherPrefix = "enc:"
+
+var (
+	ErrSecretKeyMissing    = errors.New("no secret encryption key configured")
+	ErrSecretKeyUnknown    = errors.New("secret is encrypted with an unknown key")
+	ErrInvalidSecretCipher = errors.New("invalid encrypted secret")
+)
+
+// SecretCipher encrypts secret columns with AES-256-GCM. Several keys can be loaded at once so
+// old ciphertexts stay readable while new ones are written with the active key.
+type SecretCipher struct {
+	ActiveKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+func NewSecretCipher(config *config.EnvConfig) (*SecretCipher, error) {
+	c := &SecretCipher{
+		ActiveKeyID: config.SecretEncryption.ActiveKeyID,
+		keys:        make(map[string]cipher.AEAD, len(config.SecretEncryption.Keys)),
+	}
+
+	for id, encoded := range config.SecretEncryption.Keys {
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("secret encryption key ID %q must not contain ':'", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("secret encryption key %q is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("secret encryption key %q must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("secret encryption key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("secret encryption key %q: %w", id, err)
+		}
+		c.keys[id] = aead
+	}
+
+	if c.ActiveKeyID != "" {
+		if _, ok := c.keys[c.ActiveKeyID]; !ok {
+			return nil, fmt.Errorf("active secret encryption key %q is not configured", c.ActiveKeyID)
+		}
+	}
+
+	return c, nil
+}
+
+// Encrypt seals plaintext with the active key. associatedData binds the ciphertext to its row
+// so it cannot be copied onto another record.
+func (c *SecretCipher) Encrypt(plaintext, associatedData string) (string, error) {
+	aead, ok := c.keys[c.ActiveKeyID]
+	if !ok {
+		return "", ErrSecretKeyMissing
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+
+	return secretCipherPrefix + c.ActiveKeyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with whichever configured key it names
+func (c *SecretCipher) Decrypt(stored, associatedData string) (string, error) {
+	if !IsEncryptedSecret(stored) {
+		return stored, nil
+	}
+
+	keyID, payload, found := strings.Cut(strings.TrimPrefix(stored, secretCipherPrefix), ":")
+	if !found {
+		return "", ErrInvalidSecretCipher
+	}
+	aead, ok := c.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretKeyUnknown, keyID)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", ErrInvalidSecretCipher
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(associatedData))
+	if err != nil {
+		return "", ErrInvalidSecretCipher
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencryption reports whether the stored value is plaintext or sealed with a non-active key
+func (c *SecretCipher) NeedsReencryption(stored string) bool {
+	if !IsEncryptedSecret(stored) {
+		return true
+	}
+	return !strings.HasPrefix(stored, secretCipherPrefix+c.ActiveKeyID+":")
+}
+
+// IsEncryptedSecret reports whether a column value was produced by SecretCipher
+func IsEncryptedSecret(stored string) bool {
+	return strings.HasPrefix(stored, secretCipherPrefix)
+}
+
+// MFASecretAssociatedData is the associated data used for UserMFA.Secret ciphertexts
+func MFASecretAssociatedData(mfaID uuid.UUID) string {
+	return "user_mfa:" + mfaID.String()
+}
+
+// SessionTokenAssociatedData is the associated data used for refresh tokens sealed in the session index
+func SessionTokenAssociatedData(userID, deviceID string) string {
+	return "user_session:" + userID + ":" + deviceID
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-account-service/shared/config"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func testCipher(t *testing.T, active string, keys map[string]string) *SecretCipher {
+	t.Helper()
+	var cfg config.EnvConfig
+	cfg.SecretEncryption.Keys = keys
+	cfg.SecretEncryption.ActiveKeyID = active
+	c, err := NewSecretCipher(&cfg)
+	if err != nil {
+		t.Fatalf("NewSecretCipher: %v", err)
+	}
+	return c
+}
+
+func TestSecretCipherRoundTrip(t *testing.T) {
+	c := testCipher(t, "k1", map[string]string{"k1": testKey(1)})
+	ad := MFASecretAssociatedData(uuid.New())
+
+	sealed, err := c.Encrypt("JBSWY3DPEHPK3PXP", ad)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(sealed, "enc:k1:") || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("unexpected ciphertext %q", sealed)
+	}
+	if again, _ := c.Encrypt("JBSWY3DPEHPK3PXP", ad); again == sealed {
+		t.Fatal("two encryptions are equal, nonce is not random")
+	}
+
+	plain, err := c.Decrypt(sealed, ad)
+	if err != nil || plain != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+	if c.NeedsReencryption(sealed) {
+		t.Fatal("fresh ciphertext reported as needing re-encryption")
+	}
+}
+
+func TestSecretCipherRotation(t *testing.T) {
+	ad := MFASecretAssociatedData(uuid.New())
+	old := testCipher(t, "k1", map[string]string{"k1": testKey(1)})
+	sealedOld, _ := old.Encrypt("secret", ad)
+
+	// k2 becomes active, k1 stays loaded until every row is re-encrypted
+	rotated := testCipher(t, "k2", map[string]string{"k1": testKey(1), "k2": testKey(2)})
+	if !rotated.NeedsReencryption(sealedOld) {
+		t.Fatal("ciphertext of the retired key not reported as needing re-encryption")
+	}
+	plain, err := rotated.Decrypt(sealedOld, ad)
+	if err != nil || plain != "secret" {
+		t.Fatalf("Decrypt(old key) = %q, %v", plain, err)
+	}
+
+	sealedNew, err := rotated.Encrypt(plain, ad)
+	if err != nil || !strings.HasPrefix(sealedNew, "enc:k2:") || rotated.NeedsReencryption(sealedNew) {
+		t.Fatalf("re-encrypted value %q, %v", sealedNew, err)
+	}
+
+	// Once k1 is removed from config its ciphertexts are refused with a clear error
+	k2Only := testCipher(t, "k2", map[string]string{"k2": testKey(2)})
+	if _, err := k2Only.Decrypt(sealedOld, ad); !errors.Is(err, ErrSecretKeyUnknown) {
+		t.Fatalf("Decrypt(removed key) error = %v, want ErrSecretKeyUnknown", err)
+	}
+	if plain, err := k2Only.Decrypt(sealedNew, ad); err != nil || plain != "secret" {
+		t.Fatalf("Decrypt(new key) = %q, %v", plain, err)
+	}
+}
+
+func TestSecretCipherRejectsTampering(t *testing.T) {
+	c := testCipher(t, "k1", map[string]string{"k1": testKey(1)})
+	ad := MFASecretAssociatedData(uuid.New())
+	sealed, _ := c.Encrypt("secret", ad)
+
+	payload := strings.TrimPrefix(sealed, "enc:k1:")
+	raw, _ := base64.RawURLEncoding.DecodeString(payload)
+	raw[len(raw)-1] ^= 1
+	flipped := "enc:k1:" + base64.RawURLEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name   string
+		stored string
+		ad     string
+	}{
+		{"row swapped", sealed, MFASecretAssociatedData(uuid.New())},
+		{"ciphertext modified", flipped, ad},
+		{"truncated", "enc:k1:" + payload[:8], ad},
+		{"not base64", "enc:k1:!!!", ad},
+		{"no key id", "enc:" + payload, ad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Decrypt(tt.stored, tt.ad); err == nil {
+				t.Fatal("Decrypt accepted an invalid ciphertext")
+			}
+		})
+	}
+}
+
+func TestSecretCipherLegacyPlaintext(t *testing.T) {
+	c := testCipher(t, "k1", map[string]string{"k1": testKey(1)})
+	plain, err := c.Decrypt("JBSWY3DPEHPK3PXP", "")
+	if err != nil || plain != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("Decrypt(plaintext) = %q, %v", plain, err)
+	}
+	if !c.NeedsReencryption("JBSWY3DPEHPK3PXP") {
+		t.Fatal("plaintext not reported as needing encryption")
+	}
+}
+
+func TestNewSecretCipherInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		active string
+		keys   map[string]string
+	}{
+		{"short key", "k1", map[string]string{"k1": base64.StdEncoding.EncodeToString(make([]byte, 16))}},
+		{"not base64", "k1", map[string]string{"k1": "%%%"}},
+		{"active key missing", "k2", map[string]string{"k1": testKey(1)}},
+		{"colon in id", "a:b", map[string]string{"a:b": testKey(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.EnvConfig
+			cfg.SecretEncryption.Keys = tt.keys
+			cfg.SecretEncryption.ActiveKeyID = tt.active
+			if _, err := NewSecretCipher(&cfg); err == nil {
+				t.Fatal("NewSecretCipher accepted an invalid config")
+			}
+		})
+	}
+
+	empty := testCipher(t, "", map[string]string{})
+	if _, err := empty.Encrypt("secret", ""); !errors.Is(err, ErrSecretKeyMissing) {
+		t.Fatalf("Encrypt without keys error = %v, want ErrSecretKeyMissing", err)
+	}
+}
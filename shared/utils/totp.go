@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/tnqbao/gau-account-service/shared/config"
+)
+
+// TOTPPeriod is the length of one TOTP time step. Authenticator apps assume 30 seconds.
+const TOTPPeriod = 30
+
+var (
+	ErrTOTPInvalidCode  = errors.New("invalid TOTP code")
+	ErrTOTPReplayedCode = errors.New("TOTP code has already been used")
+)
+
+// TOTPOptions holds the TOTP parameters configured in EnvConfig
+type TOTPOptions struct {
+	Skew      uint
+	Digits    otp.Digits
+	Algorithm otp.Algorithm
+}
+
+func NewTOTPOptions(config *config.EnvConfig) *TOTPOptions {
+	return (&TOTPOptions{Skew: config.TOTP.Skew}).WithParameters(config.TOTP.Digits, config.TOTP.Algorithm)
+}
+
+// WithParameters returns a copy using the digits and algorithm a secret was enrolled with. Secrets
+// enrolled before these were recorded have zero values and fall back to 6 digits and SHA1.
+func (o *TOTPOptions) WithParameters(digits int, algorithm string) *TOTPOptions {
+	opts := *o
+	opts.Digits = otp.DigitsSix
+	if digits == 8 {
+		opts.Digits = otp.DigitsEight
+	}
+	switch algorithm {
+	case "SHA256":
+		opts.Algorithm = otp.AlgorithmSHA256
+	case "SHA512":
+		opts.Algorithm = otp.AlgorithmSHA512
+	default:
+		opts.Algorithm = otp.AlgorithmSHA1
+	}
+	return &opts
+}
+
+// GenerateOpts returns the options for enrolling a new secret with these parameters
+func (o *TOTPOptions) GenerateOpts(issuer, accountName string, secret []byte) totp.GenerateOpts {
+	return totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Secret:      secret,
+		Period:      TOTPPeriod,
+		Digits:      o.Digits,
+		Algorithm:   o.Algorithm,
+	}
+}
+
+// MatchStep looks for the code within the allowed skew around t and returns the time step it belongs to
+func (o *TOTPOptions) MatchStep(code, secret string, t time.Time) (int64, bool) {
+	if len(code) != o.Digits.Length() {
+		return 0, false
+	}
+
+	current := t.Unix() / TOTPPeriod
+	skew := int64(o.Skew)
+	for step := current - skew; step <= current+skew; step++ {
+		if step < 0 {
+			continue
+		}
+		expected, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{
+			Digits:    o.Digits,
+			Algorithm: o.Algorithm,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// StepTTL is how long a used step must be remembered to cover the whole acceptance window
+func (o *TOTPOptions) StepTTL() time.Duration {
+	return time.Duration(2*o.Skew+2) * TOTPPeriod * time.Second
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestTOTPOptionsWithParameters(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000000, 0)
+	configured := &TOTPOptions{Skew: 1, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA512}
+
+	tests := []struct {
+		name          string
+		digits        int
+		algorithm     string
+		wantDigits    otp.Digits
+		wantAlgorithm otp.Algorithm
+	}{
+		{"recorded before parameters were stored", 0, "", otp.DigitsSix, otp.AlgorithmSHA1},
+		{"six digits SHA1", 6, "SHA1", otp.DigitsSix, otp.AlgorithmSHA1},
+		{"eight digits SHA256", 8, "SHA256", otp.DigitsEight, otp.AlgorithmSHA256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := configured.WithParameters(tt.digits, tt.algorithm)
+			if opts.Digits != tt.wantDigits || opts.Algorithm != tt.wantAlgorithm || opts.Skew != configured.Skew {
+				t.Fatalf("WithParameters = %+v", opts)
+			}
+
+			code, err := totp.GenerateCodeCustom(secret, now, totp.ValidateOpts{Period: TOTPPeriod, Digits: tt.wantDigits, Algorithm: tt.wantAlgorithm})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := opts.MatchStep(code, secret, now); !ok {
+				t.Fatalf("MatchStep rejected a code generated with the enrolment parameters")
+			}
+			if _, ok := configured.MatchStep(code, secret, now); ok {
+				t.Fatalf("MatchStep accepted the code with the configured parameters")
+			}
+		})
+	}
+}
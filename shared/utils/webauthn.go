@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/tnqbao/gau-account-service/shared/config"
+)
+
+// WebAuthn (Level 2) relying-party verification for passkeys. Attestation statements are not
+// verified (attestation conveyance "none"), so any authenticator format is accepted and only the
+// credential public key inside authData is used.
+
+const (
+	WebAuthnFlagUserPresent  byte = 0x01
+	WebAuthnFlagUserVerified byte = 0x04
+	WebAuthnFlagAttestedData byte = 0x40
+
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+var (
+	ErrWebAuthnVerification = errors.New("webauthn verification failed")
+	ErrWebAuthnSignCount    = errors.New("webauthn signature counter did not increase, authenticator may be cloned")
+)
+
+// WebAuthnRelyingParty holds the identity browsers bind credentials to
+type WebAuthnRelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+func NewWebAuthnRelyingParty(config *config.EnvConfig) *WebAuthnRelyingParty {
+	return &WebAuthnRelyingParty{
+		ID:      config.WebAuthn.RPID,
+		Name:    config.WebAuthn.RPName,
+		Origins: config.WebAuthn.Origins,
+	}
+}
+
+// WebAuthnCredential is the result of a successful registration ceremony
+type WebAuthnCredential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key bytes as sent by the authenticator
+	Algorithm int64
+	SignCount uint32
+	AAGUID    []byte
+	Format    string
+}
+
+type webAuthnClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type webAuthnAuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// GenerateWebAuthnChallenge returns a random base64url challenge
+func GenerateWebAuthnChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(challenge), nil
+}
+
+// DecodeWebAuthnBase64 accepts both base64url (what browsers send) and standard base64, padded or not
+func DecodeWebAuthnBase64(value string) ([]byte, error) {
+	value = strings.TrimRight(value, "=")
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create()
+func (rp *WebAuthnRelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte, requireUserVerification bool) (*WebAuthnCredential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := DecodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %v", ErrWebAuthnVerification, err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrWebAuthnVerification)
+	}
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing authData", ErrWebAuthnVerification)
+	}
+
+	authData, err := parseWebAuthnAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorFlags(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+	if authData.Flags&WebAuthnFlagAttestedData == 0 || len(authData.CredentialID) == 0 {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrWebAuthnVerification)
+	}
+
+	_, alg, err := ParseCOSEPublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCredential{
+		ID:        authData.CredentialID,
+		PublicKey: authData.PublicKey,
+		Algorithm: alg,
+		SignCount: authData.SignCount,
+		AAGUID:    authData.AAGUID,
+		Format:    format,
+	}, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get() against a stored credential
+// and returns the new signature counter to persist
+func (rp *WebAuthnRelyingParty) VerifyAssertion(challenge string, clientDataJSON, rawAuthData, signature, publicKey []byte, storedSignCount uint32, requireUserVerification bool) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := parseWebAuthnAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorFlags(authData, requireUserVerification); err != nil {
+		return 0, err
+	}
+
+	key, alg, err := ParseCOSEPublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := make([]byte, 0, len(rawAuthData)+len(clientDataHash))
+	signed = append(signed, rawAuthData...)
+	signed = append(signed, clientDataHash[:]...)
+	if err := verifyCOSESignature(key, alg, signed, signature); err != nil {
+		return 0, err
+	}
+
+	// Authenticators that do not implement counters always report 0
+	if (authData.SignCount != 0 || storedSignCount != 0) && authData.SignCount <= storedSignCount {
+		return 0, ErrWebAuthnSignCount
+	}
+
+	return authData.SignCount, nil
+}
+
+func (rp *WebAuthnRelyingParty) verifyClientData(raw []byte, expectedType, expectedChallenge string) error {
+	var clientData webAuthnClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return fmt.Errorf("%w: invalid client data", ErrWebAuthnVerification)
+	}
+	if clientData.Type != expectedType {
+		return fmt.Errorf("%w: unexpected client data type %q", ErrWebAuthnVerification, clientData.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimRight(clientData.Challenge, "=")), []byte(expectedChallenge)) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrWebAuthnVerification)
+	}
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: origin %q is not allowed", ErrWebAuthnVerification, clientData.Origin)
+}
+
+func (rp *WebAuthnRelyingParty) verifyAuthenticatorFlags(authData *webAuthnAuthenticatorData, requireUserVerification bool) error {
+	expectedHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIDHash, expectedHash[:]) {
+		return fmt.Errorf("%w: RP ID hash mismatch", ErrWebAuthnVerification)
+	}
+	if authData.Flags&WebAuthnFlagUserPresent == 0 {
+		return fmt.Errorf("%w: user not present", ErrWebAuthnVerification)
+	}
+	if requireUserVerification && authData.Flags&WebAuthnFlagUserVerified == 0 {
+		return fmt.Errorf("%w: user not verified", ErrWebAuthnVerification)
+	}
+	return nil
+}
+
+func parseWebAuthnAuthenticatorData(data []byte) (*webAuthnAuthenticatorData, error) {
+	// rpIdHash (32) | flags (1) | signCount (4) | [aaguid (16) | credIdLen (2) | credId | COSE key] | [extensions]
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrWebAuthnVerification)
+	}
+
+	authData := &webAuthnAuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if authData.Flags&WebAuthnFlagAttestedData != 0 {
+		rest := data[37:]
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrWebAuthnVerification)
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, fmt.Errorf("%w: credential ID truncated", ErrWebAuthnVerification)
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, consumed, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %v", ErrWebAuthnVerification, err)
+		}
+		authData.PublicKey = rest[:consumed]
+	}
+
+	return authData, nil
+}
+
+// ParseCOSEPublicKey converts a COSE_Key (RFC 9053) into a Go public key for ES256, RS256 or EdDSA
+func ParseCOSEPublicKey(data []byte) (crypto.PublicKey, int64, error) {
+	decoded, _, err := DecodeCBOR(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: COSE key: %v", ErrWebAuthnVerification, err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: COSE key is not a map", ErrWebAuthnVerification)
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("%w: invalid P-256 key", ErrWebAuthnVerification)
+		}
+		point := append([]byte{0x04}, append(append([]byte{}, x...), y...)...)
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: invalid P-256 point", ErrWebAuthnVerification)
+		}
+		return pub, alg, nil
+
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid RSA key", ErrWebAuthnVerification)
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, alg, nil
+
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%w: invalid Ed25519 key", ErrWebAuthnVerification)
+		}
+		return ed25519.PublicKey(x), alg, nil
+
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported COSE key type %d / algorithm %d", ErrWebAuthnVerification, kty, alg)
+	}
+}
+
+func verifyCOSESignature(key crypto.PublicKey, alg int64, signed, signature []byte) error {
+	switch alg {
+	case COSEAlgES256:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature) {
+			return fmt.Errorf("%w: invalid signature", ErrWebAuthnVerification)
+		}
+	case COSEAlgRS256:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: invalid signature", ErrWebAuthnVerification)
+		}
+	case COSEAlgEdDSA:
+		if !ed25519.Verify(key.(ed25519.PublicKey), signed, signature) {
+			return fmt.Errorf("%w: invalid signature", ErrWebAuthnVerification)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %d", ErrWebAuthnVerification, alg)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+const testWebAuthnChallenge = "dGVzdC1jaGFsbGVuZ2U"
+
+var testRelyingParty = &WebAuthnRelyingParty{
+	ID:      "example.com",
+	Name:    "Example",
+	Origins: []string{"https://example.com"},
+}
+
+// testAuthenticator signs WebAuthn responses with a key generated for the test
+type testAuthenticator struct {
+	alg  int64
+	key  crypto.Signer
+	cose []byte
+}
+
+func newTestAuthenticator(t *testing.T, alg int64) *testAuthenticator {
+	t.Helper()
+	a := &testAuthenticator{alg: alg}
+	switch alg {
+	case COSEAlgES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.key = key
+		a.cose = cborEncode(map[interface{}]interface{}{
+			int64(1): int64(2), int64(3): alg, int64(-1): int64(1),
+			int64(-2): key.X.FillBytes(make([]byte, 32)),
+			int64(-3): key.Y.FillBytes(make([]byte, 32)),
+		})
+	case COSEAlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.key = key
+		a.cose = cborEncode(map[interface{}]interface{}{
+			int64(1): int64(3), int64(3): alg,
+			int64(-1): key.N.Bytes(),
+			int64(-2): big.NewInt(int64(key.E)).Bytes(),
+		})
+	case COSEAlgEdDSA:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.key = key
+		a.cose = cborEncode(map[interface{}]interface{}{
+			int64(1): int64(1), int64(3): alg, int64(-1): int64(6), int64(-2): []byte(pub),
+		})
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	return a
+}
+
+func (a *testAuthenticator) sign(t *testing.T, message []byte) []byte {
+	t.Helper()
+	var (
+		signature []byte
+		err       error
+	)
+	if a.alg == COSEAlgEdDSA {
+		signature, err = a.key.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		signature, err = a.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+func testClientData(ceremony, challenge, origin string) []byte {
+	raw, _ := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": origin})
+	return raw
+}
+
+func testAuthData(rpID string, flags byte, signCount uint32, credentialID, coseKey []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	if flags&WebAuthnFlagAttestedData != 0 {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(credentialID)))
+		data = append(data, credentialID...)
+		data = append(data, coseKey...)
+	}
+	return data
+}
+
+func testAttestationObject(authData []byte) []byte {
+	return cborEncode(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+}
+
+func TestWebAuthnRegistrationAndAssertion(t *testing.T) {
+	tests := []struct {
+		name string
+		alg  int64
+	}{
+		{"ES256", COSEAlgES256},
+		{"RS256", COSEAlgRS256},
+		{"EdDSA", COSEAlgEdDSA},
+	}
+	credentialID := []byte("credential-id")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t, tt.alg)
+
+			flags := WebAuthnFlagUserPresent | WebAuthnFlagUserVerified | WebAuthnFlagAttestedData
+			authData := testAuthData(testRelyingParty.ID, flags, 0, credentialID, authenticator.cose)
+			// Extensions after the COSE key must not end up in the stored public key
+			authData = append(authData, cborEncode(map[interface{}]interface{}{"credProtect": int64(2)})...)
+			clientData := testClientData("webauthn.create", testWebAuthnChallenge, "https://example.com")
+
+			credential, err := testRelyingParty.VerifyRegistration(testWebAuthnChallenge, clientData, testAttestationObject(authData), true)
+			if err != nil {
+				t.Fatalf("VerifyRegistration: %v", err)
+			}
+			if credential.Algorithm != tt.alg || !bytes.Equal(credential.ID, credentialID) || !bytes.Equal(credential.PublicKey, authenticator.cose) {
+				t.Fatalf("VerifyRegistration returned %+v", credential)
+			}
+
+			assertionData := testAuthData(testRelyingParty.ID, WebAuthnFlagUserPresent|WebAuthnFlagUserVerified, 7, nil, nil)
+			assertionClientData := testClientData("webauthn.get", testWebAuthnChallenge, "https://example.com")
+			clientDataHash := sha256.Sum256(assertionClientData)
+			signature := authenticator.sign(t, append(append([]byte{}, assertionData...), clientDataHash[:]...))
+
+			signCount, err := testRelyingParty.VerifyAssertion(testWebAuthnChallenge, assertionClientData, assertionData, signature, credential.PublicKey, 3, true)
+			if err != nil || signCount != 7 {
+				t.Fatalf("VerifyAssertion = %d, %v; want 7, nil", signCount, err)
+			}
+
+			tampered := append([]byte{}, signature...)
+			tampered[len(tampered)-1] ^= 0xff
+			if _, err := testRelyingParty.VerifyAssertion(testWebAuthnChallenge, assertionClientData, assertionData, tampered, credential.PublicKey, 3, true); !errors.Is(err, ErrWebAuthnVerification) {
+				t.Fatalf("VerifyAssertion with a bad signature error = %v, want ErrWebAuthnVerification", err)
+			}
+
+			otherData := testAuthData(testRelyingParty.ID, WebAuthnFlagUserPresent|WebAuthnFlagUserVerified, 8, nil, nil)
+			if _, err := testRelyingParty.VerifyAssertion(testWebAuthnChallenge, assertionClientData, otherData, signature, credential.PublicKey, 3, true); !errors.Is(err, ErrWebAuthnVerification) {
+				t.Fatalf("VerifyAssertion over modified authenticator data error = %v, want ErrWebAuthnVerification", err)
+			}
+		})
+	}
+}
+
+func TestWebAuthnVerifyRegistrationRejects(t *testing.T) {
+	authenticator := newTestAuthenticator(t, COSEAlgEdDSA)
+	credentialID := []byte("credential-id")
+	allFlags := WebAuthnFlagUserPresent | WebAuthnFlagUserVerified | WebAuthnFlagAttestedData
+	validAuthData := testAuthData(testRelyingParty.ID, allFlags, 0, credentialID, authenticator.cose)
+	validClientData := testClientData("webauthn.create", testWebAuthnChallenge, "https://example.com")
+
+	tests := []struct {
+		name              string
+		clientData        []byte
+		attestationObject []byte
+		requireUV         bool
+	}{
+		{name: "challenge mismatch", clientData: testClientData("webauthn.create", "other", "https://example.com")},
+		{name: "origin not allowed", clientData: testClientData("webauthn.create", testWebAuthnChallenge, "https://evil.example")},
+		{name: "assertion client data", clientData: testClientData("webauthn.get", testWebAuthnChallenge, "https://example.com")},
+		{name: "client data not JSON", clientData: []byte("{")},
+		{name: "RP ID hash mismatch", attestationObject: testAttestationObject(testAuthData("evil.example", allFlags, 0, credentialID, authenticator.cose))},
+		{name: "user not present", attestationObject: testAttestationObject(testAuthData(testRelyingParty.ID, WebAuthnFlagAttestedData, 0, credentialID, authenticator.cose))},
+		{name: "user not verified", requireUV: true, attestationObject: testAttestationObject(testAuthData(testRelyingParty.ID, WebAuthnFlagUserPresent|WebAuthnFlagAttestedData, 0, credentialID, authenticator.cose))},
+		{name: "no attested credential", attestationObject: testAttestationObject(testAuthData(testRelyingParty.ID, WebAuthnFlagUserPresent, 0, nil, nil))},
+		{name: "empty credential ID", attestationObject: testAttestationObject(testAuthData(testRelyingParty.ID, allFlags, 0, nil, authenticator.cose))},
+		{name: "authData shorter than header", attestationObject: testAttestationObject(validAuthData[:36])},
+		{name: "attested data truncated", attestationObject: testAttestationObject(validAuthData[:37+17])},
+		{name: "credential ID truncated", attestationObject: testAttestationObject(validAuthData[:37+18+len(credentialID)-1])},
+		{name: "COSE key truncated", attestationObject: testAttestationObject(validAuthData[:len(validAuthData)-1])},
+		{name: "missing authData", attestationObject: cborEncode(map[interface{}]interface{}{"fmt": "none"})},
+		{name: "attestation not a map", attestationObject: cborEncode([]interface{}{validAuthData})},
+		{name: "attestation truncated", attestationObject: testAttestationObject(validAuthData)[:20]},
+		{name: "unsupported key algorithm", attestationObject: testAttestationObject(testAuthData(testRelyingParty.ID, allFlags, 0, credentialID,
+			cborEncode(map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(-35)})))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientData := tt.clientData
+			if clientData == nil {
+				clientData = validClientData
+			}
+			attestationObject := tt.attestationObject
+			if attestationObject == nil {
+				attestationObject = testAttestationObject(validAuthData)
+			}
+			if _, err := testRelyingParty.VerifyRegistration(testWebAuthnChallenge, clientData, attestationObject, tt.requireUV); !errors.Is(err, ErrWebAuthnVerification) {
+				t.Fatalf("VerifyRegistration error = %v, want ErrWebAuthnVerification", err)
+			}
+		})
+	}
+}
+
+func TestWebAuthnAssertionSignCount(t *testing.T) {
+	authenticator := newTestAuthenticator(t, COSEAlgES256)
+	clientData := testClientData("webauthn.get", testWebAuthnChallenge, "https://example.com")
+	clientDataHash := sha256.Sum256(clientData)
+
+	tests := []struct {
+		name    string
+		stored  uint32
+		counter uint32
+		wantErr error
+	}{
+		{name: "counter not implemented", stored: 0, counter: 0},
+		{name: "first use", stored: 0, counter: 1},
+		{name: "increased", stored: 5, counter: 6},
+		{name: "repeated", stored: 5, counter: 5, wantErr: ErrWebAuthnSignCount},
+		{name: "went back", stored: 5, counter: 3, wantErr: ErrWebAuthnSignCount},
+		{name: "reset to zero", stored: 5, counter: 0, wantErr: ErrWebAuthnSignCount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authData := testAuthData(testRelyingParty.ID, WebAuthnFlagUserPresent, tt.counter, nil, nil)
+			signature := authenticator.sign(t, append(append([]byte{}, authData...), clientDataHash[:]...))
+
+			signCount, err := testRelyingParty.VerifyAssertion(testWebAuthnChallenge, clientData, authData, signature, authenticator.cose, tt.stored, false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAssertion error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && signCount != tt.counter {
+				t.Fatalf("VerifyAssertion = %d, want %d", signCount, tt.counter)
+			}
+		})
+	}
+}
+
+func TestParseCOSEPublicKeyRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		key  interface{}
+	}{
+		{"not a map", []interface{}{int64(1)}},
+		{"P-256 wrong curve", map[interface{}]interface{}{int64(1): int64(2), int64(3): COSEAlgES256, int64(-1): int64(2), int64(-2): make([]byte, 32), int64(-3): make([]byte, 32)}},
+		{"P-256 short coordinate", map[interface{}]interface{}{int64(1): int64(2), int64(3): COSEAlgES256, int64(-1): int64(1), int64(-2): make([]byte, 31), int64(-3): make([]byte, 32)}},
+		{"P-256 point not on curve", map[interface{}]interface{}{int64(1): int64(2), int64(3): COSEAlgES256, int64(-1): int64(1), int64(-2): make([]byte, 32), int64(-3): make([]byte, 32)}},
+		{"RSA modulus too short", map[interface{}]interface{}{int64(1): int64(3), int64(3): COSEAlgRS256, int64(-1): make([]byte, 128), int64(-2): []byte{1, 0, 1}}},
+		{"RSA missing exponent", map[interface{}]interface{}{int64(1): int64(3), int64(3): COSEAlgRS256, int64(-1): make([]byte, 256)}},
+		{"Ed25519 wrong curve", map[interface{}]interface{}{int64(1): int64(1), int64(3): COSEAlgEdDSA, int64(-1): int64(7), int64(-2): make([]byte, 32)}},
+		{"Ed25519 short key", map[interface{}]interface{}{int64(1): int64(1), int64(3): COSEAlgEdDSA, int64(-1): int64(6), int64(-2): make([]byte, 16)}},
+		{"key type and algorithm mismatch", map[interface{}]interface{}{int64(1): int64(1), int64(3): COSEAlgES256}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseCOSEPublicKey(cborEncode(tt.key)); !errors.Is(err, ErrWebAuthnVerification) {
+				t.Fatalf("ParseCOSEPublicKey error = %v, want ErrWebAuthnVerification", err)
+			}
+		})
+	}
+	if _, _, err := ParseCOSEPublicKey([]byte{0xa2, 0x01}); !errors.Is(err, ErrWebAuthnVerification) {
+		t.Fatalf("ParseCOSEPublicKey on truncated CBOR error = %v, want ErrWebAuthnVerification", err)
+	}
+}